@@ -0,0 +1,100 @@
+package response
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+)
+
+// JSON func answer a response code with its http status and its description in the language of the client
+func JSON(context *gin.Context, code int, data interface{}) {
+	WithStatus(context, apperr.Status(code), code, data)
+}
+
+// WithStatus func answer a response code with an explicit http status
+func WithStatus(context *gin.Context, status int, code int, data interface{}) {
+	lang := Language(context)
+	context.Header("Content-Language", lang)
+	context.Header("Vary", "Accept-Language")
+	context.JSON(status, gin.H{
+		"code": code,
+		"data": data,
+		"msg":  constant.GetLocalizedMessage(code, lang),
+	})
+}
+
+// JobStatus func answer the state of a background job, the request itself succeeds
+// even if the job failed so only the codes which are not job states keep their http status.
+func JobStatus(context *gin.Context, code int, data interface{}) {
+	switch code {
+	case constant.PhotoAddInProcess, constant.PhotoUploadSuccess, constant.PhotoUploadError,
+		constant.BucketExportInProcess, constant.BucketExportSuccess, constant.BucketExportError:
+		WithStatus(context, http.StatusOK, code, data)
+	default:
+		JSON(context, code, data)
+	}
+}
+
+// Document func answer a document which has a standard format of its own instead of a response code
+func Document(context *gin.Context, document interface{}) {
+	context.JSON(http.StatusOK, document)
+}
+
+// Abort func answer a response code without data and stop the handlers after the current one
+func Abort(context *gin.Context, code int) {
+	AbortWithData(context, code, make(map[string]string))
+}
+
+// AbortWithData func answer a response code and stop the handlers after the current one
+func AbortWithData(context *gin.Context, code int, data interface{}) {
+	JSON(context, code, data)
+	context.Abort()
+}
+
+// Language func get the supported language the client prefers in its Accept-Language header
+func Language(context *gin.Context) string {
+	type preference struct {
+		lang    string
+		quality float64
+	}
+	preferences := make([]preference, 0)
+	for _, part := range strings.Split(context.GetHeader("Accept-Language"), ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		lang := strings.ToLower(strings.TrimSpace(fields[0]))
+		if lang == "" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range fields[1:] {
+			if value := strings.TrimSpace(param); strings.HasPrefix(value, "q=") {
+				if q, err := strconv.ParseFloat(value[2:], 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality > 0 {
+			preferences = append(preferences, preference{lang, quality})
+		}
+	}
+	sort.SliceStable(preferences, func(i, j int) bool {
+		return preferences[i].quality > preferences[j].quality
+	})
+
+	// zh-CN matches zh and the english messages are the default
+	for _, p := range preferences {
+		for _, lang := range []string{p.lang, strings.SplitN(p.lang, "-", 2)[0]} {
+			if lang == constant.DefaultLanguage {
+				return lang
+			}
+			if _, ok := constant.Messages[lang]; ok {
+				return lang
+			}
+		}
+	}
+	return constant.DefaultLanguage
+}
@@ -0,0 +1,101 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+)
+
+// newTestContext func create a gin context of a request with the Accept-Language header
+func newTestContext(acceptLanguage string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	context, _ := gin.CreateTestContext(recorder)
+	context.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	if acceptLanguage != "" {
+		context.Request.Header.Set("Accept-Language", acceptLanguage)
+	}
+	return context, recorder
+}
+
+func TestLanguage(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		want           string
+	}{
+		{name: "no header", acceptLanguage: "", want: "en"},
+		{name: "english", acceptLanguage: "en", want: "en"},
+		{name: "chinese", acceptLanguage: "zh", want: "zh"},
+		{name: "region falls back to the language", acceptLanguage: "zh-CN", want: "zh"},
+		{name: "case insensitive", acceptLanguage: "ZH-cn", want: "zh"},
+		{name: "first supported", acceptLanguage: "fr, zh, en", want: "zh"},
+		{name: "highest quality", acceptLanguage: "en;q=0.5, zh;q=0.8", want: "zh"},
+		{name: "default quality is 1", acceptLanguage: "zh;q=0.9, en", want: "en"},
+		{name: "same quality keeps the order", acceptLanguage: "en;q=0.7, zh;q=0.7", want: "en"},
+		{name: "zero quality is refused", acceptLanguage: "zh;q=0, fr", want: "en"},
+		{name: "invalid quality is 1", acceptLanguage: "en;q=0.5, zh;q=x", want: "zh"},
+		{name: "unsupported only", acceptLanguage: "fr-FR, de;q=0.9", want: "en"},
+		{name: "wildcard", acceptLanguage: "*", want: "en"},
+		{name: "empty parts", acceptLanguage: ", ,zh", want: "zh"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			context, _ := newTestContext(test.acceptLanguage)
+			if got := Language(context); got != test.want {
+				t.Errorf("Language() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestJobStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		code       int
+		wantStatus int
+	}{
+		{name: "photo in process", code: constant.PhotoAddInProcess, wantStatus: http.StatusOK},
+		{name: "photo uploaded", code: constant.PhotoUploadSuccess, wantStatus: http.StatusOK},
+		{name: "photo upload failed", code: constant.PhotoUploadError, wantStatus: http.StatusOK},
+		{name: "export in process", code: constant.BucketExportInProcess, wantStatus: http.StatusOK},
+		{name: "export done", code: constant.BucketExportSuccess, wantStatus: http.StatusOK},
+		{name: "export failed", code: constant.BucketExportError, wantStatus: http.StatusOK},
+		{name: "invalid params", code: constant.InvalidParams, wantStatus: http.StatusBadRequest},
+		{name: "not signed in", code: constant.UserAuthError, wantStatus: http.StatusUnauthorized},
+		{name: "no such export", code: constant.BucketExportNotExist, wantStatus: http.StatusNotFound},
+		{name: "server error", code: constant.InternalServerError, wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			context, recorder := newTestContext("zh-CN")
+			JobStatus(context, test.code, nil)
+
+			if recorder.Code != test.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, test.wantStatus)
+			}
+			if lang := recorder.Header().Get("Content-Language"); lang != "zh" {
+				t.Errorf("Content-Language = %q, want %q", lang, "zh")
+			}
+			body := struct {
+				Code int    `json:"code"`
+				Msg  string `json:"msg"`
+			}{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid body %q: %v", recorder.Body.String(), err)
+			}
+			if body.Code != test.code {
+				t.Errorf("code = %d, want %d", body.Code, test.code)
+			}
+			if want := constant.GetLocalizedMessage(test.code, "zh"); body.Msg != want {
+				t.Errorf("msg = %q, want %q", body.Msg, want)
+			}
+		})
+	}
+}
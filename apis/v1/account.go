@@ -0,0 +1,145 @@
+package v1
+
+import (
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+	"go.uber.org/zap"
+
+	"github.com/astaxie/beego/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walk1ng/gin-photo-gallery-storage/apis/response"
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/models"
+)
+
+// GetProfile func get the profile of the current user.
+func GetProfile(context *gin.Context) {
+	responseCode := constant.UserAuthError
+	data := make(map[string]interface{})
+
+	if auth, err := getCurrentAuth(context); err == nil {
+		responseCode = constant.UserGetSuccess
+		data["user"] = *auth
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// UpdateEmail func change the email of the current user and send a mail to verify it,
+// it is confirmed by the password or, for the users without one, a totp code or a recent oidc login.
+func UpdateEmail(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	email := context.PostForm("email")
+	password := context.PostForm("password")
+	code := context.PostForm("code")
+
+	validCheck := validation.Validation{}
+	validCheck.Required(email, "email").Message("must have email")
+	validCheck.MaxSize(email, 128, "email").Message("email can not exceed 128 chars")
+	validCheck.Email(email, "email").Message("email is invalid")
+
+	data := make(map[string]interface{})
+	if !validCheck.HasErrors() {
+		if context.GetBool("api_token") {
+			responseCode = constant.UserDenied
+		} else if auth, err := getCurrentAuth(context); err != nil {
+			responseCode = constant.UserAuthError
+		} else if auth, err = models.UpdateAuthEmail(context.Request.Context(), auth.ID, password, code, email); err != nil {
+			responseCode = getAccountErrorCode(err)
+		} else {
+			responseCode = constant.UserUpdateSuccess
+			data["user"] = *auth
+			if err := models.SendVerificationMail(context.Request.Context(), auth.UserName); err != nil {
+				utils.GetLogger(context).Warn(err.Error(), zap.String("service", "UpdateEmail()"))
+			}
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "UpdateEmail()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// ChangePassword func change the password of the current user, the user has to log in again.
+func ChangePassword(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	password := context.PostForm("password")
+	newPassword := context.PostForm("new_password")
+
+	validCheck := validation.Validation{}
+	validCheck.Required(password, "password").Message("must have password")
+	validCheck.Required(newPassword, "new_password").Message("must have new password")
+	validCheck.MaxSize(newPassword, 16, "new_password").Message("length of password cannot exceed 16")
+	validCheck.MinSize(newPassword, 6, "new_password").Message("length of password is at least 6")
+
+	if !validCheck.HasErrors() {
+		if context.GetBool("api_token") {
+			responseCode = constant.UserDenied
+		} else if auth, err := getCurrentAuth(context); err != nil {
+			responseCode = constant.UserAuthError
+		} else if _, err := models.ChangePassword(context.Request.Context(), auth.ID, password, newPassword); err != nil {
+			responseCode = getAccountErrorCode(err)
+		} else {
+			responseCode = constant.PasswordUpdateSuccess
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "ChangePassword()"))
+		}
+	}
+
+	response.JSON(context, responseCode, make(map[string]string))
+}
+
+// StartAccountExport func export everything the current user owns as a zip archive in the background,
+// it is followed by the export status and download endpoints. An api token cannot start it.
+func StartAccountExport(context *gin.Context) {
+	responseCode := constant.InternalServerError
+	data := make(map[string]interface{})
+
+	if context.GetBool("api_token") {
+		// a token must not carry away everything the user owns
+		responseCode = constant.UserDenied
+	} else if auth, err := getCurrentAuth(context); err != nil {
+		responseCode = constant.UserAuthError
+	} else if exportID, err := models.StartAccountExport(context.Request.Context(), auth); err != nil {
+		utils.GetLogger(context).Error(err.Error(), zap.String("service", "StartAccountExport()"))
+	} else {
+		responseCode = constant.BucketExportInProcess
+		data["export_id"] = exportID
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// DeleteAccount func permanently delete the current user and everything the user owns,
+// it is confirmed by the password or, for the users without one, a totp code or a recent oidc login.
+func DeleteAccount(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	password := context.PostForm("password")
+	code := context.PostForm("code")
+
+	if context.GetBool("api_token") {
+		responseCode = constant.UserDenied
+	} else if auth, err := getCurrentAuth(context); err != nil {
+		responseCode = constant.UserAuthError
+	} else if err := models.DeleteAuth(context.Request.Context(), auth.ID, password, code); err != nil {
+		responseCode = getAccountErrorCode(err)
+	} else {
+		responseCode = constant.UserDeleteSuccess
+	}
+
+	response.JSON(context, responseCode, make(map[string]string))
+}
+
+// getAccountErrorCode func map the errors of the account management to response codes
+func getAccountErrorCode(err error) int {
+	// the auth of a logged in user is missing only if it was deleted meanwhile
+	if err == models.ErrNoSuchAuth {
+		return constant.UserAuthError
+	}
+	return apperr.Code(err, constant.InternalServerError)
+}
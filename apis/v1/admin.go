@@ -0,0 +1,279 @@
+package v1
+
+import (
+	"strconv"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+	"go.uber.org/zap"
+
+	"github.com/astaxie/beego/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walk1ng/gin-photo-gallery-storage/apis/response"
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/models"
+)
+
+// GetUsers func get a page of all users, admin only.
+func GetUsers(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	offset := context.GetInt("offset")
+
+	validCheck := validation.Validation{}
+	validCheck.Min(offset, 0, "page_offset").Message("page offset must be >= 0")
+
+	data := make(map[string]interface{})
+	if !validCheck.HasErrors() {
+		if auths, err := models.GetAuths(context.Request.Context(), offset); err != nil {
+			utils.GetLogger(context).Error(err.Error(), zap.String("service", "GetUsers()"))
+			responseCode = constant.InternalServerError
+		} else {
+			responseCode = constant.UserGetSuccess
+			data["users"] = auths
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "GetUsers()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// SetUserState func enable or disable a user, admin only.
+func SetUserState(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	userID, err := strconv.Atoi(context.Query("user_id"))
+	state, stateErr := strconv.Atoi(context.Query("state"))
+	if err != nil || stateErr != nil {
+		if err != nil {
+			utils.GetLogger(context).Debug(err.Error(), zap.String("service", "SetUserState()"))
+		}
+		if stateErr != nil {
+			utils.GetLogger(context).Debug(stateErr.Error(), zap.String("service", "SetUserState()"))
+		}
+		response.Abort(context, responseCode)
+		return
+	}
+
+	validCheck := validation.Validation{}
+	validCheck.Min(userID, 1, "user_id").Message("user id should be positive")
+	validCheck.Range(state, 0, 1, "state").Message("state should be 0 or 1")
+
+	data := make(map[string]interface{})
+	data["user_id"] = userID
+	if !validCheck.HasErrors() {
+		if auth, err := models.SetAuthState(context.Request.Context(), uint(userID), state); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
+		} else {
+			responseCode = constant.UserUpdateSuccess
+			auth.State = state
+			data["user"] = *auth
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "SetUserState()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// SetUserQuota func set or clear the quotas of a user, admin only.
+// A missing or 0 quota falls back to the configured default and a negative quota means no limit.
+func SetUserQuota(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	userID, err := strconv.Atoi(context.Query("user_id"))
+	maxPhotos, photosErr := strconv.ParseInt(context.DefaultQuery("max_photos", "0"), 10, 64)
+	maxBytes, bytesErr := strconv.ParseInt(context.DefaultQuery("max_bytes", "0"), 10, 64)
+	if err != nil || photosErr != nil || bytesErr != nil {
+		for _, e := range []error{err, photosErr, bytesErr} {
+			if e != nil {
+				utils.GetLogger(context).Debug(e.Error(), zap.String("service", "SetUserQuota()"))
+			}
+		}
+		response.Abort(context, responseCode)
+		return
+	}
+
+	validCheck := validation.Validation{}
+	validCheck.Min(userID, 1, "user_id").Message("user id should be positive")
+
+	data := make(map[string]interface{})
+	data["user_id"] = userID
+	if !validCheck.HasErrors() {
+		if auth, err := models.SetAuthQuota(context.Request.Context(), uint(userID), maxPhotos, maxBytes); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
+		} else {
+			responseCode = constant.UserUpdateSuccess
+			data["user"] = *auth
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "SetUserQuota()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// SetUserRole func change the role of a user, admin only.
+func SetUserRole(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	userID, err := strconv.Atoi(context.Query("user_id"))
+	role := context.Query("role")
+	if err != nil {
+		utils.GetLogger(context).Debug(err.Error(), zap.String("service", "SetUserRole()"))
+		response.Abort(context, responseCode)
+		return
+	}
+
+	validCheck := validation.Validation{}
+	validCheck.Min(userID, 1, "user_id").Message("user id should be positive")
+	validCheck.Required(role, "role").Message("must have role")
+
+	data := make(map[string]interface{})
+	data["user_id"] = userID
+	data["role"] = role
+	if !validCheck.HasErrors() {
+		if auth, err := models.SetAuthRole(context.Request.Context(), uint(userID), role); err != nil {
+			if err == models.ErrNoSuchAuth {
+				responseCode = constant.UserNotExist
+			} else if err != models.ErrInvalidRole {
+				responseCode = constant.InternalServerError
+			}
+		} else {
+			responseCode = constant.UserUpdateSuccess
+			auth.Role = role
+			data["user"] = *auth
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "SetUserRole()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// UnlockUser func clear the failed logins and the lock of a user or an ip, admin only.
+func UnlockUser(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	userID, _ := strconv.Atoi(context.DefaultQuery("user_id", "0"))
+	ip := context.Query("ip")
+
+	validCheck := validation.Validation{}
+	validCheck.Min(userID, 0, "user_id").Message("user id should not be negative")
+	if ip != "" {
+		validCheck.IP(ip, "ip").Message("ip is invalid")
+	} else if userID == 0 {
+		validCheck.SetError("user_id", "must have user id or ip")
+	}
+
+	data := make(map[string]interface{})
+	data["user_id"] = userID
+	data["ip"] = ip
+	if !validCheck.HasErrors() {
+		responseCode = constant.UserUnlockSuccess
+		if userID > 0 {
+			if auth, err := models.GetAuthByID(context.Request.Context(), uint(userID)); err != nil {
+				responseCode = constant.UserNotExist
+			} else if !utils.ResetLoginFailures(context.Request.Context(), auth.UserName) {
+				responseCode = constant.InternalServerError
+			}
+		}
+		if ip != "" && responseCode == constant.UserUnlockSuccess && !utils.UnlockLoginIP(context.Request.Context(), ip) {
+			responseCode = constant.InternalServerError
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "UnlockUser()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// InspectBucket func get any bucket with its usage and a page of its photos, admin only.
+func InspectBucket(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	offset := context.GetInt("offset")
+	bucketID, err := strconv.Atoi(context.Query("bucket_id"))
+	if err != nil {
+		utils.GetLogger(context).Debug(err.Error(), zap.String("service", "InspectBucket()"))
+		response.Abort(context, responseCode)
+		return
+	}
+
+	validCheck := validation.Validation{}
+	validCheck.Min(bucketID, 1, "bucket_id").Message("bucket id should be positive")
+	validCheck.Min(offset, 0, "page_offset").Message("page offset must be >= 0")
+
+	data := make(map[string]interface{})
+	data["bucket_id"] = bucketID
+	if !validCheck.HasErrors() {
+		if detail, err := models.GetBucketDetail(context.Request.Context(), uint(bucketID), offset); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
+		} else {
+			responseCode = constant.BucketGetSuccess
+			data["detail"] = *detail
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "InspectBucket()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// GetSystemUsage func get the storage used by all users, admin only.
+func GetSystemUsage(context *gin.Context) {
+	responseCode := constant.InternalServerError
+	data := make(map[string]interface{})
+
+	if usage, err := models.GetSystemUsage(context.Request.Context()); err != nil {
+		utils.GetLogger(context).Error(err.Error(), zap.String("service", "GetSystemUsage()"))
+	} else {
+		responseCode = constant.UserUsageSuccess
+		data["usage"] = *usage
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// GetLogLevel func get the level of the app logger, admin only.
+func GetLogLevel(context *gin.Context) {
+	data := make(map[string]interface{})
+	data["level"] = utils.GetLogLevel()
+
+	response.JSON(context, constant.LogLevelGetSuccess, data)
+}
+
+// SetLogLevel func change the level of the app logger at runtime, admin only.
+func SetLogLevel(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	level := context.Query("level")
+
+	validCheck := validation.Validation{}
+	validCheck.Required(level, "level").Message("must have log level")
+
+	data := make(map[string]interface{})
+	if !validCheck.HasErrors() {
+		if err := utils.SetLogLevel(level); err != nil {
+			utils.GetLogger(context).Debug(err.Error(), zap.String("service", "SetLogLevel()"))
+		} else {
+			utils.GetLogger(context).Info("log level changed.", zap.String("service", "SetLogLevel()"),
+				zap.String("level", utils.GetLogLevel()))
+			responseCode = constant.LogLevelUpdateSuccess
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "SetLogLevel()"))
+		}
+	}
+	data["level"] = utils.GetLogLevel()
+
+	response.JSON(context, responseCode, data)
+}
@@ -0,0 +1,102 @@
+package v1
+
+import (
+	"strconv"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+	"go.uber.org/zap"
+
+	"github.com/astaxie/beego/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walk1ng/gin-photo-gallery-storage/apis/response"
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/models"
+)
+
+// AddAPIToken func create a personal api token of the user,
+// the token is only shown in this response.
+func AddAPIToken(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	name := context.PostForm("name")
+	scope := context.DefaultPostForm("scope", constant.TokenScopeRead)
+
+	validCheck := validation.Validation{}
+	validCheck.Required(name, "name").Message("must have token name")
+	validCheck.MaxSize(name, 64, "name").Message("length of token name cannot exceed 64")
+
+	data := make(map[string]interface{})
+	if !validCheck.HasErrors() {
+		if context.GetBool("api_token") {
+			// a token cannot be used to create more tokens
+			responseCode = constant.UserDenied
+		} else if auth, err := getCurrentAuth(context); err != nil {
+			responseCode = constant.UserAuthError
+		} else if apiToken, token, err := models.AddAPIToken(context.Request.Context(), auth.ID, name, scope); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
+		} else {
+			responseCode = constant.TokenAddSuccess
+			data["token"] = token
+			data["api_token"] = *apiToken
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "AddAPIToken()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// GetAPITokens func get the personal api tokens of the user, without the tokens themselves.
+func GetAPITokens(context *gin.Context) {
+	responseCode := constant.InternalServerError
+	data := make(map[string]interface{})
+
+	if auth, err := getCurrentAuth(context); err != nil {
+		responseCode = constant.UserAuthError
+	} else if tokens, err := models.GetAPITokens(context.Request.Context(), auth.ID); err != nil {
+		utils.GetLogger(context).Error(err.Error(), zap.String("service", "GetAPITokens()"))
+	} else {
+		responseCode = constant.TokenGetSuccess
+		data["api_tokens"] = tokens
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// RevokeAPIToken func revoke a personal api token of the user, it is only allowed by a login.
+func RevokeAPIToken(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	tokenID, err := strconv.Atoi(context.Query("token_id"))
+	if err != nil {
+		utils.GetLogger(context).Debug(err.Error(), zap.String("service", "RevokeAPIToken()"))
+		response.Abort(context, responseCode)
+		return
+	}
+
+	validCheck := validation.Validation{}
+	validCheck.Min(tokenID, 1, "token_id").Message("token id should be positive")
+
+	data := make(map[string]interface{})
+	data["token_id"] = tokenID
+	if !validCheck.HasErrors() {
+		if context.GetBool("api_token") {
+			// a token cannot be used to revoke the tokens, a read token could revoke a full one
+			responseCode = constant.UserDenied
+		} else if auth, err := getCurrentAuth(context); err != nil {
+			responseCode = constant.UserAuthError
+		} else if err := models.RevokeAPIToken(context.Request.Context(), auth.ID, uint(tokenID)); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
+		} else {
+			responseCode = constant.TokenRevokeSuccess
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "RevokeAPIToken()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
@@ -1,7 +1,9 @@
 package v1
 
 import (
-	"net/http"
+	"math"
+	"strconv"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -13,6 +15,7 @@ import (
 
 	"github.com/astaxie/beego/validation"
 	"github.com/gin-gonic/gin"
+	"github.com/walk1ng/gin-photo-gallery-storage/apis/response"
 	"github.com/walk1ng/gin-photo-gallery-storage/constant"
 )
 
@@ -32,25 +35,26 @@ func AddAuth(context *gin.Context) {
 	validCheck.MinSize(password, 6, "password").Message("length of password is at least 6")
 	validCheck.Required(email, "email").Message("must have email")
 	validCheck.MaxSize(email, 128, "email").Message("email can not exceed 128 chars")
+	validCheck.Email(email, "email").Message("email is invalid")
 
 	responseCode := constant.InvalidParams
 	if !validCheck.HasErrors() {
-		if err := models.AddAuth(userName, password, email); err == nil {
+		if err := models.AddAuth(context.Request.Context(), userName, password, email); err == nil {
 			responseCode = constant.UserAddSuccess
+			// the user can ask for another mail if this one fails
+			if err := models.SendVerificationMail(context.Request.Context(), userName); err != nil {
+				utils.GetLogger(context).Warn(err.Error(), zap.String("service", "AddAuth()"))
+			}
 		} else {
 			responseCode = constant.UserAlreadyExist
 		}
 	} else {
 		for _, e := range validCheck.Errors {
-			utils.AppLogger.Info(e.Message, zap.String("service", "AddAuth()"))
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "AddAuth()"))
 		}
 	}
 
-	context.JSON(http.StatusOK, gin.H{
-		"code": responseCode,
-		"data": userName,
-		"msg":  constant.GetMessage(responseCode),
-	})
+	response.JSON(context, responseCode, userName)
 }
 
 // CheckAuth func check if the auth is valid
@@ -69,37 +73,144 @@ func CheckAuth(context *gin.Context) {
 
 	responseCode := constant.InvalidParams
 
+	data := make(map[string]interface{})
+	data["user_name"] = userName
 	if !validCheck.HasErrors() {
-		if models.CheckAuth(userName, password) {
-			if jwtString, err := utils.GenerateJWT(userName); err != nil {
-				responseCode = constant.JwtGenerationError
-			} else {
-				// auth check is pass
-				// 1. set jwt to user's cookie
-				// 2. add user to redis
-				context.SetCookie(constant.Jwt, jwtString,
-					constant.CookieMaxAge,
-					conf.ServerCfg.Get(constant.ServerPath),
-					conf.ServerCfg.Get(constant.ServerDomain),
-					true, true)
-				if err := utils.AddAuthToRedis(userName); err != nil {
+		if lock := utils.GetLoginLock(context.Request.Context(), userName, context.ClientIP()); lock > 0 {
+			responseCode = constant.UserLocked
+			setRetryAfter(context, lock, data)
+		} else if auth, err := models.CheckAuth(context.Request.Context(), userName, password); err == nil {
+			if auth.TOTPEnabled {
+				// the login is pending until the second factor is verified
+				if mfaToken, err := utils.AddUserToken(context.Request.Context(), constant.MFATokenFormat, userName, constant.MFATokenMaxAge); err != nil {
 					responseCode = constant.InternalServerError
 				} else {
-					responseCode = constant.UserAuthSuccess
+					responseCode = constant.MFARequired
+					data["mfa_token"] = mfaToken
 				}
+			} else {
+				responseCode = startLogin(context, auth, data)
 			}
+		} else if err == models.ErrAuthDisabled {
+			responseCode = constant.UserDisabled
+		} else if err == models.ErrAuthUnverified {
+			responseCode = constant.UserUnverified
+		} else if lock := utils.AddLoginFailure(context.Request.Context(), userName, context.ClientIP()); lock > 0 {
+			responseCode = constant.UserLocked
+			setRetryAfter(context, lock, data)
 		} else {
 			responseCode = constant.UserAuthError
 		}
 	} else {
 		for _, e := range validCheck.Errors {
-			utils.AppLogger.Info(e.Message, zap.String("service", "CheckAuth()"))
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "CheckAuth()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// RefreshAuth func exchange a refresh token for a new jwt and the next refresh token,
+// the refresh token is read from the form or the cookie.
+func RefreshAuth(context *gin.Context) {
+	responseCode := constant.RefreshTokenError
+	refreshToken := context.PostForm(constant.RefreshToken)
+	if refreshToken == "" {
+		refreshToken, _ = context.Cookie(constant.RefreshToken)
+	}
+	if refreshToken == "" {
+		response.Abort(context, responseCode)
+		return
+	}
+
+	data := make(map[string]interface{})
+	userName, nextToken, err := utils.RotateRefreshToken(context.Request.Context(), refreshToken)
+	switch err {
+	case nil:
+		if auth, err := models.GetAuthByUserName(context.Request.Context(), userName); err != nil {
+			responseCode = constant.UserAuthError
+		} else if auth.State == 0 {
+			responseCode = constant.UserDisabled
+		} else if responseCode = setAuthTokens(context, auth, nextToken, data); responseCode == 0 {
+			responseCode = constant.JwtRefreshSuccess
 		}
+	case utils.ErrRefreshTokenReused:
+		// someone else holds a token of this login, sign the user out everywhere
+		utils.GetLogger(context).Warn(err.Error(), zap.String("service", "RefreshAuth()"), zap.String("user_name", userName))
+		utils.RemoveAuthFromRedis(context.Request.Context(), userName)
+		responseCode = constant.RefreshTokenReused
+	case utils.ErrRefreshTokenInvalid:
+		responseCode = constant.RefreshTokenError
+	default:
+		responseCode = constant.InternalServerError
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// startLogin func start a new login of the user which passed the auth check,
+// the login has its own refresh token family.
+func startLogin(context *gin.Context, auth *models.Auth, data map[string]interface{}) int {
+	utils.ResetLoginFailures(context.Request.Context(), auth.UserName)
+	refreshToken, err := utils.IssueRefreshToken(context.Request.Context(), auth.UserName, "")
+	if err != nil {
+		return constant.InternalServerError
 	}
+	if code := setAuthTokens(context, auth, refreshToken, data); code != 0 {
+		return code
+	}
+	return constant.UserAuthSuccess
+}
+
+// setAuthTokens func generate the jwt of the user and return it with the refresh token
+// in both the cookies and the data, the login is extended by the lifetime of the refresh token.
+// 0 is returned when all succeed, otherwise the response code of the failure.
+func setAuthTokens(context *gin.Context, auth *models.Auth, refreshToken string, data map[string]interface{}) int {
+	jwtString, err := utils.GenerateJWT(context.Request.Context(), auth.UserName, auth.Role)
+	if err != nil {
+		return constant.JwtGenerationError
+	}
+	if err := utils.AddAuthToRedis(context.Request.Context(), auth.UserName); err != nil {
+		return constant.InternalServerError
+	}
+
+	path := conf.ServerCfg.Get(constant.ServerPath)
+	domain := conf.ServerCfg.Get(constant.ServerDomain)
+	context.SetCookie(constant.Jwt, jwtString, constant.CookieMaxAge, path, domain, true, true)
+	context.SetCookie(constant.RefreshToken, refreshToken, constant.RefreshTokenMaxAge, path, domain, true, true)
+
+	data["user_name"] = auth.UserName
+	data["jwt"] = jwtString
+	data["expires_in"] = constant.JwtExpMinute * 60
+	data[constant.RefreshToken] = refreshToken
+	return 0
+}
+
+// GetAuthUsage func get the storage usage and quotas of the user and the user's buckets
+func GetAuthUsage(context *gin.Context) {
+	responseCode := constant.InternalServerError
+	data := make(map[string]interface{})
+
+	if auth, err := getCurrentAuth(context); err != nil {
+		responseCode = constant.UserAuthError
+	} else if usage, err := models.GetUsageByAuthID(context.Request.Context(), auth.ID); err != nil {
+		utils.GetLogger(context).Error(err.Error(), zap.String("service", "GetAuthUsage()"))
+	} else {
+		responseCode = constant.UserUsageSuccess
+		data["usage"] = *usage
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// setRetryAfter func tell the client how long to wait before trying again
+func setRetryAfter(context *gin.Context, wait time.Duration, data map[string]interface{}) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	context.Header("Retry-After", strconv.Itoa(seconds))
+	data["retry_after"] = seconds
+}
 
-	context.JSON(http.StatusOK, gin.H{
-		"code": responseCode,
-		"data": userName,
-		"msg":  constant.GetMessage(responseCode),
-	})
+// getCurrentAuth func get the auth of the user set by the auth middleware
+func getCurrentAuth(context *gin.Context) (*models.Auth, error) {
+	return models.GetAuthByUserName(context.Request.Context(), context.GetString("user_name"))
 }
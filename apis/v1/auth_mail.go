@@ -0,0 +1,119 @@
+package v1
+
+import (
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+	"go.uber.org/zap"
+
+	"github.com/astaxie/beego/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walk1ng/gin-photo-gallery-storage/apis/response"
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/models"
+)
+
+// VerifyEmail func verify the email of a user by the token sent at signup.
+func VerifyEmail(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	token := context.Query("token")
+
+	validCheck := validation.Validation{}
+	validCheck.Required(token, "token").Message("must have token")
+
+	data := make(map[string]interface{})
+	if !validCheck.HasErrors() {
+		if auth, err := models.VerifyEmail(context.Request.Context(), token); err != nil {
+			if err == models.ErrInvalidMailToken || err == models.ErrNoSuchAuth {
+				responseCode = constant.MailTokenInvalid
+			} else {
+				responseCode = apperr.Code(err, constant.InternalServerError)
+			}
+		} else {
+			responseCode = constant.UserVerifySuccess
+			data["user_name"] = auth.UserName
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "VerifyEmail()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// ResendVerification func send another verification mail to the current user.
+func ResendVerification(context *gin.Context) {
+	responseCode := constant.InternalServerError
+	userName := context.GetString("user_name")
+
+	if err := models.SendVerificationMail(context.Request.Context(), userName); err != nil {
+		if err == models.ErrAuthVerified {
+			responseCode = constant.UserVerified
+		} else if err == models.ErrNoSuchAuth {
+			responseCode = constant.UserAuthError
+		}
+	} else {
+		responseCode = constant.UserVerifyMailSent
+	}
+
+	response.JSON(context, responseCode, userName)
+}
+
+// RequestPasswordReset func send a password reset mail to the user of the email,
+// the response is the same whether the email is registered or not.
+func RequestPasswordReset(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	email := context.PostForm("email")
+
+	validCheck := validation.Validation{}
+	validCheck.Required(email, "email").Message("must have email")
+	validCheck.Email(email, "email").Message("email is invalid")
+
+	if !validCheck.HasErrors() {
+		if err := models.RequestPasswordReset(context.Request.Context(), email); err != nil {
+			responseCode = constant.InternalServerError
+		} else {
+			responseCode = constant.ResetMailSent
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "RequestPasswordReset()"))
+		}
+	}
+
+	response.JSON(context, responseCode, make(map[string]string))
+}
+
+// ResetPassword func set a new password by the token of a password reset mail.
+func ResetPassword(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	token := context.PostForm("token")
+	password := context.PostForm("password")
+
+	validCheck := validation.Validation{}
+	validCheck.Required(token, "token").Message("must have token")
+	validCheck.Required(password, "password").Message("must have password")
+	validCheck.MaxSize(password, 16, "password").Message("length of password cannot exceed 16")
+	validCheck.MinSize(password, 6, "password").Message("length of password is at least 6")
+
+	data := make(map[string]interface{})
+	if !validCheck.HasErrors() {
+		if auth, err := models.ResetPassword(context.Request.Context(), token, password); err != nil {
+			if err == models.ErrInvalidMailToken || err == models.ErrNoSuchAuth {
+				responseCode = constant.MailTokenInvalid
+			} else {
+				responseCode = constant.InternalServerError
+			}
+		} else {
+			responseCode = constant.ResetSuccess
+			data["user_name"] = auth.UserName
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "ResetPassword()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
@@ -0,0 +1,120 @@
+package v1
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+	"go.uber.org/zap"
+
+	"github.com/astaxie/beego/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walk1ng/gin-photo-gallery-storage/apis/response"
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/conf"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/models"
+)
+
+// GetOIDCProviders func get the names of the oidc providers which can be used to log in.
+func GetOIDCProviders(context *gin.Context) {
+	responseCode := constant.OIDCProvidersSuccess
+	data := make(map[string]interface{})
+	data["providers"] = utils.GetOIDCProviderNames()
+
+	response.JSON(context, responseCode, data)
+}
+
+// StartOIDCLogin func redirect the user to the authorization endpoint of an oidc provider,
+// the state is also set in a cookie to bind the login to the browser which started it.
+func StartOIDCLogin(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	providerName := context.Query("provider")
+
+	validCheck := validation.Validation{}
+	validCheck.Required(providerName, "provider").Message("must have provider")
+
+	data := make(map[string]interface{})
+	data["provider"] = providerName
+	if !validCheck.HasErrors() {
+		if provider, err := utils.GetOIDCProvider(providerName); err != nil {
+			responseCode = constant.OIDCProviderNotExist
+		} else if authURL, state, err := provider.StartOIDCLogin(context.Request.Context()); err != nil {
+			responseCode = constant.OIDCLoginError
+		} else {
+			path := conf.ServerCfg.Get(constant.ServerPath)
+			domain := conf.ServerCfg.Get(constant.ServerDomain)
+			context.SetCookie(constant.OIDCStateCookie, state, constant.OIDCStateMaxAge, path, domain, true, true)
+			context.Redirect(http.StatusFound, authURL)
+			return
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "StartOIDCLogin()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// FinishOIDCLogin func log in the user of the oidc identity the provider redirected back with,
+// the state must match the cookie of the browser which started the login.
+// The users with totp still need to complete the login with a code.
+func FinishOIDCLogin(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	state := context.Query("state")
+	code := context.Query("code")
+	stateCookie, _ := context.Cookie(constant.OIDCStateCookie)
+
+	path := conf.ServerCfg.Get(constant.ServerPath)
+	domain := conf.ServerCfg.Get(constant.ServerDomain)
+	context.SetCookie(constant.OIDCStateCookie, "", -1, path, domain, true, true)
+
+	validCheck := validation.Validation{}
+	validCheck.Required(state, "state").Message("must have state")
+	if providerError := context.Query("error"); providerError != "" {
+		validCheck.SetError("error", providerError)
+	} else {
+		validCheck.Required(code, "code").Message("must have code")
+	}
+
+	data := make(map[string]interface{})
+	if !validCheck.HasErrors() {
+		if subtle.ConstantTimeCompare([]byte(state), []byte(stateCookie)) != 1 {
+			utils.GetLogger(context).Warn("oidc state does not match the cookie.", zap.String("service", "FinishOIDCLogin()"))
+			responseCode = constant.OIDCLoginError
+		} else if login, err := utils.PopOIDCLogin(context.Request.Context(), state); err != nil {
+			responseCode = constant.OIDCLoginError
+		} else if provider, err := utils.GetOIDCProvider(login.Provider); err != nil {
+			responseCode = constant.OIDCProviderNotExist
+		} else if claims, err := provider.FinishOIDCLogin(login, code); err != nil {
+			responseCode = constant.OIDCLoginError
+		} else if auth, err := models.LoginOIDC(context.Request.Context(), provider.Name, claims); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
+		} else if auth.TOTPEnabled {
+			data["user_name"] = auth.UserName
+			if mfaToken, err := utils.AddUserToken(context.Request.Context(), constant.MFATokenFormat, auth.UserName, constant.MFATokenMaxAge); err != nil {
+				responseCode = constant.InternalServerError
+			} else {
+				responseCode = constant.MFARequired
+				data["mfa_token"] = mfaToken
+			}
+		} else {
+			responseCode = startLogin(context, auth, data)
+			if auth.Password == "" && responseCode == constant.UserAuthSuccess {
+				// the fresh login confirms the account changes of the user without a password
+				utils.MarkOIDCReauth(context.Request.Context(), auth.UserName)
+			}
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "FinishOIDCLogin()"))
+		}
+		if context.Query("error") != "" {
+			responseCode = constant.OIDCLoginError
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
@@ -0,0 +1,152 @@
+package v1
+
+import (
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+	"go.uber.org/zap"
+
+	"github.com/astaxie/beego/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walk1ng/gin-photo-gallery-storage/apis/response"
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/models"
+)
+
+// VerifyMFA func complete a pending login with a totp or recovery code,
+// the failed codes count as failed logins of the user.
+func VerifyMFA(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	mfaToken := context.PostForm("mfa_token")
+	code := context.PostForm("code")
+
+	validCheck := validation.Validation{}
+	validCheck.Required(mfaToken, "mfa_token").Message("must have mfa token")
+	validCheck.Required(code, "code").Message("must have code")
+
+	data := make(map[string]interface{})
+	if !validCheck.HasErrors() {
+		userName, err := utils.GetUserToken(context.Request.Context(), constant.MFATokenFormat, mfaToken)
+		if err != nil {
+			responseCode = constant.InternalServerError
+		} else if userName == "" {
+			responseCode = constant.MFACodeInvalid
+		} else if lock := utils.GetLoginLock(context.Request.Context(), userName, context.ClientIP()); lock > 0 {
+			responseCode = constant.UserLocked
+			setRetryAfter(context, lock, data)
+		} else if auth, err := models.GetAuthByUserName(context.Request.Context(), userName); err != nil {
+			responseCode = constant.UserAuthError
+		} else if auth.State == 0 {
+			responseCode = constant.UserDisabled
+		} else if err := models.CheckSecondFactor(context.Request.Context(), auth, code); err != nil {
+			if err != models.ErrInvalidTOTPCode && err != models.ErrTOTPNotEnabled {
+				responseCode = constant.InternalServerError
+			} else if lock := utils.AddLoginFailure(context.Request.Context(), userName, context.ClientIP()); lock > 0 {
+				responseCode = constant.UserLocked
+				setRetryAfter(context, lock, data)
+			} else {
+				responseCode = constant.MFACodeInvalid
+			}
+		} else if poppedName, err := utils.PopUserToken(context.Request.Context(), constant.MFATokenFormat, mfaToken); err != nil || poppedName != userName {
+			// the pending login was completed by another request
+			responseCode = constant.MFACodeInvalid
+		} else {
+			responseCode = startLogin(context, auth, data)
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "VerifyMFA()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// EnrollMFA func generate a totp secret of the current user with its provisioning uri.
+func EnrollMFA(context *gin.Context) {
+	responseCode := constant.InternalServerError
+	data := make(map[string]interface{})
+
+	if context.GetBool("api_token") {
+		responseCode = constant.UserDenied
+	} else if auth, err := getCurrentAuth(context); err != nil {
+		responseCode = constant.UserAuthError
+	} else if secret, uri, err := models.EnrollTOTP(context.Request.Context(), auth.ID); err != nil {
+		if err == models.ErrTOTPEnabled {
+			responseCode = constant.MFAEnabled
+		} else {
+			utils.GetLogger(context).Error(err.Error(), zap.String("service", "EnrollMFA()"))
+		}
+	} else {
+		responseCode = constant.MFAEnrollSuccess
+		data["secret"] = secret
+		data["uri"] = uri
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// ConfirmMFA func enable totp of the current user by a code of the enrolled secret.
+func ConfirmMFA(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	code := context.PostForm("code")
+
+	validCheck := validation.Validation{}
+	validCheck.Required(code, "code").Message("must have code")
+
+	data := make(map[string]interface{})
+	if !validCheck.HasErrors() {
+		if context.GetBool("api_token") {
+			responseCode = constant.UserDenied
+		} else if auth, err := getCurrentAuth(context); err != nil {
+			responseCode = constant.UserAuthError
+		} else if recoveryCodes, err := models.ConfirmTOTP(context.Request.Context(), auth.ID, code); err != nil {
+			responseCode = getMFAErrorCode(err)
+		} else {
+			responseCode = constant.MFAEnableSuccess
+			data["recovery_codes"] = recoveryCodes
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "ConfirmMFA()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// DisableMFA func disable totp of the current user by a totp or recovery code.
+func DisableMFA(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	code := context.PostForm("code")
+
+	validCheck := validation.Validation{}
+	validCheck.Required(code, "code").Message("must have code")
+
+	if !validCheck.HasErrors() {
+		if context.GetBool("api_token") {
+			responseCode = constant.UserDenied
+		} else if auth, err := getCurrentAuth(context); err != nil {
+			responseCode = constant.UserAuthError
+		} else if err := models.DisableTOTP(context.Request.Context(), auth.ID, code); err != nil {
+			responseCode = getMFAErrorCode(err)
+		} else {
+			responseCode = constant.MFADisableSuccess
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "DisableMFA()"))
+		}
+	}
+
+	response.JSON(context, responseCode, make(map[string]string))
+}
+
+// getMFAErrorCode func map the errors of totp to response codes
+func getMFAErrorCode(err error) int {
+	// the auth of a logged in user is missing only if it was deleted meanwhile
+	if err == models.ErrNoSuchAuth {
+		return constant.UserAuthError
+	}
+	return apperr.Code(err, constant.InternalServerError)
+}
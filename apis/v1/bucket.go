@@ -1,7 +1,6 @@
 package v1
 
 import (
-	"net/http"
 	"strconv"
 
 	"github.com/walk1ng/gin-photo-gallery-storage/utils"
@@ -11,6 +10,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
+	"github.com/walk1ng/gin-photo-gallery-storage/apis/response"
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
 	"github.com/walk1ng/gin-photo-gallery-storage/constant"
 	"github.com/walk1ng/gin-photo-gallery-storage/models"
 )
@@ -20,44 +21,37 @@ func AddBucket(context *gin.Context) {
 	responseCode := constant.InvalidParams
 	bucketToAdd := models.Bucket{}
 	if err := context.ShouldBindWith(&bucketToAdd, binding.Form); err != nil {
-		utils.AppLogger.Info(err.Error(), zap.String("service", "AddBucket()"))
-		context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"code": responseCode,
-			"data": make(map[string]string),
-			"msg":  constant.GetMessage(responseCode),
-		})
+		utils.GetLogger(context).Debug(err.Error(), zap.String("service", "AddBucket()"))
+		response.Abort(context, responseCode)
 		return
 	}
 
 	validCheck := validation.Validation{}
-	validCheck.Required(bucketToAdd.AuthID, "auth_id").Message("must have auth id")
 	validCheck.Required(bucketToAdd.Name, "bucket_name").Message("must have bucket name")
 	validCheck.MaxSize(bucketToAdd.Name, 64, "bucket_name").Message("length of bucket name cannot exceed 64")
 
 	if !validCheck.HasErrors() {
-		if err := models.AddBucket(&bucketToAdd); err != nil {
-			if err == models.ErrBucketExists {
-				responseCode = constant.BucketAlreadyExist
+		if auth, err := getCurrentAuth(context); err != nil {
+			responseCode = constant.UserAuthError
+		} else {
+			// the bucket always belongs to the current user
+			bucketToAdd.AuthID = auth.ID
+			if err := models.AddBucket(context.Request.Context(), &bucketToAdd); err != nil {
+				responseCode = apperr.Code(err, constant.InternalServerError)
 			} else {
-				responseCode = constant.InternalServerError
+				responseCode = constant.BucketAddSuccess
 			}
-		} else {
-			responseCode = constant.BucketAddSuccess
 		}
 	} else {
 		for _, e := range validCheck.Errors {
-			utils.AppLogger.Info(e.Message, zap.String("service", "AddBucket()"))
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "AddBucket()"))
 		}
 	}
 
 	data := make(map[string]string)
 	data["bucket_name"] = bucketToAdd.Name
 
-	context.JSON(http.StatusOK, gin.H{
-		"code": responseCode,
-		"data": data,
-		"msg":  constant.GetMessage(responseCode),
-	})
+	response.JSON(context, responseCode, data)
 }
 
 // DeleteBucket func delete an exist bucket.
@@ -65,12 +59,8 @@ func DeleteBucket(context *gin.Context) {
 	responseCode := constant.InvalidParams
 	bucketID, err := strconv.Atoi(context.Query("bucket_id"))
 	if err != nil {
-		utils.AppLogger.Info(err.Error(), zap.String("service", "DeleteBucket()"))
-		context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"code": responseCode,
-			"data": make(map[string]string),
-			"msg":  constant.GetMessage(responseCode),
-		})
+		utils.GetLogger(context).Debug(err.Error(), zap.String("service", "DeleteBucket()"))
+		response.Abort(context, responseCode)
 		return
 	}
 
@@ -79,28 +69,22 @@ func DeleteBucket(context *gin.Context) {
 	validCheck.Min(bucketID, 1, "bucket_id").Message("bucket id should be positive")
 
 	if !validCheck.HasErrors() {
-		if err := models.DeleteBucket(uint(bucketID)); err != nil {
-			if err == models.ErrNoSuchBucket {
-				responseCode = constant.BucketNotExist
-			} else {
-				responseCode = constant.InternalServerError
-			}
+		if _, code := checkBucketAccess(context, uint(bucketID), constant.PermissionOwner); code != 0 {
+			responseCode = code
+		} else if err := models.DeleteBucket(context.Request.Context(), uint(bucketID)); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
 		} else {
 			responseCode = constant.BucketDeleteSuccess
 		}
 	} else {
 		for _, e := range validCheck.Errors {
-			utils.AppLogger.Info(e.Message, zap.String("service", "DeleteBucket()"))
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "DeleteBucket()"))
 		}
 	}
 
 	data := make(map[string]interface{})
 	data["bucket_id"] = bucketID
-	context.JSON(http.StatusOK, gin.H{
-		"code": responseCode,
-		"data": data,
-		"msg":  constant.GetMessage(responseCode),
-	})
+	response.JSON(context, responseCode, data)
 }
 
 // UpdateBucket func to update an existed bucket.
@@ -108,12 +92,8 @@ func UpdateBucket(context *gin.Context) {
 	responseCode := constant.InvalidParams
 	bucketToUpdate := models.Bucket{}
 	if err := context.ShouldBindWith(&bucketToUpdate, binding.Form); err != nil {
-		utils.AppLogger.Info(err.Error(), zap.String("service", "UpdateBucket()"))
-		context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"code": responseCode,
-			"data": make(map[string]string),
-			"msg":  constant.GetMessage(responseCode),
-		})
+		utils.GetLogger(context).Debug(err.Error(), zap.String("service", "UpdateBucket()"))
+		response.Abort(context, responseCode)
 		return
 	}
 
@@ -122,28 +102,22 @@ func UpdateBucket(context *gin.Context) {
 	validCheck.MaxSize(bucketToUpdate.Name, 64, "bucket_name").Message("name of bucket cannot exceed 64")
 
 	if !validCheck.HasErrors() {
-		if err := models.UpdateBucket(&bucketToUpdate); err != nil {
-			if err == models.ErrNoSuchBucket {
-				responseCode = constant.BucketNotExist
-			} else {
-				responseCode = constant.InternalServerError
-			}
+		if bucket, code := checkBucketAccess(context, bucketToUpdate.ID, constant.PermissionEditor); code != 0 {
+			responseCode = code
+		} else if err := models.UpdateBucket(context.Request.Context(), restrictBucketUpdate(context, bucket, &bucketToUpdate)); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
 		} else {
 			responseCode = constant.BucketUpdateSuccess
 		}
 	} else {
 		for _, e := range validCheck.Errors {
-			utils.AppLogger.Info(e.Message, zap.String("service", "UpdateBucket()"))
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "UpdateBucket()"))
 		}
 	}
 
 	data := make(map[string]interface{})
 	data["bucket_id"] = bucketToUpdate.ID
-	context.JSON(http.StatusOK, gin.H{
-		"code": responseCode,
-		"data": data,
-		"msg":  constant.GetMessage(responseCode),
-	})
+	response.JSON(context, responseCode, data)
 }
 
 // GetBucketByID func get bucket by its ID.
@@ -151,12 +125,8 @@ func GetBucketByID(context *gin.Context) {
 	responseCode := constant.InvalidParams
 	bucketID, err := strconv.Atoi(context.Query("bucket_id"))
 	if err != nil {
-		utils.AppLogger.Info(err.Error(), zap.String("service", "GetBucketByID()"))
-		context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"code": responseCode,
-			"data": make(map[string]string),
-			"msg":  constant.GetMessage(responseCode),
-		})
+		utils.GetLogger(context).Debug(err.Error(), zap.String("service", "GetBucketByID()"))
+		response.Abort(context, responseCode)
 		return
 	}
 
@@ -166,27 +136,19 @@ func GetBucketByID(context *gin.Context) {
 
 	data := make(map[string]interface{})
 	if !validCheck.HasErrors() {
-		if bucket, err := models.GetBucketByID(uint(bucketID)); err != nil {
-			if err == models.ErrNoSuchBucket {
-				responseCode = constant.BucketNotExist
-			} else {
-				responseCode = constant.InternalServerError
-			}
+		if bucket, code := checkBucketAccess(context, uint(bucketID), constant.PermissionViewer); code != 0 {
+			responseCode = code
 		} else {
 			responseCode = constant.BucketGetSuccess
-			data["bucket"] = bucket
+			data["bucket"] = *bucket
 		}
 	} else {
 		for _, e := range validCheck.Errors {
-			utils.AppLogger.Info(e.Message, zap.String("service", "GetBucketByID()"))
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "GetBucketByID()"))
 		}
 	}
 
-	context.JSON(http.StatusOK, gin.H{
-		"code": responseCode,
-		"data": data,
-		"msg":  constant.GetMessage(responseCode),
-	})
+	response.JSON(context, responseCode, data)
 }
 
 // GetBucketByAuthID func get buckets by auth id.
@@ -195,12 +157,8 @@ func GetBucketByAuthID(context *gin.Context) {
 	authID, err := strconv.Atoi(context.Query("auth_id"))
 	offset := context.GetInt("offset")
 	if err != nil {
-		utils.AppLogger.Info(err.Error(), zap.String("service", "GetBucketByAuthID()"))
-		context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"code": responseCode,
-			"data": make(map[string]string),
-			"msg":  constant.GetMessage(responseCode),
-		})
+		utils.GetLogger(context).Debug(err.Error(), zap.String("service", "GetBucketByAuthID()"))
+		response.Abort(context, responseCode)
 		return
 	}
 
@@ -211,7 +169,9 @@ func GetBucketByAuthID(context *gin.Context) {
 
 	data := make(map[string]interface{})
 	if !validCheck.HasErrors() {
-		if buckets, err := models.GetBucketByAuthID(uint(authID), offset); err != nil {
+		if auth, err := getCurrentAuth(context); err != nil {
+			responseCode = constant.UserAuthError
+		} else if buckets, err := models.GetBucketByAuthID(context.Request.Context(), uint(authID), auth.ID, offset); err != nil {
 			responseCode = constant.InternalServerError
 		} else {
 			responseCode = constant.BucketGetSuccess
@@ -219,14 +179,22 @@ func GetBucketByAuthID(context *gin.Context) {
 		}
 	} else {
 		for _, e := range validCheck.Errors {
-			utils.AppLogger.Info(e.Message, zap.String("service", "GetBucketByAuthID()"))
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "GetBucketByAuthID()"))
 		}
 	}
 
-	context.JSON(http.StatusOK, gin.H{
-		"code": responseCode,
-		"data": data,
-		"msg":  constant.GetMessage(responseCode),
-	})
+	response.JSON(context, responseCode, data)
 
 }
+
+// restrictBucketUpdate func drop the fields of the bucket the current user cannot update,
+// the owner cannot be changed and only the owner sets the quotas.
+func restrictBucketUpdate(context *gin.Context, bucket *models.Bucket, bucketToUpdate *models.Bucket) *models.Bucket {
+	bucketToUpdate.AuthID = 0
+	if auth, err := getCurrentAuth(context); err != nil || auth.ID != bucket.AuthID {
+		bucketToUpdate.MaxPhotos = 0
+		bucketToUpdate.MaxBytes = 0
+		bucketToUpdate.MaxVersions = 0
+	}
+	return bucketToUpdate
+}
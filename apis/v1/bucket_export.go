@@ -0,0 +1,139 @@
+package v1
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+	"go.uber.org/zap"
+
+	"github.com/astaxie/beego/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walk1ng/gin-photo-gallery-storage/apis/response"
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/models"
+)
+
+// ExportBucket func stream the photos of a bucket as a zip archive.
+func ExportBucket(context *gin.Context) {
+	bucket, responseCode := getExportBucket(context, "ExportBucket()")
+	if bucket == nil {
+		response.JSON(context, responseCode, make(map[string]string))
+		return
+	}
+
+	context.Header("Content-Type", "application/zip")
+	context.Header("Content-Disposition", mime.FormatMediaType("attachment",
+		map[string]string{"filename": fmt.Sprintf("%s.zip", bucket.Name)}))
+	context.Status(http.StatusOK)
+
+	// the response is already started, an error can only cut the archive short
+	if err := models.WriteBucketArchive(context.Request.Context(), context.Writer, bucket, context.Query("tag")); err != nil {
+		utils.GetLogger(context).Debug(err.Error(), zap.String("service", "ExportBucket()"))
+		context.Abort()
+	}
+}
+
+// StartBucketExport func export a bucket as a zip archive in the background.
+func StartBucketExport(context *gin.Context) {
+	bucket, responseCode := getExportBucket(context, "StartBucketExport()")
+
+	data := make(map[string]interface{})
+	if bucket != nil {
+		if exportID, err := models.StartBucketExport(context.Request.Context(), context.GetString("user_name"), bucket, context.Query("tag")); err != nil {
+			responseCode = constant.InternalServerError
+		} else {
+			responseCode = constant.BucketExportInProcess
+			data["export_id"] = exportID
+			data["bucket_id"] = bucket.ID
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// GetBucketExportStatus func get the status of a background bucket export.
+func GetBucketExportStatus(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	exportID := context.Query("export_id")
+
+	validCheck := validation.Validation{}
+	validCheck.Required(exportID, "export_id").Message("must have export id")
+
+	data := make(map[string]interface{})
+	data["export_id"] = exportID
+
+	if !validCheck.HasErrors() {
+		if export, err := models.GetBucketExport(context.Request.Context(), exportID, context.GetString("user_name")); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
+		} else {
+			responseCode = export.Code
+			data["export"] = *export
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "GetBucketExportStatus()"))
+		}
+	}
+
+	response.JobStatus(context, responseCode, data)
+}
+
+// DownloadBucketExport func download the archive of a finished background bucket export.
+func DownloadBucketExport(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	exportID := context.Query("export_id")
+
+	validCheck := validation.Validation{}
+	validCheck.Required(exportID, "export_id").Message("must have export id")
+
+	data := make(map[string]interface{})
+	data["export_id"] = exportID
+
+	if !validCheck.HasErrors() {
+		if export, err := models.GetBucketExport(context.Request.Context(), exportID, context.GetString("user_name")); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
+		} else if export.Code != constant.BucketExportSuccess {
+			responseCode = export.Code
+		} else if err := serveBlob(context, export.BlobName, fmt.Sprintf("export_%s.zip", exportID), true); err == nil {
+			return
+		} else {
+			responseCode = constant.InternalServerError
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "DownloadBucketExport()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// getExportBucket func get the bucket of the user to export from the bucket_id query,
+// the response code is returned when the bucket cannot be exported.
+func getExportBucket(context *gin.Context, service string) (*models.Bucket, int) {
+	bucketID, err := strconv.Atoi(context.Query("bucket_id"))
+	if err != nil {
+		utils.GetLogger(context).Debug(err.Error(), zap.String("service", service))
+		return nil, constant.InvalidParams
+	}
+
+	validCheck := validation.Validation{}
+	validCheck.Min(bucketID, 1, "bucket_id").Message("bucket id should be positive")
+	if validCheck.HasErrors() {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", service))
+		}
+		return nil, constant.InvalidParams
+	}
+
+	bucket, code := checkBucketAccess(context, uint(bucketID), constant.PermissionViewer)
+	if code != 0 {
+		return nil, code
+	}
+	return bucket, constant.BucketExportSuccess
+}
@@ -0,0 +1,235 @@
+package v1
+
+import (
+	"strconv"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+	"go.uber.org/zap"
+
+	"github.com/astaxie/beego/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walk1ng/gin-photo-gallery-storage/apis/response"
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/models"
+)
+
+// AddCollaborator func share a bucket with a user, owner only.
+func AddCollaborator(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	bucketID, err := strconv.Atoi(context.PostForm("bucket_id"))
+	userName := context.PostForm("user_name")
+	permission := context.PostForm("permission")
+	if err != nil {
+		utils.GetLogger(context).Debug(err.Error(), zap.String("service", "AddCollaborator()"))
+		response.Abort(context, responseCode)
+		return
+	}
+
+	validCheck := validation.Validation{}
+	validCheck.Min(bucketID, 1, "bucket_id").Message("bucket id should be positive")
+	validCheck.Required(userName, "user_name").Message("must have user name")
+	validCheck.Required(permission, "permission").Message("must have permission")
+
+	data := make(map[string]interface{})
+	data["bucket_id"] = bucketID
+	data["user_name"] = userName
+	if !validCheck.HasErrors() {
+		if _, code := checkBucketAccess(context, uint(bucketID), constant.PermissionOwner); code != 0 {
+			responseCode = code
+		} else if collaborator, err := models.AddCollaborator(context.Request.Context(), uint(bucketID), userName, permission); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
+		} else {
+			responseCode = constant.CollaboratorSuccess
+			data["collaborator"] = *collaborator
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "AddCollaborator()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// UpdateCollaborator func change the permission of a collaborator, owner only.
+func UpdateCollaborator(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	bucketID, err := strconv.Atoi(context.PostForm("bucket_id"))
+	userID, userErr := strconv.Atoi(context.PostForm("user_id"))
+	permission := context.PostForm("permission")
+	if err != nil || userErr != nil {
+		if err != nil {
+			utils.GetLogger(context).Debug(err.Error(), zap.String("service", "UpdateCollaborator()"))
+		}
+		if userErr != nil {
+			utils.GetLogger(context).Debug(userErr.Error(), zap.String("service", "UpdateCollaborator()"))
+		}
+		response.Abort(context, responseCode)
+		return
+	}
+
+	validCheck := validation.Validation{}
+	validCheck.Min(bucketID, 1, "bucket_id").Message("bucket id should be positive")
+	validCheck.Min(userID, 1, "user_id").Message("user id should be positive")
+	validCheck.Required(permission, "permission").Message("must have permission")
+
+	data := make(map[string]interface{})
+	data["bucket_id"] = bucketID
+	data["user_id"] = userID
+	if !validCheck.HasErrors() {
+		if _, code := checkBucketAccess(context, uint(bucketID), constant.PermissionOwner); code != 0 {
+			responseCode = code
+		} else if collaborator, err := models.UpdateCollaborator(context.Request.Context(), uint(bucketID), uint(userID), permission); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
+		} else {
+			responseCode = constant.CollaboratorSuccess
+			collaborator.Permission = permission
+			data["collaborator"] = *collaborator
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "UpdateCollaborator()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// RemoveCollaborator func stop sharing a bucket with a user,
+// the owner can remove anyone and a collaborator can leave the bucket.
+func RemoveCollaborator(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	bucketID, err := strconv.Atoi(context.Query("bucket_id"))
+	userID, userErr := strconv.Atoi(context.Query("user_id"))
+	if err != nil || userErr != nil {
+		if err != nil {
+			utils.GetLogger(context).Debug(err.Error(), zap.String("service", "RemoveCollaborator()"))
+		}
+		if userErr != nil {
+			utils.GetLogger(context).Debug(userErr.Error(), zap.String("service", "RemoveCollaborator()"))
+		}
+		response.Abort(context, responseCode)
+		return
+	}
+
+	validCheck := validation.Validation{}
+	validCheck.Min(bucketID, 1, "bucket_id").Message("bucket id should be positive")
+	validCheck.Min(userID, 1, "user_id").Message("user id should be positive")
+
+	data := make(map[string]interface{})
+	data["bucket_id"] = bucketID
+	data["user_id"] = userID
+	if !validCheck.HasErrors() {
+		permission := constant.PermissionOwner
+		if auth, err := getCurrentAuth(context); err == nil && auth.ID == uint(userID) {
+			permission = constant.PermissionViewer
+		}
+
+		if _, code := checkBucketAccess(context, uint(bucketID), permission); code != 0 {
+			responseCode = code
+		} else if err := models.RemoveCollaborator(context.Request.Context(), uint(bucketID), uint(userID)); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
+		} else {
+			responseCode = constant.CollaboratorSuccess
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "RemoveCollaborator()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// GetCollaborators func get the collaborators of a bucket the user can see.
+func GetCollaborators(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	bucketID, err := strconv.Atoi(context.Query("bucket_id"))
+	if err != nil {
+		utils.GetLogger(context).Debug(err.Error(), zap.String("service", "GetCollaborators()"))
+		response.Abort(context, responseCode)
+		return
+	}
+
+	validCheck := validation.Validation{}
+	validCheck.Min(bucketID, 1, "bucket_id").Message("bucket id should be positive")
+
+	data := make(map[string]interface{})
+	data["bucket_id"] = bucketID
+	if !validCheck.HasErrors() {
+		if _, code := checkBucketAccess(context, uint(bucketID), constant.PermissionViewer); code != 0 {
+			responseCode = code
+		} else if collaborators, err := models.GetCollaborators(context.Request.Context(), uint(bucketID)); err != nil {
+			utils.GetLogger(context).Error(err.Error(), zap.String("service", "GetCollaborators()"))
+			responseCode = constant.InternalServerError
+		} else {
+			responseCode = constant.CollaboratorSuccess
+			data["collaborators"] = collaborators
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "GetCollaborators()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// GetSharedBuckets func get the buckets other users share with the user.
+func GetSharedBuckets(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	offset := context.GetInt("offset")
+
+	validCheck := validation.Validation{}
+	validCheck.Min(offset, 0, "page_offset").Message("page offset must be >= 0")
+
+	data := make(map[string]interface{})
+	if !validCheck.HasErrors() {
+		if auth, err := getCurrentAuth(context); err != nil {
+			responseCode = constant.UserAuthError
+		} else if buckets, err := models.GetSharedBuckets(context.Request.Context(), auth.ID, offset); err != nil {
+			responseCode = constant.InternalServerError
+		} else {
+			responseCode = constant.BucketGetSuccess
+			data["buckets"] = buckets
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "GetSharedBuckets()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// checkBucketAccess func get the bucket if the current user has the permission on it,
+// otherwise the response code of the failure is returned.
+func checkBucketAccess(context *gin.Context, bucketID uint, permission string) (*models.Bucket, int) {
+	auth, err := getCurrentAuth(context)
+	if err != nil {
+		return nil, constant.UserAuthError
+	}
+
+	bucket, err := models.GetBucketAccess(context.Request.Context(), bucketID, auth.ID, permission)
+	if err != nil {
+		return nil, apperr.Code(err, constant.InternalServerError)
+	}
+	return bucket, 0
+}
+
+// checkPhotoAccess func get the photo if the current user has the permission on its bucket,
+// otherwise the response code of the failure is returned.
+func checkPhotoAccess(context *gin.Context, photoID uint, permission string) (*models.Photo, int) {
+	auth, err := getCurrentAuth(context)
+	if err != nil {
+		return nil, constant.UserAuthError
+	}
+
+	photo, err := models.GetPhotoAccess(context.Request.Context(), photoID, auth.ID, permission)
+	if err != nil {
+		return nil, apperr.Code(err, constant.InternalServerError)
+	}
+	return photo, 0
+}
@@ -0,0 +1,24 @@
+package v1
+
+import (
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+	"go.uber.org/zap"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walk1ng/gin-photo-gallery-storage/apis/response"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+)
+
+// GetJWKS func get the public keys which verify the jwt, in the standard jwks format
+// so other services can verify the jwt without any secret.
+func GetJWKS(context *gin.Context) {
+	jwks, err := utils.GetJWKS(context.Request.Context())
+	if err != nil {
+		utils.GetLogger(context).Error(err.Error(), zap.String("service", "GetJWKS()"))
+		response.JSON(context, constant.InternalServerError, make(map[string]string))
+		return
+	}
+
+	context.Header("Cache-Control", "public, max-age=300")
+	response.Document(context, jwks)
+}
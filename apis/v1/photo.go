@@ -1,7 +1,9 @@
 package v1
 
 import (
+	"mime"
 	"net/http"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -12,6 +14,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
+	"github.com/walk1ng/gin-photo-gallery-storage/apis/response"
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
 	"github.com/walk1ng/gin-photo-gallery-storage/constant"
 	"github.com/walk1ng/gin-photo-gallery-storage/models"
 )
@@ -23,25 +27,20 @@ func AddPhoto(context *gin.Context) {
 
 	photoFile, fileErr := context.FormFile("photo")
 	if fileErr != nil {
-		utils.AppLogger.Info(fileErr.Error(), zap.String("service", "AddPhoto()"))
+		utils.GetLogger(context).Debug(fileErr.Error(), zap.String("service", "AddPhoto()"))
 	}
 
 	paramErr := context.ShouldBindWith(&photoToAdd, binding.Form)
 	if paramErr != nil {
-		utils.AppLogger.Info(paramErr.Error(), zap.String("service", "AddPhoto()"))
+		utils.GetLogger(context).Debug(paramErr.Error(), zap.String("service", "AddPhoto()"))
 	}
 
 	if fileErr != nil || paramErr != nil {
-		context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"code": responseCode,
-			"data": make(map[string]string),
-			"msg":  constant.GetMessage(responseCode),
-		})
+		response.Abort(context, responseCode)
 		return
 	}
 
 	validCheck := validation.Validation{}
-	validCheck.Required(photoToAdd.AuthID, "auth_id").Message("must have auth id")
 	validCheck.Required(photoToAdd.BucketID, "bucket_id").Message("must have bucket id")
 	validCheck.Required(photoToAdd.Name, "photo_name").Message("must have photo name")
 	validCheck.MaxSize(photoToAdd.Name, 255, "photo_name").Message("length of photo's name cannot exceed 255")
@@ -50,12 +49,10 @@ func AddPhoto(context *gin.Context) {
 	photoToAdd.Tag = strings.Join(photoToAdd.Tags, ";")
 
 	if !validCheck.HasErrors() {
-		if photoToAdd, uploadID, err := models.AddPhoto(&photoToAdd, photoFile); err != nil {
-			if err == models.ErrPhotoExists {
-				responseCode = constant.PhotoAlreadyExist
-			} else {
-				responseCode = constant.InternalServerError
-			}
+		if _, code := checkBucketAccess(context, photoToAdd.BucketID, constant.PermissionContributor); code != 0 {
+			responseCode = code
+		} else if photoToAdd, uploadID, err := models.AddPhoto(context.Request.Context(), &photoToAdd, photoFile); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
 		} else {
 			responseCode = constant.PhotoAddInProcess
 			data["photo"] = *photoToAdd
@@ -63,15 +60,11 @@ func AddPhoto(context *gin.Context) {
 		}
 	} else {
 		for _, e := range validCheck.Errors {
-			utils.AppLogger.Info(e.Message, zap.String("service", "AddPhoto()"))
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "AddPhoto()"))
 		}
 	}
 
-	context.JSON(http.StatusOK, gin.H{
-		"code": responseCode,
-		"data": data,
-		"msg":  constant.GetMessage(responseCode),
-	})
+	response.JSON(context, responseCode, data)
 }
 
 // DeletePhoto func delete an existed photo.
@@ -80,12 +73,8 @@ func DeletePhoto(context *gin.Context) {
 	bucketID, err := strconv.Atoi(context.PostForm("bucket_id"))
 	photoName := context.PostForm("photo_name")
 	if err != nil {
-		utils.AppLogger.Info(err.Error(), zap.String("service", "DeletePhoto()"))
-		context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"code": responseCode,
-			"data": make(map[string]string),
-			"msg":  constant.GetMessage(responseCode),
-		})
+		utils.GetLogger(context).Debug(err.Error(), zap.String("service", "DeletePhoto()"))
+		response.Abort(context, responseCode)
 		return
 	}
 
@@ -96,27 +85,21 @@ func DeletePhoto(context *gin.Context) {
 
 	data := make(map[string]interface{})
 	if !validCheck.HasErrors() {
-		if err := models.DeletePhotoByBucketIDAndPhotoName(uint(bucketID), photoName); err != nil {
-			if err == models.ErrNoSuchPhoto {
-				responseCode = constant.PhotoNotExist
-			} else {
-				responseCode = constant.InternalServerError
-			}
+		if _, code := checkBucketAccess(context, uint(bucketID), constant.PermissionEditor); code != 0 {
+			responseCode = code
+		} else if err := models.DeletePhotoByBucketIDAndPhotoName(context.Request.Context(), uint(bucketID), photoName); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
 		} else {
 			responseCode = constant.PhotoDeleteSuccess
 		}
 	} else {
 		for _, e := range validCheck.Errors {
-			utils.AppLogger.Info(e.Message, zap.String("service", "DeletePhoto()"))
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "DeletePhoto()"))
 		}
 	}
 
 	data["photo_name"] = photoName
-	context.JSON(http.StatusOK, gin.H{
-		"code": responseCode,
-		"data": data,
-		"msg":  constant.GetMessage(responseCode),
-	})
+	response.JSON(context, responseCode, data)
 }
 
 // UpdatePhoto func update an existed photo.
@@ -126,12 +109,8 @@ func UpdatePhoto(context *gin.Context) {
 
 	err := context.ShouldBindWith(&photoToUpdate, binding.Form)
 	if err != nil {
-		utils.AppLogger.Info(err.Error(), zap.String("service", "UpdatePhoto()"))
-		context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"code": responseCode,
-			"data": make(map[string]string),
-			"msg":  constant.GetMessage(responseCode),
-		})
+		utils.GetLogger(context).Debug(err.Error(), zap.String("service", "UpdatePhoto()"))
+		response.Abort(context, responseCode)
 		return
 	}
 
@@ -144,27 +123,25 @@ func UpdatePhoto(context *gin.Context) {
 	data := make(map[string]interface{})
 	if !validCheck.HasErrors() {
 		photoToUpdate.Tag = strings.Join(photoToUpdate.Tags, ";")
-		if photo, err := models.UpdatePhoto(&photoToUpdate); err != nil {
-			if err == models.ErrNoSuchPhoto {
-				responseCode = constant.PhotoNotExist
-			} else {
-				responseCode = constant.InternalServerError
-			}
+
+		// photos change their buckets by the bulk move which checks both buckets
+		photoToUpdate.AuthID = 0
+		photoToUpdate.BucketID = 0
+		if _, code := checkPhotoAccess(context, photoToUpdate.ID, constant.PermissionEditor); code != 0 {
+			responseCode = code
+		} else if photo, err := models.UpdatePhoto(context.Request.Context(), &photoToUpdate); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
 		} else {
 			responseCode = constant.PhotoUpdateSuccess
 			data["photo"] = *photo
 		}
 	} else {
 		for _, e := range validCheck.Errors {
-			utils.AppLogger.Info(e.Message, zap.String("service", "UpdatePhoto()"))
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "UpdatePhoto()"))
 		}
 	}
 
-	context.JSON(http.StatusOK, gin.H{
-		"code": responseCode,
-		"data": data,
-		"msg":  constant.GetMessage(responseCode),
-	})
+	response.JSON(context, responseCode, data)
 }
 
 // GetPhotoByID func get photo by its ID.
@@ -173,12 +150,8 @@ func GetPhotoByID(context *gin.Context) {
 	photoID, err := strconv.Atoi(context.Query("photo_id"))
 
 	if err != nil {
-		utils.AppLogger.Info(err.Error(), zap.String("service", "GetPhotoByID()"))
-		context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"code": responseCode,
-			"data": make(map[string]string),
-			"msg":  constant.GetMessage(responseCode),
-		})
+		utils.GetLogger(context).Debug(err.Error(), zap.String("service", "GetPhotoByID()"))
+		response.Abort(context, responseCode)
 		return
 	}
 
@@ -188,12 +161,8 @@ func GetPhotoByID(context *gin.Context) {
 
 	data := make(map[string]interface{})
 	if !validCheck.HasErrors() {
-		if photo, err := models.GetPhotoByID(uint(photoID)); err != nil {
-			if err == models.ErrNoSuchPhoto {
-				responseCode = constant.PhotoNotExist
-			} else {
-				responseCode = constant.InternalServerError
-			}
+		if photo, code := checkPhotoAccess(context, uint(photoID), constant.PermissionViewer); code != 0 {
+			responseCode = code
 		} else {
 			responseCode = constant.PhotoGetSuccess
 			photo.Tags = strings.Split(photo.Tag, ";")
@@ -201,15 +170,11 @@ func GetPhotoByID(context *gin.Context) {
 		}
 	} else {
 		for _, e := range validCheck.Errors {
-			utils.AppLogger.Info(e.Message, zap.String("service", "GetPhotoByID()"))
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "GetPhotoByID()"))
 		}
 	}
 
-	context.JSON(http.StatusOK, gin.H{
-		"code": responseCode,
-		"data": data,
-		"msg":  constant.GetMessage(responseCode),
-	})
+	response.JSON(context, responseCode, data)
 }
 
 // GetPhotoByBucketID func get photos by bucket ID.
@@ -219,12 +184,8 @@ func GetPhotoByBucketID(context *gin.Context) {
 	offset := context.GetInt("offset")
 
 	if err != nil {
-		utils.AppLogger.Info(err.Error(), zap.String("service", "GetPhotoByBucketID()"))
-		context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"code": responseCode,
-			"data": make(map[string]string),
-			"msg":  constant.GetMessage(responseCode),
-		})
+		utils.GetLogger(context).Debug(err.Error(), zap.String("service", "GetPhotoByBucketID()"))
+		response.Abort(context, responseCode)
 		return
 	}
 
@@ -235,7 +196,9 @@ func GetPhotoByBucketID(context *gin.Context) {
 
 	data := make(map[string]interface{})
 	if !validCheck.HasErrors() {
-		if photos, err := models.GetPhotosByBucketID(uint(bucketID), offset); err != nil {
+		if _, code := checkBucketAccess(context, uint(bucketID), constant.PermissionViewer); code != 0 {
+			responseCode = code
+		} else if photos, err := models.GetPhotosByBucketID(context.Request.Context(), uint(bucketID), offset); err != nil {
 			responseCode = constant.InternalServerError
 		} else {
 			responseCode = constant.PhotoGetSuccess
@@ -246,15 +209,11 @@ func GetPhotoByBucketID(context *gin.Context) {
 		}
 	} else {
 		for _, e := range validCheck.Errors {
-			utils.AppLogger.Info(e.Message, zap.String("service", "GetPhotoByBucketID()"))
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "GetPhotoByBucketID()"))
 		}
 	}
 
-	context.JSON(http.StatusOK, gin.H{
-		"code": responseCode,
-		"data": data,
-		"msg":  constant.GetMessage(responseCode),
-	})
+	response.JSON(context, responseCode, data)
 }
 
 // GetPhotoUploadStatus func get the upload status of photo by photo ID.
@@ -269,16 +228,97 @@ func GetPhotoUploadStatus(context *gin.Context) {
 	data["upload_id"] = uploadID
 
 	if !validCheck.HasErrors() {
-		responseCode = models.GetPhotoUploadStatus(uploadID)
+		responseCode = models.GetPhotoUploadStatus(context.Request.Context(), uploadID)
 	} else {
 		for _, e := range validCheck.Errors {
-			utils.AppLogger.Info(e.Message, zap.String("service", "GetPhotoUploadStatus()"))
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "GetPhotoUploadStatus()"))
 		}
 	}
 
-	context.JSON(http.StatusOK, gin.H{
-		"code": responseCode,
-		"data": data,
-		"msg":  constant.GetMessage(responseCode),
-	})
+	response.JobStatus(context, responseCode, data)
+}
+
+// GetPhotoContent func stream the content of a photo from the photo storage.
+func GetPhotoContent(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	photoID, err := strconv.Atoi(context.Query("photo_id"))
+	rendition := context.DefaultQuery("rendition", constant.PhotoRenditionOriginal)
+
+	if err != nil {
+		utils.GetLogger(context).Debug(err.Error(), zap.String("service", "GetPhotoContent()"))
+		response.Abort(context, responseCode)
+		return
+	}
+
+	validCheck := validation.Validation{}
+	validCheck.Required(photoID, "photo_id").Message("must have photo id")
+	validCheck.Min(photoID, 1, "photo_id").Message("photo id should be positive")
+
+	data := make(map[string]interface{})
+	data["photo_id"] = photoID
+	data["rendition"] = rendition
+
+	if !validCheck.HasErrors() {
+		if photo, code := checkPhotoAccess(context, uint(photoID), constant.PermissionViewer); code != 0 {
+			responseCode = code
+		} else if version := context.Query("version"); version != "" && version != strconv.Itoa(photo.Version) {
+			// previous versions are only kept as originals
+			versionNumber, _ := strconv.Atoi(version)
+			if photoVersion, err := models.GetPhotoVersion(context.Request.Context(), photo.ID, versionNumber); err != nil {
+				responseCode = constant.PhotoVersionNotExist
+			} else if err := serveBlob(context, photoVersion.BlobName, photo.Name, context.Query("download") == "1"); err == nil {
+				return
+			} else {
+				responseCode = constant.InternalServerError
+			}
+		} else if blobName, err := models.GetPhotoBlobName(photo, rendition); err == nil {
+			if err := serveBlob(context, blobName, photo.Name, context.Query("download") == "1"); err == nil {
+				return
+			}
+			responseCode = constant.InternalServerError
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "GetPhotoContent()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// serveBlob func write a blob to the response as the file name,
+// ranges and conditional requests are handled by http.ServeContent.
+func serveBlob(context *gin.Context, blobName, fileName string, attachment bool) error {
+	props, err := utils.PhotoStorage.Properties(context.Request.Context(), blobName)
+	if err != nil {
+		utils.GetLogger(context).Error(err.Error(), zap.String("service", "serveBlob()"))
+		return err
+	}
+
+	contentType := props.ContentType
+	if contentType == "" || contentType == constant.PhotoDefaultMIME {
+		if byExt := mime.TypeByExtension(filepath.Ext(fileName)); byExt != "" {
+			contentType = byExt
+		} else {
+			contentType = constant.PhotoDefaultMIME
+		}
+	}
+
+	disposition := "inline"
+	if attachment {
+		disposition = "attachment"
+	}
+
+	header := context.Writer.Header()
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": filepath.Base(fileName)}))
+	header.Set("Cache-Control", "private, max-age=0, must-revalidate")
+	if props.ETag != "" {
+		header.Set("ETag", props.ETag)
+	}
+
+	reader := utils.NewBlobReader(context.Request.Context(), utils.PhotoStorage, blobName, props.Size)
+	defer reader.Close()
+	http.ServeContent(context.Writer, context.Request, "", props.LastModified, reader)
+	return nil
 }
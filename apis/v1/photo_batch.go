@@ -0,0 +1,94 @@
+package v1
+
+import (
+	"strings"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+	"go.uber.org/zap"
+
+	"github.com/astaxie/beego/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/walk1ng/gin-photo-gallery-storage/apis/response"
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/models"
+)
+
+// AddPhotoBatch func add many photos to a bucket in one multipart request,
+// the files are sent as "photos" and/or zip archives as "archive".
+func AddPhotoBatch(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	photoTemplate := models.Photo{}
+
+	form, formErr := context.MultipartForm()
+	if formErr != nil {
+		utils.GetLogger(context).Debug(formErr.Error(), zap.String("service", "AddPhotoBatch()"))
+	}
+
+	paramErr := context.ShouldBindWith(&photoTemplate, binding.FormMultipart)
+	if paramErr != nil {
+		utils.GetLogger(context).Debug(paramErr.Error(), zap.String("service", "AddPhotoBatch()"))
+	}
+
+	if formErr != nil || paramErr != nil {
+		response.Abort(context, responseCode)
+		return
+	}
+
+	photoFiles := form.File[constant.BatchPhotoField]
+	archives := form.File[constant.BatchArchiveField]
+
+	validCheck := validation.Validation{}
+	validCheck.Required(photoTemplate.BucketID, "bucket_id").Message("must have bucket id")
+	validCheck.Min(len(photoFiles)+len(archives), 1, "photos").Message("must have photos or archive")
+	validCheck.Max(len(photoFiles), constant.BatchMaxFiles, "photos").Message("too many photos in a batch")
+
+	data := make(map[string]interface{})
+	photoTemplate.Tag = strings.Join(photoTemplate.Tags, ";")
+
+	if !validCheck.HasErrors() {
+		if _, code := checkBucketAccess(context, photoTemplate.BucketID, constant.PermissionContributor); code != 0 {
+			responseCode = code
+		} else if status, err := models.AddPhotoBatch(context.Request.Context(), &photoTemplate, context.GetString("user_name"), photoFiles, archives); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
+		} else {
+			responseCode = constant.PhotoAddInProcess
+			data["batch"] = *status
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "AddPhotoBatch()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// GetPhotoBatchStatus func get the upload status of every photo in a batch added by the user.
+func GetPhotoBatchStatus(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	batchID := context.Query("batch_id")
+
+	validCheck := validation.Validation{}
+	validCheck.Required(batchID, "batch_id").Message("must have batch id")
+
+	data := make(map[string]interface{})
+	data["batch_id"] = batchID
+
+	if !validCheck.HasErrors() {
+		if status, err := models.GetPhotoBatchStatus(context.Request.Context(), batchID, context.GetString("user_name")); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
+		} else {
+			responseCode = constant.PhotoGetSuccess
+			data["batch"] = *status
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "GetPhotoBatchStatus()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
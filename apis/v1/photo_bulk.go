@@ -0,0 +1,111 @@
+package v1
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+	"go.uber.org/zap"
+
+	"github.com/astaxie/beego/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walk1ng/gin-photo-gallery-storage/apis/response"
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/models"
+)
+
+// MovePhotos func move photos to another bucket.
+func MovePhotos(context *gin.Context) {
+	bulkPhotos(context, "MovePhotos()", true, func(authID uint, photoIDs []uint, bucketID uint, atomic bool) ([]models.BulkResult, error) {
+		return models.MovePhotos(context.Request.Context(), authID, photoIDs, bucketID, atomic)
+	})
+}
+
+// CopyPhotos func copy photos to another bucket.
+func CopyPhotos(context *gin.Context) {
+	bulkPhotos(context, "CopyPhotos()", true, func(authID uint, photoIDs []uint, bucketID uint, atomic bool) ([]models.BulkResult, error) {
+		return models.CopyPhotos(context.Request.Context(), authID, photoIDs, bucketID, atomic)
+	})
+}
+
+// DeletePhotos func delete photos.
+func DeletePhotos(context *gin.Context) {
+	bulkPhotos(context, "DeletePhotos()", false, func(authID uint, photoIDs []uint, bucketID uint, atomic bool) ([]models.BulkResult, error) {
+		return models.DeletePhotos(context.Request.Context(), authID, photoIDs, atomic)
+	})
+}
+
+// RetagPhotos func add and remove tags of photos.
+func RetagPhotos(context *gin.Context) {
+	addTags := context.PostFormArray("add_tags")
+	removeTags := context.PostFormArray("remove_tags")
+	bulkPhotos(context, "RetagPhotos()", false, func(authID uint, photoIDs []uint, bucketID uint, atomic bool) ([]models.BulkResult, error) {
+		return models.RetagPhotos(context.Request.Context(), authID, photoIDs, addTags, removeTags, atomic)
+	})
+}
+
+// bulkPhotos func parse the photo ids of a bulk request, run the operation and report every photo.
+func bulkPhotos(context *gin.Context, service string, needBucket bool,
+	operation func(authID uint, photoIDs []uint, bucketID uint, atomic bool) ([]models.BulkResult, error)) {
+	responseCode := constant.InvalidParams
+	photoIDs, err := parseIDList(context.PostFormArray("photo_ids"))
+	bucketID := 0
+	if err == nil && needBucket {
+		bucketID, err = strconv.Atoi(context.PostForm("bucket_id"))
+	}
+	if err != nil {
+		utils.GetLogger(context).Debug(err.Error(), zap.String("service", service))
+		response.Abort(context, responseCode)
+		return
+	}
+	atomic := context.PostForm("atomic") == "1" || context.PostForm("atomic") == "true"
+
+	validCheck := validation.Validation{}
+	validCheck.Min(len(photoIDs), 1, "photo_ids").Message("must have photo ids")
+	validCheck.Max(len(photoIDs), constant.BatchMaxFiles, "photo_ids").Message("too many photo ids")
+	if needBucket {
+		validCheck.Min(bucketID, 1, "bucket_id").Message("bucket id should be positive")
+	}
+
+	data := make(map[string]interface{})
+	if !validCheck.HasErrors() {
+		if auth, err := getCurrentAuth(context); err != nil {
+			responseCode = constant.UserAuthError
+		} else if results, err := operation(auth.ID, photoIDs, uint(bucketID), atomic); err != nil {
+			// an aborted atomic operation still reports each photo
+			responseCode = apperr.Code(err, constant.InternalServerError)
+			if results != nil {
+				data["results"] = results
+			}
+		} else {
+			responseCode = constant.PhotoBulkSuccess
+			data["results"] = results
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", service))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// parseIDList func parse ids given as repeated values and/or comma separated lists
+func parseIDList(values []string) ([]uint, error) {
+	ids := make([]uint, 0, len(values))
+	for _, value := range values {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field == "" {
+				continue
+			}
+			id, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,70 @@
+package v1
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+	"go.uber.org/zap"
+
+	"github.com/astaxie/beego/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/walk1ng/gin-photo-gallery-storage/apis/response"
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/models"
+)
+
+// TransformPhoto func get a resized photo, the sizes which are not in the allowlist
+// must be signed with the transform secret.
+func TransformPhoto(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	options := utils.TransformOptions{}
+
+	photoID, err := strconv.Atoi(context.Query("photo_id"))
+	paramErr := context.ShouldBindWith(&options, binding.Query)
+	if err != nil || paramErr != nil {
+		if err != nil {
+			utils.GetLogger(context).Debug(err.Error(), zap.String("service", "TransformPhoto()"))
+		}
+		if paramErr != nil {
+			utils.GetLogger(context).Debug(paramErr.Error(), zap.String("service", "TransformPhoto()"))
+		}
+		response.Abort(context, responseCode)
+		return
+	}
+
+	validCheck := validation.Validation{}
+	validCheck.Min(photoID, 1, "photo_id").Message("photo id should be positive")
+
+	data := make(map[string]interface{})
+	data["photo_id"] = photoID
+
+	if !validCheck.HasErrors() {
+		if photo, code := checkPhotoAccess(context, uint(photoID), constant.PermissionViewer); code != 0 {
+			responseCode = code
+		} else if err := options.Normalize(); err != nil {
+			responseCode = constant.PhotoTransformInvalid
+		} else if err := utils.CheckTransform(photo.ID, &options, context.Query("sig")); err != nil {
+			responseCode = constant.PhotoTransformDenied
+		} else if variantBlobName, err := models.GetPhotoVariant(context.Request.Context(), photo, &options); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
+		} else {
+			fileName := strings.TrimSuffix(photo.Name, filepath.Ext(photo.Name)) + "." + options.Format
+			if err := serveBlob(context, variantBlobName, fileName, false); err == nil {
+				return
+			}
+			responseCode = constant.InternalServerError
+		}
+		data["options"] = options
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "TransformPhoto()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
@@ -0,0 +1,192 @@
+package v1
+
+import (
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+	"go.uber.org/zap"
+
+	"github.com/astaxie/beego/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/walk1ng/gin-photo-gallery-storage/apis/response"
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/models"
+)
+
+// InitPhotoUpload func add a new photo whose file will be uploaded in chunks.
+func InitPhotoUpload(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	photoToAdd := models.Photo{}
+
+	paramErr := context.ShouldBindWith(&photoToAdd, binding.Form)
+	totalSize, sizeErr := strconv.ParseInt(context.PostForm("total_size"), 10, 64)
+	if paramErr != nil || sizeErr != nil {
+		if paramErr != nil {
+			utils.GetLogger(context).Debug(paramErr.Error(), zap.String("service", "InitPhotoUpload()"))
+		}
+		if sizeErr != nil {
+			utils.GetLogger(context).Debug(sizeErr.Error(), zap.String("service", "InitPhotoUpload()"))
+		}
+		response.Abort(context, responseCode)
+		return
+	}
+
+	validCheck := validation.Validation{}
+	validCheck.Required(photoToAdd.BucketID, "bucket_id").Message("must have bucket id")
+	validCheck.Required(photoToAdd.Name, "photo_name").Message("must have photo name")
+	validCheck.MaxSize(photoToAdd.Name, 255, "photo_name").Message("length of photo's name cannot exceed 255")
+	validCheck.Min(int(totalSize), 1, "total_size").Message("total size should be positive")
+
+	data := make(map[string]interface{})
+	photoToAdd.Tag = strings.Join(photoToAdd.Tags, ";")
+
+	if !validCheck.HasErrors() {
+		if _, code := checkBucketAccess(context, photoToAdd.BucketID, constant.PermissionContributor); code != 0 {
+			responseCode = code
+		} else if photo, uploadID, err := models.InitPhotoUpload(context.Request.Context(), &photoToAdd, context.GetString("user_name"), totalSize); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
+		} else {
+			responseCode = constant.PhotoAddInProcess
+			data["photo"] = *photo
+			data["photo_upload_id"] = uploadID
+			data["chunk_max_size"] = constant.UploadChunkMaxSize
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "InitPhotoUpload()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// UploadPhotoChunk func upload a chunk of a photo, the chunk is the raw request body.
+func UploadPhotoChunk(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	uploadID := context.Query("upload_id")
+	index, err := strconv.Atoi(context.Query("index"))
+	if err != nil {
+		utils.GetLogger(context).Debug(err.Error(), zap.String("service", "UploadPhotoChunk()"))
+		response.Abort(context, responseCode)
+		return
+	}
+
+	chunk, err := ioutil.ReadAll(io.LimitReader(context.Request.Body, constant.UploadChunkMaxSize+1))
+	if err != nil {
+		utils.GetLogger(context).Warn(err.Error(), zap.String("service", "UploadPhotoChunk()"))
+	}
+
+	validCheck := validation.Validation{}
+	validCheck.Required(uploadID, "upload_id").Message("must have upload id")
+	validCheck.Range(index, 0, constant.UploadMaxChunks-1, "index").Message("chunk index is out of range")
+	validCheck.Range(len(chunk), 1, constant.UploadChunkMaxSize, "chunk").Message("chunk size is out of range")
+
+	data := make(map[string]interface{})
+	data["upload_id"] = uploadID
+	data["index"] = index
+
+	if err == nil && !validCheck.HasErrors() {
+		if err := models.UploadPhotoChunk(context.Request.Context(), uploadID, context.GetString("user_name"), index, chunk); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
+		} else {
+			responseCode = constant.PhotoChunkSuccess
+			data["size"] = len(chunk)
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "UploadPhotoChunk()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// GetPhotoUploadProgress func get the received chunks of a photo upload to resume it.
+func GetPhotoUploadProgress(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	uploadID := context.Query("upload_id")
+
+	validCheck := validation.Validation{}
+	validCheck.Required(uploadID, "upload_id").Message("must have upload id")
+
+	data := make(map[string]interface{})
+	data["upload_id"] = uploadID
+
+	if !validCheck.HasErrors() {
+		if progress, err := models.GetPhotoUploadProgress(context.Request.Context(), uploadID, context.GetString("user_name")); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
+		} else {
+			responseCode = constant.PhotoAddInProcess
+			data["progress"] = *progress
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "GetPhotoUploadProgress()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// CompletePhotoUpload func commit the uploaded chunks of a photo.
+func CompletePhotoUpload(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	uploadID := context.Query("upload_id")
+
+	validCheck := validation.Validation{}
+	validCheck.Required(uploadID, "upload_id").Message("must have upload id")
+
+	data := make(map[string]interface{})
+	data["upload_id"] = uploadID
+
+	if !validCheck.HasErrors() {
+		progress, err := models.CompletePhotoUpload(context.Request.Context(), uploadID, context.GetString("user_name"))
+		if err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
+			if progress != nil {
+				data["progress"] = *progress
+			}
+		} else {
+			responseCode = constant.PhotoAddInProcess
+			data["progress"] = *progress
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "CompletePhotoUpload()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// AbortPhotoUpload func abort a photo upload and delete the photo.
+func AbortPhotoUpload(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	uploadID := context.Query("upload_id")
+
+	validCheck := validation.Validation{}
+	validCheck.Required(uploadID, "upload_id").Message("must have upload id")
+
+	data := make(map[string]interface{})
+	data["upload_id"] = uploadID
+
+	if !validCheck.HasErrors() {
+		if err := models.AbortPhotoUpload(context.Request.Context(), uploadID, context.GetString("user_name")); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
+		} else {
+			responseCode = constant.PhotoUploadAborted
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "AbortPhotoUpload()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
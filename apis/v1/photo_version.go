@@ -0,0 +1,131 @@
+package v1
+
+import (
+	"strconv"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+	"go.uber.org/zap"
+
+	"github.com/astaxie/beego/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walk1ng/gin-photo-gallery-storage/apis/response"
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/models"
+)
+
+// ReplacePhoto func upload a new version of a photo, keeping its id and tags.
+func ReplacePhoto(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	photoID, err := strconv.Atoi(context.Query("photo_id"))
+	if err != nil {
+		utils.GetLogger(context).Debug(err.Error(), zap.String("service", "ReplacePhoto()"))
+	}
+
+	photoFile, fileErr := context.FormFile("photo")
+	if fileErr != nil {
+		utils.GetLogger(context).Debug(fileErr.Error(), zap.String("service", "ReplacePhoto()"))
+	}
+
+	if err != nil || fileErr != nil {
+		response.Abort(context, responseCode)
+		return
+	}
+
+	validCheck := validation.Validation{}
+	validCheck.Min(photoID, 1, "photo_id").Message("photo id should be positive")
+
+	data := make(map[string]interface{})
+	data["photo_id"] = photoID
+	if !validCheck.HasErrors() {
+		if photo, code := checkPhotoAccess(context, uint(photoID), constant.PermissionEditor); code != 0 {
+			responseCode = code
+		} else if replaced, err := models.ReplacePhoto(context.Request.Context(), photo.AuthID, photo.ID, photoFile); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
+		} else {
+			responseCode = constant.PhotoReplaceSuccess
+			data["photo"] = *replaced
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "ReplacePhoto()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// GetPhotoVersions func get the version history of a photo.
+func GetPhotoVersions(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	photoID, err := strconv.Atoi(context.Query("photo_id"))
+	if err != nil {
+		utils.GetLogger(context).Debug(err.Error(), zap.String("service", "GetPhotoVersions()"))
+		response.Abort(context, responseCode)
+		return
+	}
+
+	validCheck := validation.Validation{}
+	validCheck.Min(photoID, 1, "photo_id").Message("photo id should be positive")
+
+	data := make(map[string]interface{})
+	data["photo_id"] = photoID
+	if !validCheck.HasErrors() {
+		if photo, code := checkPhotoAccess(context, uint(photoID), constant.PermissionViewer); code != 0 {
+			responseCode = code
+		} else if versions, err := models.GetPhotoVersions(context.Request.Context(), photo.AuthID, photo.ID); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
+		} else {
+			responseCode = constant.PhotoVersionsSuccess
+			data["versions"] = versions
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "GetPhotoVersions()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// RestorePhotoVersion func make a previous version the current version of a photo.
+func RestorePhotoVersion(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	photoID, err := strconv.Atoi(context.Query("photo_id"))
+	version, versionErr := strconv.Atoi(context.Query("version"))
+	if err != nil || versionErr != nil {
+		if err != nil {
+			utils.GetLogger(context).Debug(err.Error(), zap.String("service", "RestorePhotoVersion()"))
+		}
+		if versionErr != nil {
+			utils.GetLogger(context).Debug(versionErr.Error(), zap.String("service", "RestorePhotoVersion()"))
+		}
+		response.Abort(context, responseCode)
+		return
+	}
+
+	validCheck := validation.Validation{}
+	validCheck.Min(photoID, 1, "photo_id").Message("photo id should be positive")
+	validCheck.Min(version, 1, "version").Message("version should be positive")
+
+	data := make(map[string]interface{})
+	data["photo_id"] = photoID
+	data["version"] = version
+	if !validCheck.HasErrors() {
+		if photo, code := checkPhotoAccess(context, uint(photoID), constant.PermissionEditor); code != 0 {
+			responseCode = code
+		} else if restored, err := models.RestorePhotoVersion(context.Request.Context(), photo.AuthID, photo.ID, version); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
+		} else {
+			responseCode = constant.PhotoVersionRestored
+			data["photo"] = *restored
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "RestorePhotoVersion()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
@@ -0,0 +1,125 @@
+package v1
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+	"go.uber.org/zap"
+
+	"github.com/astaxie/beego/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walk1ng/gin-photo-gallery-storage/apis/response"
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/models"
+)
+
+// GetTrash func get the trashed buckets and photos of the user.
+func GetTrash(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	offset := context.GetInt("offset")
+
+	validCheck := validation.Validation{}
+	validCheck.Min(offset, 0, "page_offset").Message("page offset must be >= 0")
+
+	data := make(map[string]interface{})
+	if !validCheck.HasErrors() {
+		if auth, err := getCurrentAuth(context); err != nil {
+			responseCode = constant.UserAuthError
+		} else if trash, err := models.GetTrashByAuthID(context.Request.Context(), auth.ID, offset); err != nil {
+			responseCode = constant.InternalServerError
+		} else {
+			responseCode = constant.TrashGetSuccess
+			data["trash"] = *trash
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "GetTrash()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// RestorePhoto func restore a trashed photo.
+func RestorePhoto(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	photoID, err := strconv.Atoi(context.Query("photo_id"))
+	if err != nil {
+		utils.GetLogger(context).Debug(err.Error(), zap.String("service", "RestorePhoto()"))
+		response.Abort(context, responseCode)
+		return
+	}
+
+	validCheck := validation.Validation{}
+	validCheck.Min(photoID, 1, "photo_id").Message("photo id should be positive")
+
+	data := make(map[string]interface{})
+	data["photo_id"] = photoID
+	if !validCheck.HasErrors() {
+		if auth, err := getCurrentAuth(context); err != nil {
+			responseCode = constant.UserAuthError
+		} else if photo, err := models.RestorePhoto(context.Request.Context(), auth.ID, uint(photoID)); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
+		} else {
+			responseCode = constant.PhotoRestoreSuccess
+			data["photo"] = *photo
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "RestorePhoto()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// RestoreBucket func restore a trashed bucket with its photos.
+func RestoreBucket(context *gin.Context) {
+	responseCode := constant.InvalidParams
+	bucketID, err := strconv.Atoi(context.Query("bucket_id"))
+	if err != nil {
+		utils.GetLogger(context).Debug(err.Error(), zap.String("service", "RestoreBucket()"))
+		response.Abort(context, responseCode)
+		return
+	}
+
+	validCheck := validation.Validation{}
+	validCheck.Min(bucketID, 1, "bucket_id").Message("bucket id should be positive")
+
+	data := make(map[string]interface{})
+	data["bucket_id"] = bucketID
+	if !validCheck.HasErrors() {
+		if auth, err := getCurrentAuth(context); err != nil {
+			responseCode = constant.UserAuthError
+		} else if bucket, err := models.RestoreBucket(context.Request.Context(), auth.ID, uint(bucketID)); err != nil {
+			responseCode = apperr.Code(err, constant.InternalServerError)
+		} else {
+			responseCode = constant.BucketRestoreSuccess
+			data["bucket"] = *bucket
+		}
+	} else {
+		for _, e := range validCheck.Errors {
+			utils.GetLogger(context).Debug(e.Message, zap.String("service", "RestoreBucket()"))
+		}
+	}
+
+	response.JSON(context, responseCode, data)
+}
+
+// EmptyTrash func permanently delete everything in the trash of the user.
+func EmptyTrash(context *gin.Context) {
+	responseCode := constant.InternalServerError
+
+	if auth, err := getCurrentAuth(context); err != nil {
+		responseCode = constant.UserAuthError
+	} else if err := models.PurgeTrash(context.Request.Context(), auth.ID, time.Now()); err != nil {
+		utils.GetLogger(context).Error(err.Error(), zap.String("service", "EmptyTrash()"))
+	} else {
+		responseCode = constant.TrashPurgeSuccess
+	}
+
+	response.JSON(context, responseCode, make(map[string]string))
+}
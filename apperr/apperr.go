@@ -0,0 +1,115 @@
+package apperr
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+)
+
+// Error struct is a domain error with the response code answered to the client for it
+type Error struct {
+	Code int
+	Text string
+}
+
+// New func create a domain error answered with the response code
+func New(code int, text string) *Error {
+	return &Error{Code: code, Text: text}
+}
+
+// Error func get the text of the error
+func (e *Error) Error() string {
+	return e.Text
+}
+
+// Status func get the http status answered for the error
+func (e *Error) Status() int {
+	return Status(e.Code)
+}
+
+// Code func get the response code of an error, the default code is used for errors without one
+func Code(err error, defaultCode int) int {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Code
+	}
+	return defaultCode
+}
+
+// statuses is the http status of each response code which is not 200 OK
+var statuses = map[int]int{
+	constant.UserAlreadyExist:     http.StatusConflict,
+	constant.UserAddSuccess:       http.StatusCreated,
+	constant.UserAuthError:        http.StatusUnauthorized,
+	constant.UserAuthTimeout:      http.StatusUnauthorized,
+	constant.UserDenied:           http.StatusForbidden,
+	constant.UserDisabled:         http.StatusForbidden,
+	constant.UserNotExist:         http.StatusNotFound,
+	constant.TokenAddSuccess:      http.StatusCreated,
+	constant.TokenNotExist:        http.StatusNotFound,
+	constant.TokenInvalid:         http.StatusUnauthorized,
+	constant.UserVerified:         http.StatusConflict,
+	constant.UserUnverified:       http.StatusForbidden,
+	constant.ResetMailSent:        http.StatusAccepted,
+	constant.UserVerifyMailSent:   http.StatusAccepted,
+	constant.MailTokenInvalid:     http.StatusBadRequest,
+	constant.UserLocked:           http.StatusTooManyRequests,
+	constant.MFACodeInvalid:       http.StatusUnauthorized,
+	constant.MFAEnabled:           http.StatusConflict,
+	constant.MFANotEnabled:        http.StatusConflict,
+	constant.OIDCProviderNotExist: http.StatusNotFound,
+	constant.OIDCLoginError:       http.StatusUnauthorized,
+	constant.OIDCNotLinked:        http.StatusForbidden,
+	constant.PasswordWrong:        http.StatusForbidden,
+	constant.EmailAlreadyExist:    http.StatusConflict,
+	constant.ReauthRequired:       http.StatusForbidden,
+
+	constant.JwtGenerationError: http.StatusInternalServerError,
+	constant.JwtMissingError:    http.StatusUnauthorized,
+	constant.JwtParseError:      http.StatusUnauthorized,
+	constant.RefreshTokenError:  http.StatusUnauthorized,
+	constant.RefreshTokenReused: http.StatusUnauthorized,
+
+	constant.BucketAlreadyExist:    http.StatusConflict,
+	constant.BucketAddSuccess:      http.StatusCreated,
+	constant.BucketNotExist:        http.StatusNotFound,
+	constant.BucketExportInProcess: http.StatusAccepted,
+	constant.BucketExportError:     http.StatusInternalServerError,
+	constant.BucketExportNotExist:  http.StatusNotFound,
+	constant.BucketInTrash:         http.StatusConflict,
+	constant.CollaboratorExist:     http.StatusConflict,
+	constant.CollaboratorNotExist:  http.StatusNotFound,
+
+	constant.PhotoAlreadyExist:     http.StatusConflict,
+	constant.PhotoAddInProcess:     http.StatusAccepted,
+	constant.PhotoUploadError:      http.StatusInternalServerError,
+	constant.PhotoNotExist:         http.StatusNotFound,
+	constant.PhotoUploadNotExist:   http.StatusNotFound,
+	constant.PhotoUploadIncomplete: http.StatusConflict,
+	constant.PhotoBatchNotExist:    http.StatusNotFound,
+	constant.PhotoBatchTooLarge:    http.StatusRequestEntityTooLarge,
+	constant.PhotoBulkAborted:      http.StatusConflict,
+	constant.PhotoInTrash:          http.StatusConflict,
+	constant.PhotoQuotaExceeded:    http.StatusForbidden,
+	constant.PhotoTypeNotAllowed:   http.StatusUnsupportedMediaType,
+	constant.PhotoFileCorrupt:      http.StatusUnprocessableEntity,
+	constant.PhotoFileTooLarge:     http.StatusRequestEntityTooLarge,
+	constant.PhotoVersionNotExist:  http.StatusNotFound,
+	constant.PhotoNotReady:         http.StatusConflict,
+	constant.PhotoTransformInvalid: http.StatusBadRequest,
+	constant.PhotoTransformDenied:  http.StatusForbidden,
+	constant.PhotoTransformFailed:  http.StatusUnsupportedMediaType,
+
+	constant.InternalServerError: http.StatusInternalServerError,
+	constant.InvalidParams:       http.StatusBadRequest,
+	constant.RequestThrottled:    http.StatusTooManyRequests,
+}
+
+// Status func get the http status answered with a response code
+func Status(code int) int {
+	if status, ok := statuses[code]; ok {
+		return status
+	}
+	return http.StatusOK
+}
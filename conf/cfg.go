@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"log"
 	"os"
+	"path/filepath"
 )
 
 // Cfg struct
@@ -15,7 +16,7 @@ type Cfg struct {
 var ServerCfg Cfg
 
 func init() {
-	cfgFile, err := os.Open("conf/server.json")
+	cfgFile, err := os.Open(cfgPath())
 	defer cfgFile.Close()
 	if err != nil {
 		log.Fatalln(err)
@@ -37,3 +38,31 @@ func (cfg *Cfg) Get(key string) string {
 	log.Fatalf("No such config term: %s", key)
 	return ""
 }
+
+// GetDefault function get a config term or the default value if it is not configured
+func (cfg *Cfg) GetDefault(key, defaultVal string) string {
+	if val, ok := cfg.ConfigMap[key]; ok {
+		return val
+	}
+	return defaultVal
+}
+
+// cfgPath func find conf/server.json in the working directory or the closest parent directory,
+// so the tests of a package run in its directory also find it.
+func cfgPath() string {
+	path := filepath.Join("conf", "server.json")
+	dir, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, path)); err == nil {
+			return filepath.Join(dir, path)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return path
+		}
+		dir = parent
+	}
+}
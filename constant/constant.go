@@ -1,17 +1,55 @@
 package constant
 
 const (
-	// JWT constants
-	JwtSecret         = "JWT_SECRET"
-	Jwt               = "jwt"
-	JwtExpMinute      = 30
-	PhotoStorageAdmin = "admin"
+	// JWT constants, the jwt are signed by RS256 or EdDSA keys which are rotated
+	// and published by the jwks endpoint
+	JwtSecret                  = "JWT_SECRET"
+	Jwt                        = "jwt"
+	JwtExpMinute               = 30
+	PhotoStorageAdmin          = "admin"
+	JwtAlg                     = "JWT_ALG"
+	DefaultJwtAlg              = "RS256"
+	JwtAcceptSecret            = "JWT_ACCEPT_SECRET"
+	JwtKeyRotationHours        = "JWT_KEY_ROTATION_HOURS"
+	DefaultJwtKeyRotationHours = "168"
+	JwtKeys                    = "JWT_SIGNING_KEYS"
+	JwtKeysLock                = "JWT_SIGNING_KEYS_LOCK"
+	JwtKeysLockMaxAge          = 30
+	JwtKeyCheckMinutes         = 10
+	JwtKeyReloadSeconds        = 10
+	JwtKeyWaitMillis           = 200
+	JwtKeyWaitRetries          = 25
+	JwtKeyGraceSeconds         = 300
+	JwtRSAKeyBits              = 2048
+
+	// Permissions on a bucket, collaborators are granted one of viewer, contributor and editor
+	PermissionViewer      = "viewer"
+	PermissionContributor = "contributor"
+	PermissionEditor      = "editor"
+	PermissionOwner       = "owner"
+
+	// Roles of the users, the read-only users cannot change anything
+	// and the uploader role is only acted as by the api tokens which can upload
+	RoleAdmin     = "admin"
+	RoleUser      = "user"
+	RoleReadOnly  = "readonly"
+	RoleUploader  = "uploader"
+	AdminUserName = "ADMIN_USER_NAME"
+
+	// API token constants, the tokens are sent as Authorization: Bearer <token>
+	APITokenPrefix   = "pgs_"
+	TokenScopeRead   = "read"
+	TokenScopeUpload = "upload"
+	TokenScopeFull   = "full"
+	BearerPrefix     = "Bearer "
 
 	// Server constants
 	ServerPort   = "SERVER_PORT"
 	PageSize     = 20
 	ServerDomain = "SERVER_DOMAIN"
 	ServerPath   = "SERVER_PATH"
+	// TrustedProxies lists the proxies allowed to set the client ip by X-Forwarded-For
+	TrustedProxies = "TRUSTED_PROXIES"
 
 	// DB constants
 	DBConnect = "%s:%s@tcp(%s:%s)/%s?charset=utf8&parseTime=True&loc=Local"
@@ -22,10 +60,104 @@ const (
 	DBPwd     = "DB_PWD"
 	DBName    = "DB_NAME"
 
-	// Auth constants
-	CookieMaxAge = 1800
-	LoginMaxAge  = 1800
-	LoginUser    = "LOGIN_"
+	// Auth constants, a login lasts as long as its refresh token
+	// while the jwt is short-lived and renewed by the refresh endpoint
+	CookieMaxAge        = 1800
+	LoginMaxAge         = 604800
+	LoginUser           = "LOGIN_"
+	RefreshToken        = "refresh_token"
+	RefreshTokenMaxAge  = 604800
+	RefreshTokenFormat  = "REFRESH_%s"
+	RefreshFamilyFormat = "REFRESH_FAMILY_%s"
+	RefreshUserFormat   = "REFRESH_USER_%s"
+
+	// Login lockout constants, the failed logins are counted per user name and per ip
+	LoginMaxAttempts           = "LOGIN_MAX_ATTEMPTS"
+	DefaultLoginMaxAttempts    = "5"
+	LoginIPMaxAttempts         = "LOGIN_IP_MAX_ATTEMPTS"
+	DefaultLoginIPMaxAttempts  = "20"
+	LoginLockSeconds           = "LOGIN_LOCK_SECONDS"
+	DefaultLoginLockSeconds    = "60"
+	LoginMaxLockSeconds        = "LOGIN_MAX_LOCK_SECONDS"
+	DefaultLoginMaxLockSeconds = "3600"
+	LoginFailureWindow         = "LOGIN_FAILURE_WINDOW_SECONDS"
+	DefaultLoginFailureWindow  = "86400"
+	LoginFailureFormat         = "LOGIN_FAILURE_%s_%s"
+	LoginLockFormat            = "LOGIN_LOCK_%s_%s"
+
+	// TOTP constants, a password-only login of a user with totp gets a pending token
+	// which must be completed with a totp or recovery code
+	TOTPIssuer        = "TOTP_ISSUER"
+	DefaultTOTPIssuer = "gin-photo-gallery"
+	TOTPSecretSize    = 20
+	TOTPDigits        = 6
+	TOTPPeriod        = 30
+	TOTPUsedFormat    = "TOTP_USED_%s_%d"
+	MFATokenFormat    = "MFA_PENDING_%s"
+	MFATokenMaxAge    = 300
+	RecoveryCodeCount = 10
+
+	// OIDC constants, the providers are listed in OIDC_PROVIDERS and each one is
+	// configured by OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL and _SCOPES
+	OIDCProviders        = "OIDC_PROVIDERS"
+	OIDCAutoProvision    = "OIDC_AUTO_PROVISION"
+	OIDCStateFormat      = "OIDC_STATE_%s"
+	OIDCStateMaxAge      = 600
+	OIDCStateCookie      = "oidc_state"
+	OIDCTimeout          = 10
+	OIDCClockSkew        = 60
+	OIDCUserNameAttempts = 5
+	OIDCReauthFormat     = "OIDC_REAUTH_%s"
+	OIDCReauthMaxAge     = 300
+
+	// Logging config keys, the output is stdout, file or both and the encoding is json or console
+	LogLevel      = "LOG_LEVEL"
+	LogOutput     = "LOG_OUTPUT"
+	LogEncoding   = "LOG_ENCODING"
+	LogFile       = "LOG_FILE"
+	LogMaxSizeMB  = "LOG_MAX_SIZE_MB"
+	LogMaxBackups = "LOG_MAX_BACKUPS"
+	LogMaxAgeDays = "LOG_MAX_AGE_DAYS"
+	LogCompress   = "LOG_COMPRESS"
+
+	// Tracing config keys, the exporter is none, stdout or otlp
+	TraceExporter        = "TRACE_EXPORTER"
+	TraceOTLPEndpoint    = "TRACE_OTLP_ENDPOINT"
+	TraceOTLPInsecure    = "TRACE_OTLP_INSECURE"
+	TraceSampleRatio     = "TRACE_SAMPLE_RATIO"
+	TraceServiceName     = "TRACE_SERVICE_NAME"
+	TraceInstrumentation = "gin-photo-gallery-storage"
+	TraceContextKey      = "trace:context"
+
+	// Request id header, a request id sent by the client is kept
+	RequestIDHeader = "X-Request-ID"
+
+	// Rate limit constants, each limit is configured as RATE_LIMIT_<NAME> in the form <requests>/<seconds>
+	RateLimitFormat    = "RATE_LIMIT_%s"
+	RateLimitKeyFormat = "RATE_%s_%s"
+	RateLimitGlobal    = "GLOBAL"
+	RateLimitAuth      = "AUTH"
+	RateLimitUpload    = "UPLOAD"
+	RateLimitList      = "LIST"
+
+	// Mail constants, the mail tokens are single-use and kept in redis until they expire
+	MailDriver               = "MAIL_DRIVER"
+	MailDriverSMTP           = "smtp"
+	MailDriverFile           = "file"
+	MailFrom                 = "MAIL_FROM"
+	MailDir                  = "MAIL_DIR"
+	DefaultMailDir           = "logs/mail"
+	SMTPHost                 = "SMTP_HOST"
+	SMTPPort                 = "SMTP_PORT"
+	SMTPUser                 = "SMTP_USER"
+	SMTPPassword             = "SMTP_PASSWORD"
+	MailVerifyURL            = "MAIL_VERIFY_URL"
+	MailResetURL             = "MAIL_RESET_URL"
+	RequireEmailVerification = "REQUIRE_EMAIL_VERIFICATION"
+	VerifyTokenFormat        = "VERIFY_%s"
+	VerifyTokenMaxAge        = 86400
+	ResetTokenFormat         = "RESET_%s"
+	ResetTokenMaxAge         = 3600
 
 	// Redis constants
 	RedisHost = "REDIS_HOST"
@@ -41,4 +173,75 @@ const (
 	PhotoURLUpdateChannel = "PHOTO_URL_UPDATE"
 	PhotoDeleteChannel    = "PHOTO_DELETE"
 	PhotoUpdateIDFormat   = "photo_%d"
+	UploadStatusMaxAge    = 86400
+
+	// Resumable upload constants
+	UploadSessionFormat = "%s_session"
+	UploadChunksFormat  = "%s_chunks"
+	UploadSessionMaxAge = 86400
+	UploadChunkMaxSize  = 8 * 1024 * 1024
+	UploadMaxChunks     = 10000
+	UploadCleanInterval = 3600
+
+	// Batch upload constants, the max bytes counts the uncompressed size of the archived files
+	BatchIDFormat        = "batch_%s"
+	BatchMaxAge          = 86400
+	BatchMaxFiles        = 1000
+	BatchMaxBytes        = "BATCH_MAX_BYTES"
+	DefaultBatchMaxBytes = "1073741824"
+	BatchPhotoField      = "photos"
+	BatchArchiveField    = "archive"
+	BatchOwnerField      = "owner"
+
+	// Export constants
+	ExportIDFormat       = "export_%s"
+	ExportBlobFormat     = "exports/%s.zip"
+	ExportMaxAge         = 86400
+	ExportExpiryKey      = "EXPORT_EXPIRY"
+	ExportCleanInterval  = 3600
+	ExportManifestName   = "manifest.json"
+	ExportPhotoDirectory = "photos/"
+
+	// Account export constants, the photos of each bucket are in its own directory
+	AccountExportManifestName    = "account.json"
+	AccountExportBucketDirectory = "buckets/%d/photos/"
+
+	// Trash constants
+	TrashRetentionHours              = "TRASH_RETENTION_HOURS"
+	TrashPurgeIntervalMinutes        = "TRASH_PURGE_INTERVAL_MINUTES"
+	DefaultTrashRetentionHours       = "720"
+	DefaultTrashPurgeIntervalMinutes = "60"
+
+	// Quota constants, 0 means no limit
+	QuotaUserMaxPhotos = "QUOTA_USER_MAX_PHOTOS"
+	QuotaUserMaxBytes  = "QUOTA_USER_MAX_BYTES"
+	DefaultQuota       = "0"
+
+	// Upload validation constants, the allowed types are a comma separated list and empty means all
+	UploadMaxFileSize        = "UPLOAD_MAX_FILE_SIZE"
+	UploadAllowedTypes       = "UPLOAD_ALLOWED_TYPES"
+	UploadMaxPixels          = "UPLOAD_MAX_PIXELS"
+	DefaultUploadMaxFileSize = "52428800"
+	DefaultUploadMaxPixels   = "100000000"
+	UploadSniffSize          = 32
+
+	// Photo version constants, the max versions of a bucket falls back to the config
+	PhotoMaxVersions        = "PHOTO_MAX_VERSIONS"
+	DefaultPhotoMaxVersions = "10"
+
+	// Transform constants, the allowed sizes are a comma separated list of WxH
+	TransformMaxDimension        = "TRANSFORM_MAX_DIMENSION"
+	TransformAllowedSizes        = "TRANSFORM_ALLOWED_SIZES"
+	TransformSecret              = "TRANSFORM_SECRET"
+	DefaultTransformMaxDimension = "4096"
+	TransformDefaultQuality      = 85
+	TransformFitContain          = "contain"
+	TransformFitCover            = "cover"
+	TransformFitFill             = "fill"
+	VariantBlobFormat            = "variants/%s/%s.%s"
+	PhotoVariantsFormat          = "%s_variants"
+
+	// Photo content constants
+	PhotoRenditionOriginal = "original"
+	PhotoDefaultMIME       = "application/octet-stream"
 )
@@ -2,40 +2,121 @@ package constant
 
 const (
 	// user related response
-	UserAlreadyExist   = 1001
-	UserAddSuccess     = 1002
-	UserAuthSuccess    = 1003
-	UserAuthError      = 1004
-	UserAuthTimeout    = 1005
-	UserSignoutSuccess = 1006
+	UserAlreadyExist      = 1001
+	UserAddSuccess        = 1002
+	UserAuthSuccess       = 1003
+	UserAuthError         = 1004
+	UserAuthTimeout       = 1005
+	UserSignoutSuccess    = 1006
+	UserUsageSuccess      = 1007
+	UserDenied            = 1008
+	UserDisabled          = 1009
+	UserGetSuccess        = 1010
+	UserUpdateSuccess     = 1011
+	UserNotExist          = 1012
+	TokenAddSuccess       = 1013
+	TokenGetSuccess       = 1014
+	TokenRevokeSuccess    = 1015
+	TokenNotExist         = 1016
+	TokenInvalid          = 1017
+	UserVerifySuccess     = 1018
+	UserVerifyMailSent    = 1019
+	UserVerified          = 1020
+	UserUnverified        = 1021
+	ResetMailSent         = 1022
+	ResetSuccess          = 1023
+	MailTokenInvalid      = 1024
+	UserLocked            = 1025
+	UserUnlockSuccess     = 1026
+	MFARequired           = 1027
+	MFAEnrollSuccess      = 1028
+	MFAEnableSuccess      = 1029
+	MFADisableSuccess     = 1030
+	MFACodeInvalid        = 1031
+	MFAEnabled            = 1032
+	MFANotEnabled         = 1033
+	OIDCProvidersSuccess  = 1034
+	OIDCProviderNotExist  = 1035
+	OIDCLoginError        = 1036
+	OIDCNotLinked         = 1037
+	PasswordWrong         = 1038
+	EmailAlreadyExist     = 1039
+	PasswordUpdateSuccess = 1040
+	UserDeleteSuccess     = 1041
+	ReauthRequired        = 1042
 
 	// JWT related response
 	JwtGenerationError = 2001
 	JwtMissingError    = 2002
 	JwtParseError      = 2003
+	JwtRefreshSuccess  = 2004
+	RefreshTokenError  = 2005
+	RefreshTokenReused = 2006
 
 	//Bucket related response
-	BucketAlreadyExist  = 3001
-	BucketAddSuccess    = 3002
-	BucketNotExist      = 3003
-	BucketDeleteSuccess = 3004
-	BucketUpdateSuccess = 3005
-	BucketGetSuccess    = 3006
+	BucketAlreadyExist    = 3001
+	BucketAddSuccess      = 3002
+	BucketNotExist        = 3003
+	BucketDeleteSuccess   = 3004
+	BucketUpdateSuccess   = 3005
+	BucketGetSuccess      = 3006
+	BucketExportInProcess = 3007
+	BucketExportSuccess   = 3008
+	BucketExportError     = 3009
+	BucketExportNotExist  = 3010
+	BucketInTrash         = 3011
+	BucketRestoreSuccess  = 3012
+	CollaboratorSuccess   = 3013
+	CollaboratorExist     = 3014
+	CollaboratorNotExist  = 3015
 
 	// Photo related response
-	PhotoAlreadyExist  = 4001
-	PhotoAddInProcess = 4002
-	PhotoUploadSuccess = 4003
-	PhotoUploadError   = 4004
-	PhotoNotExist      = 4005
-	PhotoDeleteSuccess = 4006
-	PhotoUpdateSuccess = 4007
-	PhotoGetSuccess    = 4008
+	PhotoAlreadyExist     = 4001
+	PhotoAddInProcess     = 4002
+	PhotoUploadSuccess    = 4003
+	PhotoUploadError      = 4004
+	PhotoNotExist         = 4005
+	PhotoDeleteSuccess    = 4006
+	PhotoUpdateSuccess    = 4007
+	PhotoGetSuccess       = 4008
+	PhotoChunkSuccess     = 4009
+	PhotoUploadNotExist   = 4010
+	PhotoUploadIncomplete = 4011
+	PhotoUploadAborted    = 4012
+	PhotoBatchNotExist    = 4013
+	PhotoBatchTooLarge    = 4014
+	PhotoBulkSuccess      = 4015
+	PhotoBulkAborted      = 4016
+	PhotoInTrash          = 4017
+	PhotoRestoreSuccess   = 4018
+	PhotoQuotaExceeded    = 4019
+	PhotoTypeNotAllowed   = 4020
+	PhotoFileCorrupt      = 4021
+	PhotoFileTooLarge     = 4022
+	PhotoReplaceSuccess   = 4023
+	PhotoVersionsSuccess  = 4024
+	PhotoVersionRestored  = 4025
+	PhotoVersionNotExist  = 4026
+	PhotoNotReady         = 4027
+	PhotoTransformInvalid = 4028
+	PhotoTransformDenied  = 4029
+	PhotoTransformFailed  = 4030
 
 	// Internal server response
 	InternalServerError = 5001
-	PaginationSuccess    = 6001
-	InvalidParams        = 7001
+	PaginationSuccess   = 6001
+	InvalidParams       = 7001
+
+	// Trash related response
+	TrashGetSuccess   = 8001
+	TrashPurgeSuccess = 8002
+
+	// Rate limit related response
+	RequestThrottled = 9001
+
+	// Logging related response
+	LogLevelGetSuccess    = 9101
+	LogLevelUpdateSuccess = 9102
 )
 
 var Message map[int]string
@@ -49,22 +130,100 @@ func init() {
 	Message[UserAuthError] = "User authentication fail."
 	Message[UserAuthTimeout] = "User authentication timeout."
 	Message[UserSignoutSuccess] = "User sign out success."
+	Message[UserUsageSuccess] = "User usage get success."
+	Message[UserDenied] = "User has no permission."
+	Message[UserDisabled] = "User is disabled."
+	Message[UserGetSuccess] = "User get success."
+	Message[UserUpdateSuccess] = "User update success."
+	Message[UserNotExist] = "User does not exist."
+	Message[TokenAddSuccess] = "Add api token success."
+	Message[TokenGetSuccess] = "Get api tokens success."
+	Message[TokenRevokeSuccess] = "Revoke api token success."
+	Message[TokenNotExist] = "API token does not exist."
+	Message[TokenInvalid] = "API token is invalid or revoked."
+	Message[UserVerifySuccess] = "Email verify success."
+	Message[UserVerifyMailSent] = "Verification mail sent."
+	Message[UserVerified] = "Email is already verified."
+	Message[UserUnverified] = "Email is not verified yet."
+	Message[ResetMailSent] = "If the email is registered, a password reset mail is sent."
+	Message[ResetSuccess] = "Password reset success, please log in again."
+	Message[MailTokenInvalid] = "Token is invalid or expired."
+	Message[UserLocked] = "Too many failed logins, please try again later."
+	Message[UserUnlockSuccess] = "User unlock success."
+	Message[MFARequired] = "Two-factor code is required to complete the login."
+	Message[MFAEnrollSuccess] = "Two-factor enroll success, confirm it with a code."
+	Message[MFAEnableSuccess] = "Two-factor enable success, keep the recovery codes safe."
+	Message[MFADisableSuccess] = "Two-factor disable success."
+	Message[MFACodeInvalid] = "Two-factor code or login token is invalid."
+	Message[MFAEnabled] = "Two-factor is already enabled."
+	Message[MFANotEnabled] = "Two-factor is not enabled."
+	Message[OIDCProvidersSuccess] = "Get login providers success."
+	Message[OIDCProviderNotExist] = "Login provider does not exist."
+	Message[OIDCLoginError] = "Login with the provider failed, please try again."
+	Message[OIDCNotLinked] = "No user is linked to this account of the provider."
+	Message[PasswordWrong] = "Password is wrong."
+	Message[EmailAlreadyExist] = "Email already exists."
+	Message[PasswordUpdateSuccess] = "Password update success, please log in again."
+	Message[UserDeleteSuccess] = "User delete success."
+	Message[ReauthRequired] = "Log in by the provider again or give a two-factor code to confirm."
 	Message[JwtGenerationError] = "JWT generation fail."
 	Message[JwtMissingError] = "JWT is missing."
+	Message[JwtParseError] = "JWT is invalid or expired."
+	Message[JwtRefreshSuccess] = "JWT refresh success."
+	Message[RefreshTokenError] = "Refresh token is missing, invalid or expired."
+	Message[RefreshTokenReused] = "Refresh token was already used, please log in again."
 	Message[InternalServerError] = "Internal server error."
+	Message[PaginationSuccess] = "Pagination success."
 	Message[BucketAlreadyExist] = "Bucket already exists."
 	Message[BucketAddSuccess] = "Add bucket success."
 	Message[BucketNotExist] = "Bucket does not exist."
 	Message[BucketDeleteSuccess] = "Bucket delete success."
 	Message[BucketUpdateSuccess] = "Bucket update success."
 	Message[BucketGetSuccess] = "Bucket get success."
+	Message[BucketExportInProcess] = "Bucket export is in process."
+	Message[BucketExportSuccess] = "Bucket export success."
+	Message[BucketExportError] = "Bucket export error."
+	Message[BucketExportNotExist] = "Bucket export does not exist."
+	Message[BucketInTrash] = "Bucket with the same name is in trash."
+	Message[CollaboratorSuccess] = "Bucket collaborators update success."
+	Message[CollaboratorExist] = "Collaborator already exists."
+	Message[CollaboratorNotExist] = "Collaborator does not exist."
+	Message[BucketRestoreSuccess] = "Bucket restore success."
 	Message[PhotoAlreadyExist] = "Photo already exists."
 	Message[PhotoAddInProcess] = "Adding photo is in process."
 	Message[PhotoUploadSuccess] = "Photo upload success."
 	Message[PhotoUploadError] = "Photo upload error."
 	Message[PhotoNotExist] = "Photo does not exist."
 	Message[PhotoDeleteSuccess] = "Photo delete success."
+	Message[PhotoUpdateSuccess] = "Photo update success."
 	Message[PhotoGetSuccess] = "Photo get success."
+	Message[PhotoChunkSuccess] = "Photo chunk upload success."
+	Message[PhotoUploadNotExist] = "Photo upload does not exist."
+	Message[PhotoUploadIncomplete] = "Photo upload is incomplete."
+	Message[PhotoUploadAborted] = "Photo upload aborted."
+	Message[PhotoBatchNotExist] = "Photo batch does not exist."
+	Message[PhotoBatchTooLarge] = "Photo batch has too many files or is too large."
+	Message[PhotoBulkSuccess] = "Photo bulk operation success."
+	Message[PhotoBulkAborted] = "Photo bulk operation aborted."
+	Message[PhotoInTrash] = "Photo with the same name is in trash."
+	Message[PhotoRestoreSuccess] = "Photo restore success."
+	Message[PhotoQuotaExceeded] = "Storage quota exceeded."
+	Message[PhotoTypeNotAllowed] = "Photo file type is not allowed."
+	Message[PhotoFileCorrupt] = "Photo file is corrupt."
+	Message[PhotoFileTooLarge] = "Photo file is too large."
+	Message[PhotoReplaceSuccess] = "Replace photo successfully."
+	Message[PhotoVersionsSuccess] = "Get photo versions successfully."
+	Message[PhotoVersionRestored] = "Restore photo version successfully."
+	Message[PhotoVersionNotExist] = "Photo version does not exist."
+	Message[PhotoNotReady] = "Photo is still uploading."
+	Message[PhotoTransformInvalid] = "Invalid photo transform parameters."
+	Message[PhotoTransformDenied] = "Photo transform is not signed or allowed."
+	Message[PhotoTransformFailed] = "Photo format cannot be transformed."
+	Message[TrashGetSuccess] = "Trash get success."
+	Message[TrashPurgeSuccess] = "Trash purge success."
+	Message[RequestThrottled] = "Too many requests, please slow down."
+	Message[LogLevelGetSuccess] = "Log level get success."
+	Message[LogLevelUpdateSuccess] = "Log level update success."
 }
 
 // GetMessage func to get response description according to the code
@@ -75,3 +234,12 @@ func GetMessage(code int) string {
 	}
 	return ""
 }
+
+// GetLocalizedMessage func to get response description in a language,
+// the english description is used if the language or the code is not translated.
+func GetLocalizedMessage(code int, lang string) string {
+	if msg, ok := Messages[lang][code]; ok {
+		return msg
+	}
+	return GetMessage(code)
+}
@@ -0,0 +1,114 @@
+package constant
+
+// DefaultLanguage is the language of Message
+const DefaultLanguage = "en"
+
+// Messages is the response descriptions of each supported language
+var Messages = map[string]map[int]string{
+	"zh": MessageZh,
+}
+
+// MessageZh is the chinese response descriptions
+var MessageZh = map[int]string{
+	InvalidParams:         "参数无效。",
+	UserAlreadyExist:      "用户已存在。",
+	UserAddSuccess:        "添加用户成功。",
+	UserAuthSuccess:       "用户认证成功。",
+	UserAuthError:         "用户认证失败。",
+	UserAuthTimeout:       "用户认证已过期。",
+	UserSignoutSuccess:    "用户退出成功。",
+	UserUsageSuccess:      "获取用户用量成功。",
+	UserDenied:            "用户没有权限。",
+	UserDisabled:          "用户已被禁用。",
+	UserGetSuccess:        "获取用户成功。",
+	UserUpdateSuccess:     "更新用户成功。",
+	UserNotExist:          "用户不存在。",
+	TokenAddSuccess:       "添加 API 令牌成功。",
+	TokenGetSuccess:       "获取 API 令牌成功。",
+	TokenRevokeSuccess:    "吊销 API 令牌成功。",
+	TokenNotExist:         "API 令牌不存在。",
+	TokenInvalid:          "API 令牌无效或已被吊销。",
+	UserVerifySuccess:     "邮箱验证成功。",
+	UserVerifyMailSent:    "验证邮件已发送。",
+	UserVerified:          "邮箱已经验证过了。",
+	UserUnverified:        "邮箱尚未验证。",
+	ResetMailSent:         "如果该邮箱已注册，密码重置邮件已发送。",
+	ResetSuccess:          "密码重置成功，请重新登录。",
+	MailTokenInvalid:      "令牌无效或已过期。",
+	UserLocked:            "登录失败次数过多，请稍后再试。",
+	UserUnlockSuccess:     "解锁用户成功。",
+	MFARequired:           "需要两步验证码才能完成登录。",
+	MFAEnrollSuccess:      "两步验证登记成功，请用验证码确认。",
+	MFAEnableSuccess:      "两步验证开启成功，请妥善保管恢复码。",
+	MFADisableSuccess:     "两步验证关闭成功。",
+	MFACodeInvalid:        "两步验证码或登录令牌无效。",
+	MFAEnabled:            "两步验证已经开启。",
+	MFANotEnabled:         "两步验证尚未开启。",
+	OIDCProvidersSuccess:  "获取登录提供方成功。",
+	OIDCProviderNotExist:  "登录提供方不存在。",
+	OIDCLoginError:        "通过提供方登录失败，请重试。",
+	OIDCNotLinked:         "该提供方账号没有关联任何用户。",
+	PasswordWrong:         "密码错误。",
+	EmailAlreadyExist:     "邮箱已存在。",
+	PasswordUpdateSuccess: "密码修改成功，请重新登录。",
+	UserDeleteSuccess:     "删除用户成功。",
+	ReauthRequired:        "请重新通过提供方登录或提供两步验证码以确认。",
+	JwtGenerationError:    "JWT 生成失败。",
+	JwtMissingError:       "缺少 JWT。",
+	JwtParseError:         "JWT 无效或已过期。",
+	JwtRefreshSuccess:     "JWT 刷新成功。",
+	RefreshTokenError:     "刷新令牌缺失、无效或已过期。",
+	RefreshTokenReused:    "刷新令牌已被使用，请重新登录。",
+	InternalServerError:   "服务器内部错误。",
+	PaginationSuccess:     "分页成功。",
+	BucketAlreadyExist:    "存储桶已存在。",
+	BucketAddSuccess:      "添加存储桶成功。",
+	BucketNotExist:        "存储桶不存在。",
+	BucketDeleteSuccess:   "删除存储桶成功。",
+	BucketUpdateSuccess:   "更新存储桶成功。",
+	BucketGetSuccess:      "获取存储桶成功。",
+	BucketExportInProcess: "存储桶正在导出。",
+	BucketExportSuccess:   "存储桶导出成功。",
+	BucketExportError:     "存储桶导出失败。",
+	BucketExportNotExist:  "存储桶导出不存在。",
+	BucketInTrash:         "回收站中有同名的存储桶。",
+	BucketRestoreSuccess:  "恢复存储桶成功。",
+	CollaboratorSuccess:   "更新存储桶协作者成功。",
+	CollaboratorExist:     "协作者已存在。",
+	CollaboratorNotExist:  "协作者不存在。",
+	PhotoAlreadyExist:     "照片已存在。",
+	PhotoAddInProcess:     "照片正在添加。",
+	PhotoUploadSuccess:    "照片上传成功。",
+	PhotoUploadError:      "照片上传失败。",
+	PhotoNotExist:         "照片不存在。",
+	PhotoDeleteSuccess:    "删除照片成功。",
+	PhotoUpdateSuccess:    "更新照片成功。",
+	PhotoGetSuccess:       "获取照片成功。",
+	PhotoChunkSuccess:     "照片分块上传成功。",
+	PhotoUploadNotExist:   "照片上传不存在。",
+	PhotoUploadIncomplete: "照片上传尚未完成。",
+	PhotoUploadAborted:    "照片上传已取消。",
+	PhotoBatchNotExist:    "照片批次不存在。",
+	PhotoBatchTooLarge:    "照片批次中的文件过多或总大小过大。",
+	PhotoBulkSuccess:      "照片批量操作成功。",
+	PhotoBulkAborted:      "照片批量操作已中止。",
+	PhotoInTrash:          "回收站中有同名的照片。",
+	PhotoRestoreSuccess:   "恢复照片成功。",
+	PhotoQuotaExceeded:    "超出存储配额。",
+	PhotoTypeNotAllowed:   "不允许的照片文件类型。",
+	PhotoFileCorrupt:      "照片文件已损坏。",
+	PhotoFileTooLarge:     "照片文件过大。",
+	PhotoReplaceSuccess:   "替换照片成功。",
+	PhotoVersionsSuccess:  "获取照片版本成功。",
+	PhotoVersionRestored:  "恢复照片版本成功。",
+	PhotoVersionNotExist:  "照片版本不存在。",
+	PhotoNotReady:         "照片仍在上传中。",
+	PhotoTransformInvalid: "照片变换参数无效。",
+	PhotoTransformDenied:  "照片变换未签名或不被允许。",
+	PhotoTransformFailed:  "该照片格式无法变换。",
+	TrashGetSuccess:       "获取回收站成功。",
+	TrashPurgeSuccess:     "清理回收站成功。",
+	RequestThrottled:      "请求过于频繁，请稍后再试。",
+	LogLevelGetSuccess:    "获取日志级别成功。",
+	LogLevelUpdateSuccess: "更新日志级别成功。",
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
@@ -8,6 +9,7 @@ import (
 	"github.com/walk1ng/gin-photo-gallery-storage/constant"
 
 	"github.com/walk1ng/gin-photo-gallery-storage/routers"
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
 )
 
 func main() {
@@ -21,6 +23,7 @@ func main() {
 		MaxHeaderBytes: 1 << 20,
 	}
 
-	// run and listen
+	// run and listen, the spans not exported yet are flushed when the server stops
 	server.ListenAndServe()
+	utils.ShutdownTracing(context.Background())
 }
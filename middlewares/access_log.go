@@ -0,0 +1,36 @@
+package middlewares
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+)
+
+// GetAccessLogMiddleware func is a wrapper func to return an access log middleware,
+// it writes one log line for each request after it is handled.
+func GetAccessLogMiddleware() func(*gin.Context) {
+	return func(context *gin.Context) {
+		start := time.Now()
+		path := context.Request.URL.Path
+		context.Next()
+
+		fields := []zap.Field{
+			zap.String("service", "AccessLog"),
+			zap.String("method", context.Request.Method),
+			zap.String("path", path),
+			zap.String("route", context.FullPath()),
+			zap.Int("status", context.Writer.Status()),
+			zap.Int("size", context.Writer.Size()),
+			zap.Duration("latency", time.Since(start)),
+			zap.String("client_ip", context.ClientIP()),
+			zap.String("user_agent", context.Request.UserAgent()),
+		}
+		if errs := context.Errors.ByType(gin.ErrorTypePrivate).String(); errs != "" {
+			fields = append(fields, zap.String("errors", errs))
+		}
+		utils.GetLogger(context).Info("access", fields...)
+	}
+}
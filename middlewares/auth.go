@@ -1,57 +1,87 @@
 package middlewares
 
 import (
-	"net/http"
+	"strings"
 
 	"go.uber.org/zap"
 
 	"github.com/walk1ng/gin-photo-gallery-storage/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/walk1ng/gin-photo-gallery-storage/apis/response"
 	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/models"
 )
 
 // GetAuthMiddleware func is a wrapper func to return a auth middleware
 func GetAuthMiddleware() func(*gin.Context) {
 	return func(context *gin.Context) {
-		jwtString, err := context.Cookie(constant.Jwt)
+		// personal api tokens are sent in the authorization header instead of the cookie
+		bearer := context.GetHeader("Authorization")
+		if token := strings.TrimPrefix(bearer, constant.BearerPrefix); strings.HasPrefix(token, constant.APITokenPrefix) {
+			checkAPIToken(context, token)
+			return
+		}
+
+		// the jwt is read from the authorization header first, then the cookie
+		var jwtString string
+		var err error
+		if strings.HasPrefix(bearer, constant.BearerPrefix) {
+			jwtString = strings.TrimPrefix(bearer, constant.BearerPrefix)
+		} else {
+			jwtString, err = context.Cookie(constant.Jwt)
+		}
 
-		// cannot find the jwt in cookie, it mean that the user has not loggined yet or cookie missing.
+		// cannot find the jwt, it mean that the user has not loggined yet or cookie missing.
 		if err != nil {
-			utils.AppLogger.Info(err.Error(), zap.String("service", "GetAuthMiddleware()"))
-			context.JSON(http.StatusBadRequest, gin.H{
-				"code": constant.JwtMissingError,
-				"data": make(map[string]string),
-				"msg":  constant.GetMessage(constant.JwtMissingError),
-			})
-			context.Abort()
+			utils.GetLogger(context).Debug(err.Error(), zap.String("service", "GetAuthMiddleware()"))
+			response.Abort(context, constant.JwtMissingError)
 			return
 		}
 
 		// parse jwt
-		claim, err := utils.ParseJWT(jwtString)
+		claim, err := utils.ParseJWT(context.Request.Context(), jwtString)
 		if err != nil {
-			utils.AppLogger.Info(err.Error(), zap.String("service", "GetAuthMiddleware()"))
-			context.JSON(http.StatusBadRequest, gin.H{
-				"code": constant.JwtParseError,
-				"data": make(map[string]string),
-				"msg":  constant.GetMessage(constant.JwtParseError),
-			})
-			context.Abort()
+			utils.GetLogger(context).Debug(err.Error(), zap.String("service", "GetAuthMiddleware()"))
+			response.Abort(context, constant.JwtParseError)
 			return
 		}
 
-		if utils.IsAuthInRedis(claim.UserName) {
-			context.Set("user_name", claim.UserName)
+		if utils.IsAuthInRedis(context.Request.Context(), claim.UserName) {
+			// jwt issued before roles existed belong to normal users
+			if claim.Role == "" {
+				claim.Role = constant.RoleUser
+			}
+			setUser(context, claim.UserName, claim.Role)
 			context.Next()
 		} else {
 			// auth is expired
-			context.JSON(http.StatusBadRequest, gin.H{
-				"code": constant.UserAuthTimeout,
-				"data": make(map[string]string),
-				"msg":  constant.GetMessage(constant.UserAuthTimeout),
-			})
-			context.Abort()
+			response.Abort(context, constant.UserAuthTimeout)
+		}
+	}
+}
+
+// checkAPIToken func authenticate a request by a personal api token
+func checkAPIToken(context *gin.Context, token string) {
+	auth, role, err := models.CheckAPIToken(context.Request.Context(), token)
+	if err != nil {
+		utils.GetLogger(context).Debug(err.Error(), zap.String("service", "checkAPIToken()"))
+		responseCode := constant.TokenInvalid
+		if err == models.ErrAuthDisabled {
+			responseCode = constant.UserDisabled
 		}
+		response.Abort(context, responseCode)
+		return
 	}
+
+	setUser(context, auth.UserName, role)
+	context.Set("api_token", true)
+	context.Next()
+}
+
+// setUser func set the authenticated user of a request and add it to the request logger
+func setUser(context *gin.Context, userName, role string) {
+	context.Set("user_name", userName)
+	context.Set("role", role)
+	context.Request = context.Request.WithContext(utils.WithLogFields(context.Request.Context(), zap.String("user", userName)))
 }
@@ -2,9 +2,9 @@ package middlewares
 
 import (
 	"errors"
-	"net/http"
 	"strconv"
 
+	"github.com/walk1ng/gin-photo-gallery-storage/apis/response"
 	"github.com/walk1ng/gin-photo-gallery-storage/constant"
 
 	"github.com/gin-gonic/gin"
@@ -31,12 +31,7 @@ func GetPaginationMiddleware() func(*gin.Context) {
 		if responseCode == constant.InvalidParams {
 			data := make(map[string]string)
 			data["page"] = pageNo
-			context.JSON(http.StatusBadRequest, gin.H{
-				"code": responseCode,
-				"data": data,
-				"msg":  constant.GetMessage(responseCode),
-			})
-			context.Abort()
+			response.AbortWithData(context, responseCode, data)
 		}
 
 		// forward to the next middleware
@@ -0,0 +1,52 @@
+package middlewares
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walk1ng/gin-photo-gallery-storage/apis/response"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+)
+
+// GetRateLimitMiddleware func is a wrapper func to return a rate limit middleware of a configured limit,
+// the requests are limited per user after the auth middleware and per client ip before it,
+// the client ip is only taken from X-Forwarded-For behind the trusted proxies.
+func GetRateLimitMiddleware(name string) func(*gin.Context) {
+	limit, enabled := utils.GetRateLimit(name)
+	return func(context *gin.Context) {
+		if !enabled {
+			context.Next()
+			return
+		}
+
+		subject := "ip:" + context.ClientIP()
+		if userName := context.GetString("user_name"); userName != "" {
+			subject = "user:" + userName
+		}
+
+		// the requests are not limited while redis is unavailable
+		result, err := utils.TakeRateLimitToken(context.Request.Context(), name, subject, limit)
+		if err != nil {
+			context.Next()
+			return
+		}
+
+		context.Header("RateLimit-Limit", strconv.Itoa(limit.Capacity))
+		context.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		context.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		if !result.Allowed {
+			context.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			response.Abort(context, constant.RequestThrottled)
+			return
+		}
+		context.Next()
+	}
+}
+
+// ceilSeconds func round a duration up to whole seconds
+func ceilSeconds(duration time.Duration) int {
+	return int(math.Ceil(duration.Seconds()))
+}
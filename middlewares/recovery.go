@@ -0,0 +1,25 @@
+package middlewares
+
+import (
+	"go.uber.org/zap"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walk1ng/gin-photo-gallery-storage/apis/response"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+)
+
+// GetRecoveryMiddleware func is a wrapper func to return a recovery middleware,
+// a panic in a handler is logged with its stack and answered as an internal error.
+func GetRecoveryMiddleware() func(*gin.Context) {
+	return func(context *gin.Context) {
+		defer func() {
+			if err := recover(); err != nil {
+				utils.GetLogger(context).Error("panic recovered.", zap.String("service", "GetRecoveryMiddleware()"),
+					zap.Any("error", err), zap.Stack("stacktrace"))
+				response.Abort(context, constant.InternalServerError)
+			}
+		}()
+		context.Next()
+	}
+}
@@ -0,0 +1,41 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"go.uber.org/zap"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+)
+
+// requestIDPattern is the form of the request ids accepted from clients
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// GetRequestIDMiddleware func is a wrapper func to return a request id middleware,
+// the request id sent by the client is kept and a new one is generated otherwise,
+// it is returned in the response and carried by the request logger.
+func GetRequestIDMiddleware() func(*gin.Context) {
+	return func(context *gin.Context) {
+		requestID := context.GetHeader(constant.RequestIDHeader)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+
+		context.Set("request_id", requestID)
+		context.Header(constant.RequestIDHeader, requestID)
+		logger := utils.AppLogger.With(zap.String("request_id", requestID))
+		context.Request = context.Request.WithContext(utils.WithLogger(context.Request.Context(), logger))
+		context.Next()
+	}
+}
+
+// newRequestID func generate a random request id
+func newRequestID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,25 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/walk1ng/gin-photo-gallery-storage/apis/response"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+)
+
+// GetRoleMiddleware func is a wrapper func to return a middleware which only lets the given roles pass,
+// it must run after the auth middleware which sets the role.
+func GetRoleMiddleware(roles ...string) func(*gin.Context) {
+	return func(context *gin.Context) {
+		role := context.GetString("role")
+		for _, allowed := range roles {
+			if role == allowed {
+				context.Next()
+				return
+			}
+		}
+
+		data := make(map[string]string)
+		data["role"] = role
+		response.AbortWithData(context, constant.UserDenied, data)
+	}
+}
@@ -0,0 +1,53 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// GetTracingMiddleware func is a wrapper func to return a tracing middleware,
+// each request runs in a server span continuing the trace sent by the client if any.
+func GetTracingMiddleware() func(*gin.Context) {
+	return func(context *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(context.Request.Context(), propagation.HeaderCarrier(context.Request.Header))
+		route := context.FullPath()
+		if route == "" {
+			route = context.Request.URL.Path
+		}
+		ctx, span := utils.Tracer.Start(ctx, fmt.Sprintf("%s %s", context.Request.Method, route),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", context.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", context.Request.URL.Path),
+				attribute.String("client.address", context.ClientIP()),
+			))
+		defer span.End()
+
+		// the log lines of the request can be found from the trace
+		if span.SpanContext().IsValid() {
+			ctx = utils.WithLogFields(ctx, zap.String("trace_id", span.SpanContext().TraceID().String()))
+		}
+		context.Request = context.Request.WithContext(ctx)
+		context.Next()
+
+		status := context.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if userName := context.GetString("user_name"); userName != "" {
+			span.SetAttributes(attribute.String("enduser.id", userName))
+		}
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
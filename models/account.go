@@ -0,0 +1,234 @@
+package models
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+)
+
+// AccountExportBucket struct describe an exported bucket of the user in the account export
+type AccountExportBucket struct {
+	Bucket        Bucket               `json:"bucket"`
+	Photos        []ExportManifestItem `json:"photos"`
+	Versions      []PhotoVersion       `json:"versions"`
+	Collaborators []CollaboratorInfo   `json:"collaborators"`
+}
+
+// AccountExport struct is the account.json of an account export, everything the user owns
+type AccountExport struct {
+	Auth       Auth                  `json:"auth"`
+	ExportedAt time.Time             `json:"exported_at"`
+	Buckets    []AccountExportBucket `json:"buckets"`
+	SharedWith []Collaborator        `json:"shared_with"`
+	APITokens  []APIToken            `json:"api_tokens"`
+	Identities []AuthIdentity        `json:"identities"`
+	Failed     []uint                `json:"failed"`
+}
+
+var ErrWrongPassword = apperr.New(constant.PasswordWrong, "wrong password")
+var ErrEmailExist = apperr.New(constant.EmailAlreadyExist, "email already exists")
+var ErrReauthRequired = apperr.New(constant.ReauthRequired, "recent oidc login or totp code is required")
+
+// UpdateAuthEmail func change the email of the user, the new email has to be verified again.
+// The user confirms it as checked by confirmAuth.
+func UpdateAuthEmail(ctx context.Context, authID uint, password, code, email string) (*Auth, error) {
+	trx := withContext(ctx, db).Begin()
+	defer trx.Commit()
+
+	auth := Auth{}
+	trx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", authID).First(&auth)
+	if auth.ID == 0 {
+		return nil, ErrNoSuchAuth
+	}
+	if err := confirmAuth(ctx, &auth, password, code); err != nil {
+		return nil, err
+	}
+
+	other := Auth{}
+	trx.Where("email = ? AND id <> ?", email, authID).First(&other)
+	if other.ID > 0 {
+		return nil, ErrEmailExist
+	}
+
+	auth.Email = email
+	auth.EmailVerified = false
+	err := trx.Model(&auth).UpdateColumns(map[string]interface{}{
+		"email":          auth.Email,
+		"email_verified": auth.EmailVerified,
+	}).Error
+	if err != nil {
+		trx.Rollback()
+		return nil, err
+	}
+	return &auth, nil
+}
+
+// ChangePassword func change the password of the user by the current one, all logins of the user are ended.
+// The users without a password, provisioned by an oidc provider, set one by the password reset.
+func ChangePassword(ctx context.Context, authID uint, password, newPassword string) (*Auth, error) {
+	auth, err := GetAuthByID(ctx, authID)
+	if err != nil {
+		return nil, err
+	}
+	if auth.Password == "" || auth.Password != hashPassword(password) {
+		return nil, ErrWrongPassword
+	}
+
+	if err := withContext(ctx, db).Model(auth).UpdateColumn("password", hashPassword(newPassword)).Error; err != nil {
+		return nil, err
+	}
+	utils.RemoveAuthFromRedis(ctx, auth.UserName)
+	utils.RevokeRefreshTokens(ctx, auth.UserName)
+	return auth, nil
+}
+
+// StartAccountExport func export everything the user owns to a zip blob in the background
+func StartAccountExport(ctx context.Context, auth *Auth) (string, error) {
+	return startExport(ctx, auth.UserName, 0, func(ctx context.Context, w io.Writer) error {
+		return WriteAccountArchive(ctx, w, auth)
+	})
+}
+
+// WriteAccountArchive func write the photos of all buckets of the user and an account.json
+// with the profile and the metadata of everything the user owns as a zip archive.
+func WriteAccountArchive(ctx context.Context, w io.Writer, auth *Auth) error {
+	export := AccountExport{
+		Auth:       *auth,
+		ExportedAt: time.Now(),
+		Buckets:    make([]AccountExportBucket, 0),
+		SharedWith: make([]Collaborator, 0),
+		APITokens:  make([]APIToken, 0),
+		Identities: make([]AuthIdentity, 0),
+		Failed:     make([]uint, 0),
+	}
+	err := withContext(ctx, db).Where("auth_id = ?", auth.ID).Find(&export.SharedWith).Error
+	if err == nil {
+		err = withContext(ctx, db).Where("auth_id = ?", auth.ID).Find(&export.APITokens).Error
+	}
+	if err == nil {
+		err = withContext(ctx, db).Where("auth_id = ?", auth.ID).Find(&export.Identities).Error
+	}
+	buckets := make([]Bucket, 0)
+	if err == nil {
+		err = withContext(ctx, db).Where("auth_id = ?", auth.ID).Order("id").Find(&buckets).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	zipWriter := zip.NewWriter(w)
+	for _, bucket := range buckets {
+		photos, err := getExportPhotos(ctx, bucket.ID, "")
+		if err != nil {
+			return err
+		}
+		directory := fmt.Sprintf(constant.AccountExportBucketDirectory, bucket.ID)
+		items, failed, err := writeArchivePhotos(ctx, zipWriter, photos, directory)
+		if err != nil {
+			return err
+		}
+		export.Failed = append(export.Failed, failed...)
+
+		exportBucket := AccountExportBucket{Bucket: bucket, Photos: items, Versions: make([]PhotoVersion, 0)}
+		photoIDs := make([]uint, 0, len(photos))
+		for _, photo := range photos {
+			photoIDs = append(photoIDs, photo.ID)
+		}
+		if len(photoIDs) > 0 {
+			if err := withContext(ctx, db).Where("photo_id IN (?)", photoIDs).Order("photo_id, version").Find(&exportBucket.Versions).Error; err != nil {
+				return err
+			}
+		}
+		if exportBucket.Collaborators, err = GetCollaborators(ctx, bucket.ID); err != nil {
+			return err
+		}
+		export.Buckets = append(export.Buckets, exportBucket)
+	}
+
+	if err := writeArchiveJSON(zipWriter, constant.AccountExportManifestName, export); err != nil {
+		return err
+	}
+	return zipWriter.Close()
+}
+
+// DeleteAuth func permanently delete the user with the buckets, photos, exports and blobs the user owns
+// and every other record of the user. The user confirms it as checked by confirmAuth.
+func DeleteAuth(ctx context.Context, authID uint, password, code string) error {
+	auth, err := GetAuthByID(ctx, authID)
+	if err != nil {
+		return err
+	}
+	if err := confirmAuth(ctx, auth, password, code); err != nil {
+		return err
+	}
+
+	// trash everything the user owns and purge it together with the trash of the user
+	trx := withContext(ctx, db).Begin()
+	now := time.Now()
+	err = trx.Model(&Photo{}).Where("auth_id = ?", authID).UpdateColumn("deleted_at", now).Error
+	if err == nil {
+		err = trx.Model(&Bucket{}).Where("auth_id = ?", authID).UpdateColumn("deleted_at", now).Error
+	}
+	if err != nil {
+		trx.Rollback()
+		return err
+	}
+	if err := trx.Commit().Error; err != nil {
+		return err
+	}
+	if err := PurgeTrash(ctx, authID, now.Add(time.Second)); err != nil {
+		return err
+	}
+
+	trx = withContext(ctx, db).Begin()
+	for _, model := range []interface{}{Collaborator{}, APIToken{}, RecoveryCode{}, AuthIdentity{}} {
+		if err := trx.Where("auth_id = ?", authID).Delete(model).Error; err != nil {
+			trx.Rollback()
+			return err
+		}
+	}
+	if err := trx.Delete(auth).Error; err != nil {
+		trx.Rollback()
+		return err
+	}
+	if err := trx.Commit().Error; err != nil {
+		return err
+	}
+
+	deleteUserExports(ctx, auth.UserName)
+	utils.RemoveAuthFromRedis(ctx, auth.UserName)
+	utils.RevokeRefreshTokens(ctx, auth.UserName)
+	utils.ResetLoginFailures(ctx, auth.UserName)
+	utils.GetLogger(ctx).Info("auth deleted.", zap.String("service", "DeleteAuth()"), zap.Uint("auth_id", authID))
+	return nil
+}
+
+// confirmAuth func check the user confirmed a change of the account by the current password,
+// the users without one, provisioned by an oidc provider, give a totp or recovery code when
+// totp is enabled and otherwise must have logged in by the provider just before.
+func confirmAuth(ctx context.Context, auth *Auth, password, code string) error {
+	if auth.Password != "" {
+		if auth.Password != hashPassword(password) {
+			return ErrWrongPassword
+		}
+		return nil
+	}
+	if auth.TOTPEnabled {
+		if code == "" {
+			return ErrReauthRequired
+		}
+		return CheckSecondFactor(ctx, auth, code)
+	}
+	if !utils.UseOIDCReauth(ctx, auth.UserName) {
+		return ErrReauthRequired
+	}
+	return nil
+}
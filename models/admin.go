@@ -0,0 +1,211 @@
+package models
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/conf"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+)
+
+// SystemUsage struct represent the storage used by all users
+type SystemUsage struct {
+	Users         int64 `json:"users"`
+	Buckets       int64 `json:"buckets"`
+	Photos        int64 `json:"photos"`
+	Bytes         int64 `json:"bytes"`
+	TrashedPhotos int64 `json:"trashed_photos"`
+	TrashedBytes  int64 `json:"trashed_bytes"`
+	VersionBytes  int64 `json:"version_bytes"`
+}
+
+// BucketDetail struct represent a bucket with its usage and a page of its photos
+type BucketDetail struct {
+	Bucket Bucket  `json:"bucket"`
+	Usage  Usage   `json:"usage"`
+	Photos []Photo `json:"photos"`
+}
+
+// AuthDetail struct represent a user with the storage used and the effective quota
+type AuthDetail struct {
+	Auth
+	Usage Usage `json:"usage"`
+}
+
+// GetAuths func get a page of all users with their usage and quotas
+func GetAuths(ctx context.Context, offset int) ([]AuthDetail, error) {
+	trx := withContext(ctx, db).Begin()
+	defer trx.Commit()
+
+	auths := make([]Auth, 0, constant.PageSize)
+	err := trx.Order("id").
+		Offset(offset).
+		Limit(constant.PageSize).
+		Find(&auths).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	details := make([]AuthDetail, 0, len(auths))
+	for i := range auths {
+		usage, err := authUsage(trx, &auths[i])
+		if err != nil {
+			return nil, err
+		}
+		details = append(details, AuthDetail{Auth: auths[i], Usage: usage})
+	}
+	return details, nil
+}
+
+// GetAuthByID func get the auth by its id
+func GetAuthByID(ctx context.Context, authID uint) (*Auth, error) {
+	auth := Auth{}
+	withContext(ctx, db).Where("id = ?", authID).First(&auth)
+	if auth.ID == 0 {
+		return nil, ErrNoSuchAuth
+	}
+	return &auth, nil
+}
+
+// SetAuthState func enable or disable a user
+func SetAuthState(ctx context.Context, authID uint, state int) (*Auth, error) {
+	return updateAuth(ctx, authID, "state", state)
+}
+
+// SetAuthRole func change the role of a user
+func SetAuthRole(ctx context.Context, authID uint, role string) (*Auth, error) {
+	if role != constant.RoleAdmin && role != constant.RoleUser && role != constant.RoleReadOnly {
+		return nil, ErrInvalidRole
+	}
+	return updateAuth(ctx, authID, "role", role)
+}
+
+// SetAuthQuota func set the quotas of a user, 0 falls back to the configured default
+// and less than 0 means no limit
+func SetAuthQuota(ctx context.Context, authID uint, maxPhotos, maxBytes int64) (*AuthDetail, error) {
+	trx := withContext(ctx, db).Begin()
+	defer trx.Commit()
+
+	auth := Auth{}
+	trx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", authID).First(&auth)
+	if auth.ID == 0 {
+		return nil, ErrNoSuchAuth
+	}
+
+	err := trx.Model(&auth).UpdateColumns(map[string]interface{}{
+		"max_photos": maxPhotos,
+		"max_bytes":  maxBytes,
+	}).Error
+	if err != nil {
+		trx.Rollback()
+		return nil, err
+	}
+
+	usage, err := authUsage(trx, &auth)
+	if err != nil {
+		trx.Rollback()
+		return nil, err
+	}
+	return &AuthDetail{Auth: auth, Usage: usage}, nil
+}
+
+// GetBucketDetail func get any bucket with its usage and a page of its photos
+func GetBucketDetail(ctx context.Context, bucketID uint, offset int) (*BucketDetail, error) {
+	trx := withContext(ctx, db).Begin()
+	defer trx.Commit()
+
+	detail := BucketDetail{Photos: make([]Photo, 0, constant.PageSize)}
+	trx.Where("id = ?", bucketID).First(&detail.Bucket)
+	if detail.Bucket.ID == 0 {
+		return nil, ErrNoSuchBucket
+	}
+
+	var err error
+	if detail.Usage, err = getBucketUsage(trx, &detail.Bucket); err != nil {
+		return nil, err
+	}
+	err = trx.Where("bucket_id = ?", bucketID).
+		Offset(offset).
+		Limit(constant.PageSize).
+		Find(&detail.Photos).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	return &detail, nil
+}
+
+// GetSystemUsage func get the storage used by all users
+func GetSystemUsage(ctx context.Context) (*SystemUsage, error) {
+	trx := withContext(ctx, db).Begin()
+	defer trx.Commit()
+
+	usage := SystemUsage{}
+	if err := trx.Model(&Auth{}).Count(&usage.Users).Error; err != nil {
+		return nil, err
+	}
+	if err := trx.Model(&Bucket{}).Count(&usage.Buckets).Error; err != nil {
+		return nil, err
+	}
+
+	err := trx.Model(&Photo{}).
+		Select("COUNT(*), COALESCE(SUM(size), 0)").
+		Row().
+		Scan(&usage.Photos, &usage.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	err = trx.Unscoped().Model(&Photo{}).
+		Where("deleted_at IS NOT NULL").
+		Select("COUNT(*), COALESCE(SUM(size), 0)").
+		Row().
+		Scan(&usage.TrashedPhotos, &usage.TrashedBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	err = trx.Model(&PhotoVersion{}).
+		Select("COALESCE(SUM(size), 0)").
+		Row().
+		Scan(&usage.VersionBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// updateAuth func update a column of a user, the user has to sign in again to get a new jwt
+func updateAuth(ctx context.Context, authID uint, column string, value interface{}) (*Auth, error) {
+	trx := withContext(ctx, db).Begin()
+	defer trx.Commit()
+
+	auth := Auth{}
+	trx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", authID).First(&auth)
+	if auth.ID == 0 {
+		return nil, ErrNoSuchAuth
+	}
+
+	if err := trx.Model(&auth).UpdateColumn(column, value).Error; err != nil {
+		trx.Rollback()
+		return nil, err
+	}
+	utils.RemoveAuthFromRedis(ctx, auth.UserName)
+	utils.RevokeRefreshTokens(ctx, auth.UserName)
+	return &auth, nil
+}
+
+// promoteAdmin func make the configured user an admin
+func promoteAdmin() {
+	userName := conf.ServerCfg.GetDefault(constant.AdminUserName, "")
+	if userName == "" {
+		return
+	}
+	err := db.Model(&Auth{}).Where("user_name = ?", userName).UpdateColumn("role", constant.RoleAdmin).Error
+	if err != nil {
+		utils.AppLogger.Error(err.Error(), zap.String("service", "promoteAdmin()"))
+	}
+}
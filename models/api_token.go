@@ -0,0 +1,133 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jinzhu/gorm"
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+)
+
+// APIToken struct model represent a personal access token of a user, only its hash is stored
+type APIToken struct {
+	BaseModel
+	AuthID     uint       `json:"auth_id" gorm:"type:int;index"`
+	Name       string     `json:"name" gorm:"type:varchar(64)"`
+	Scope      string     `json:"scope" gorm:"type:varchar(16)"`
+	Prefix     string     `json:"prefix" gorm:"type:varchar(16)"`
+	TokenHash  string     `json:"-" gorm:"type:char(64);unique_index"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+var ErrNoSuchAPIToken = apperr.New(constant.TokenNotExist, "no such api token")
+var ErrInvalidScope = apperr.New(constant.InvalidParams, "invalid api token scope")
+
+// scopeRoles maps the scopes of the api tokens to the roles they act as
+var scopeRoles = map[string]string{
+	constant.TokenScopeRead:   constant.RoleReadOnly,
+	constant.TokenScopeUpload: constant.RoleUploader,
+}
+
+// AddAPIToken func create an api token for the user, the token is only returned here
+func AddAPIToken(ctx context.Context, authID uint, name, scope string) (*APIToken, string, error) {
+	if scope != constant.TokenScopeRead && scope != constant.TokenScopeUpload && scope != constant.TokenScopeFull {
+		return nil, "", ErrInvalidScope
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	token := constant.APITokenPrefix + hex.EncodeToString(secret)
+
+	apiToken := APIToken{
+		AuthID:    authID,
+		Name:      name,
+		Scope:     scope,
+		Prefix:    token[:len(constant.APITokenPrefix)+6],
+		TokenHash: hashAPIToken(token),
+	}
+	if err := withContext(ctx, db).Create(&apiToken).Error; err != nil {
+		utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "AddAPIToken()"))
+		return nil, "", err
+	}
+	return &apiToken, token, nil
+}
+
+// GetAPITokens func get the api tokens of the user
+func GetAPITokens(ctx context.Context, authID uint) ([]APIToken, error) {
+	tokens := make([]APIToken, 0)
+	err := withContext(ctx, db).Where("auth_id = ?", authID).Order("id DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+// RevokeAPIToken func revoke an api token of the user
+func RevokeAPIToken(ctx context.Context, authID, tokenID uint) error {
+	result := withContext(ctx, db).Model(&APIToken{}).
+		Where("id = ? AND auth_id = ? AND revoked_at IS NULL", tokenID, authID).
+		UpdateColumn("revoked_at", time.Now())
+	if err := result.Error; err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return ErrNoSuchAPIToken
+	}
+	return nil
+}
+
+// revokeAPITokens func revoke all api tokens of the user in the transaction
+func revokeAPITokens(trx *gorm.DB, authID uint) error {
+	return trx.Model(&APIToken{}).
+		Where("auth_id = ? AND revoked_at IS NULL", authID).
+		UpdateColumn("revoked_at", time.Now()).
+		Error
+}
+
+// CheckAPIToken func get the user of a valid api token and the role the token acts as,
+// the role of a token never exceeds the role of its user.
+func CheckAPIToken(ctx context.Context, token string) (*Auth, string, error) {
+	if !strings.HasPrefix(token, constant.APITokenPrefix) {
+		return nil, "", ErrNoSuchAPIToken
+	}
+
+	apiToken := APIToken{}
+	withContext(ctx, db).Where("token_hash = ? AND revoked_at IS NULL", hashAPIToken(token)).First(&apiToken)
+	if apiToken.ID == 0 {
+		return nil, "", ErrNoSuchAPIToken
+	}
+
+	auth := Auth{}
+	withContext(ctx, db).Where("id = ?", apiToken.AuthID).First(&auth)
+	if auth.ID == 0 {
+		return nil, "", ErrNoSuchAuth
+	}
+	if auth.State == 0 {
+		return nil, "", ErrAuthDisabled
+	}
+
+	err := withContext(ctx, db).Model(&apiToken).UpdateColumn("last_used_at", time.Now()).Error
+	if err != nil {
+		utils.GetLogger(ctx).Warn(err.Error(), zap.String("service", "CheckAPIToken()"))
+	}
+
+	role := auth.Role
+	if scopeRole, ok := scopeRoles[apiToken.Scope]; ok && role != constant.RoleReadOnly {
+		role = scopeRole
+	}
+	return &auth, role, nil
+}
+
+// hashAPIToken func hash an api token to store and look it up
+func hashAPIToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
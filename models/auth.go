@@ -1,26 +1,45 @@
 package models
 
 import (
+	"context"
 	"crypto/md5"
-	"errors"
 	"fmt"
 	"io"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/conf"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
 )
 
 // Auth struct model represent auth table
 type Auth struct {
 	BaseModel
 	UserName string `json:"user_name" gorm:"type:varchar(16)"`
-	Password string `json:"password" gorm:"type:varchar(255)"`
+	Password string `json:"-" gorm:"type:varchar(255)"`
 	Email    string `json:"email" gorm:"type:varchar(128)"`
+	Role     string `json:"role" gorm:"type:varchar(16);default:'user'"`
+	State    int    `json:"state" gorm:"type:tinyint(1);default:1"` // 0 means the account is disabled
+
+	EmailVerified bool `json:"email_verified" gorm:"type:tinyint(1);default:0"`
+
+	// totp is only required to log in when enabled, the secret is kept while enrolling
+	TOTPSecret  string `json:"-" gorm:"type:varchar(64)"`
+	TOTPEnabled bool   `json:"totp_enabled" gorm:"type:tinyint(1);default:0"`
+
+	// quotas of the user, 0 means the configured default and less than 0 means no limit
+	MaxPhotos int64 `json:"max_photos" gorm:"type:bigint"`
+	MaxBytes  int64 `json:"max_bytes" gorm:"type:bigint"`
 }
 
-var ErrAuthExist = errors.New("auth already exists")
+var ErrAuthExist = apperr.New(constant.UserAlreadyExist, "auth already exists")
+var ErrNoSuchAuth = apperr.New(constant.UserNotExist, "no such auth")
+var ErrAuthDisabled = apperr.New(constant.UserDisabled, "auth is disabled")
+var ErrInvalidRole = apperr.New(constant.InvalidParams, "invalid role")
 
 // AddAuth func to add a new auth
-func AddAuth(username, password, email string) error {
+func AddAuth(ctx context.Context, username, password, email string) error {
 	// transaction
-	trx := db.Begin()
+	trx := withContext(ctx, db).Begin()
 	defer trx.Commit()
 
 	auth := Auth{}
@@ -33,11 +52,11 @@ func AddAuth(username, password, email string) error {
 		return ErrAuthExist
 	}
 
-	hash := md5.New()
-	io.WriteString(hash, password)
 	auth.UserName = username
-	auth.Password = fmt.Sprintf("%x", hash.Sum(nil))
+	auth.Password = hashPassword(password)
 	auth.Email = email
+	auth.Role = constant.RoleUser
+	auth.State = 1
 	err := trx.Create(&auth).Error
 	if err != nil {
 		return err
@@ -45,23 +64,48 @@ func AddAuth(username, password, email string) error {
 	return nil
 }
 
-// CheckAuth func check if the auth is valid
-func CheckAuth(username, password string) bool {
-	trx := db.Begin()
+// CheckAuth func check if the auth is valid, the auth is returned to issue its jwt
+func CheckAuth(ctx context.Context, username, password string) (*Auth, error) {
+	trx := withContext(ctx, db).Begin()
 	defer trx.Commit()
 
 	auth := Auth{}
 
-	hash := md5.New()
-	io.WriteString(hash, password)
-	password = fmt.Sprintf("%x", hash.Sum(nil))
+	password = hashPassword(password)
 
 	trx.Set("gorm:query_option", "FOR UPDATE").
 		Where("user_name = ? AND password = ?", username, password).
 		First(&auth)
 
+	if auth.ID == 0 {
+		return nil, ErrNoSuchAuth
+	}
+	if auth.State == 0 {
+		return nil, ErrAuthDisabled
+	}
+	if !auth.EmailVerified && conf.ServerCfg.GetDefault(constant.RequireEmailVerification, "false") == "true" {
+		return nil, ErrAuthUnverified
+	}
+	return &auth, nil
+}
+
+// GetAuthByUserName func get the auth by its user name
+func GetAuthByUserName(ctx context.Context, username string) (*Auth, error) {
+	trx := withContext(ctx, db).Begin()
+	defer trx.Commit()
+
+	auth := Auth{}
+	trx.Where("user_name = ?", username).First(&auth)
+
 	if auth.ID > 0 {
-		return true
+		return &auth, nil
 	}
-	return false
+	return &auth, ErrNoSuchAuth
+}
+
+// hashPassword func hash a password to store and compare it
+func hashPassword(password string) string {
+	hash := md5.New()
+	io.WriteString(hash, password)
+	return fmt.Sprintf("%x", hash.Sum(nil))
 }
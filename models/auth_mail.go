@@ -0,0 +1,147 @@
+package models
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/jinzhu/gorm"
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/conf"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+)
+
+var ErrInvalidMailToken = apperr.New(constant.MailTokenInvalid, "mail token is invalid or expired")
+var ErrAuthVerified = apperr.New(constant.UserVerified, "auth email is already verified")
+var ErrAuthUnverified = apperr.New(constant.UserUnverified, "auth email is not verified")
+
+// SendVerificationMail func send a mail with the token to verify the email of the user
+func SendVerificationMail(ctx context.Context, username string) error {
+	auth, err := GetAuthByUserName(ctx, username)
+	if err != nil {
+		return err
+	}
+	if auth.EmailVerified {
+		return ErrAuthVerified
+	}
+
+	token, err := utils.AddUserToken(ctx, constant.VerifyTokenFormat, auth.UserName, constant.VerifyTokenMaxAge)
+	if err != nil {
+		return err
+	}
+	body := fmt.Sprintf("Hi %s,\n\nPlease verify your email with the token below, it expires in 24 hours.\n\n%s",
+		auth.UserName, mailLink(constant.MailVerifyURL, token))
+	return utils.AppMailer.Send(auth.Email, "Verify your email", body)
+}
+
+// VerifyEmail func mark the email of the user of a verification token as verified,
+// an email verified by another user is refused so a verified email always has one user.
+func VerifyEmail(ctx context.Context, token string) (*Auth, error) {
+	username, err := utils.PopUserToken(ctx, constant.VerifyTokenFormat, token)
+	if err != nil {
+		return nil, err
+	}
+	if username == "" {
+		return nil, ErrInvalidMailToken
+	}
+
+	auth, err := GetAuthByUserName(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	other := Auth{}
+	withContext(ctx, db).Where("email = ? AND email_verified = ? AND id <> ?", auth.Email, true, auth.ID).First(&other)
+	if other.ID > 0 {
+		return nil, ErrEmailExist
+	}
+	if err := withContext(ctx, db).Model(auth).UpdateColumn("email_verified", true).Error; err != nil {
+		return nil, err
+	}
+	return auth, nil
+}
+
+// RequestPasswordReset func send a mail with the token to reset the password of the user of the email,
+// an unknown or ambiguous email is not an error so the registered emails cannot be probed.
+func RequestPasswordReset(ctx context.Context, email string) error {
+	auth, err := getAuthByEmail(withContext(ctx, db), email)
+	if err != nil {
+		utils.GetLogger(ctx).Debug("password reset of an unknown email.", zap.String("service", "RequestPasswordReset()"),
+			zap.Error(err))
+		return nil
+	}
+
+	token, err := utils.AddUserToken(ctx, constant.ResetTokenFormat, auth.UserName, constant.ResetTokenMaxAge)
+	if err != nil {
+		return err
+	}
+	body := fmt.Sprintf("Hi %s,\n\nUse the token below to reset your password, it expires in 1 hour.\n"+
+		"If you did not ask for it, you can ignore this mail.\n\n%s",
+		auth.UserName, mailLink(constant.MailResetURL, token))
+	return utils.AppMailer.Send(auth.Email, "Reset your password", body)
+}
+
+// ResetPassword func set the password of the user of a reset token, all logins and api tokens of the user are ended
+func ResetPassword(ctx context.Context, token, password string) (*Auth, error) {
+	username, err := utils.PopUserToken(ctx, constant.ResetTokenFormat, token)
+	if err != nil {
+		return nil, err
+	}
+	if username == "" {
+		return nil, ErrInvalidMailToken
+	}
+
+	auth, err := GetAuthByUserName(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	// the mail proves the user owns the email as well
+	trx := withContext(ctx, db).Begin()
+	err = trx.Model(auth).UpdateColumns(map[string]interface{}{
+		"password":       hashPassword(password),
+		"email_verified": true,
+	}).Error
+	if err == nil {
+		// the password may be reset because the account is compromised, so are its tokens
+		err = revokeAPITokens(trx, auth.ID)
+	}
+	if err != nil {
+		trx.Rollback()
+		return nil, err
+	}
+	if err := trx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	utils.RemoveAuthFromRedis(ctx, auth.UserName)
+	utils.RevokeRefreshTokens(ctx, auth.UserName)
+	return auth, nil
+}
+
+// getAuthByEmail func get the only user of an email, the user who verified it or else
+// the one user using it, an email used by more users without verification is ambiguous.
+func getAuthByEmail(trx *gorm.DB, email string) (*Auth, error) {
+	auths := make([]Auth, 0)
+	if err := trx.Where("email = ?", email).Order("email_verified DESC, id").Find(&auths).Error; err != nil {
+		return nil, err
+	}
+	if len(auths) == 0 {
+		return nil, ErrNoSuchAuth
+	}
+	if !auths[0].EmailVerified && len(auths) > 1 {
+		return nil, ErrNoSuchAuth
+	}
+	return &auths[0], nil
+}
+
+// mailLink func get the link of a mail token by the configured url format, or the token itself
+func mailLink(urlKey, token string) string {
+	urlFormat := conf.ServerCfg.GetDefault(urlKey, "")
+	if urlFormat == "" {
+		return token
+	}
+	return fmt.Sprintf(urlFormat, token)
+}
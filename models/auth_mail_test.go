@@ -0,0 +1,57 @@
+//go:build integration
+
+// The models connect to the configured mysql and redis when they are loaded,
+// run these tests with: go test -tags integration ./models/
+
+package models
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestGetAuthByEmail(t *testing.T) {
+	type user struct {
+		name     string
+		verified bool
+	}
+	tests := []struct {
+		name    string
+		users   []user
+		want    string // the user name found, empty for none
+		wantErr error
+	}{
+		{name: "no user", users: nil, wantErr: ErrNoSuchAuth},
+		{name: "one unverified user", users: []user{{"t_alice", false}}, want: "t_alice"},
+		{name: "one verified user", users: []user{{"t_alice", true}}, want: "t_alice"},
+		{name: "the verified user wins", users: []user{{"t_alice", false}, {"t_bob", true}}, want: "t_bob"},
+		{name: "the verified user wins over more", users: []user{{"t_alice", false}, {"t_bob", false}, {"t_carol", true}}, want: "t_carol"},
+		{name: "unverified users are ambiguous", users: []user{{"t_alice", false}, {"t_bob", false}}, wantErr: ErrNoSuchAuth},
+		{name: "the first verified user wins", users: []user{{"t_alice", true}, {"t_bob", true}}, want: "t_alice"},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// the users only live in the transaction of the test
+			trx := withContext(context.Background(), db).Begin()
+			defer trx.Rollback()
+
+			email := fmt.Sprintf("get-auth-by-email-%d@test.invalid", i)
+			for _, u := range test.users {
+				auth := Auth{UserName: u.name, Email: email, EmailVerified: u.verified, State: 1}
+				if err := trx.Create(&auth).Error; err != nil {
+					t.Fatalf("create auth: %v", err)
+				}
+			}
+
+			auth, err := getAuthByEmail(trx, email)
+			if err != test.wantErr {
+				t.Fatalf("getAuthByEmail() error = %v, want %v", err, test.wantErr)
+			}
+			if auth != nil && auth.UserName != test.want {
+				t.Errorf("getAuthByEmail() = %q, want %q", auth.UserName, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/jinzhu/gorm"
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/conf"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+)
+
+// AuthIdentity struct model represent an account of an oidc provider linked to a user
+type AuthIdentity struct {
+	BaseModel
+	AuthID   uint   `json:"auth_id" gorm:"type:int;index"`
+	Provider string `json:"provider" gorm:"type:varchar(32);unique_index:idx_provider_subject"`
+	Subject  string `json:"subject" gorm:"type:varchar(255);unique_index:idx_provider_subject"`
+	Email    string `json:"email" gorm:"type:varchar(128)"`
+}
+
+var ErrIdentityNotLinked = apperr.New(constant.OIDCNotLinked, "oidc identity is not linked to any auth")
+
+// invalidUserNameChars matches the chars which cannot be in a provisioned user name
+var invalidUserNameChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// LoginOIDC func get the user of an oidc identity, an identity seen for the first time is linked
+// to the user of the same email when both sides verified it, or provisioned as a new user when it is enabled.
+func LoginOIDC(ctx context.Context, provider string, claims *utils.OIDCClaims) (*Auth, error) {
+	trx := withContext(ctx, db).Begin()
+	defer trx.Commit()
+
+	identity := AuthIdentity{}
+	trx.Where("provider = ? AND subject = ?", provider, claims.Subject).First(&identity)
+
+	auth := Auth{}
+	if identity.ID > 0 {
+		trx.Where("id = ?", identity.AuthID).First(&auth)
+	} else {
+		// only a verified email proves the identity belongs to the user of the email
+		if claims.Email == "" || !claims.EmailVerified {
+			return nil, ErrIdentityNotLinked
+		}
+		// an unverified local email may have been set by anyone, it never links an identity
+		trx.Where("email = ? AND email_verified = ?", claims.Email, true).First(&auth)
+		if auth.ID == 0 {
+			if conf.ServerCfg.GetDefault(constant.OIDCAutoProvision, "false") != "true" {
+				return nil, ErrIdentityNotLinked
+			}
+			if err := provisionAuth(trx, &auth, claims); err != nil {
+				trx.Rollback()
+				return nil, err
+			}
+		}
+
+		identity = AuthIdentity{
+			AuthID:   auth.ID,
+			Provider: provider,
+			Subject:  claims.Subject,
+			Email:    claims.Email,
+		}
+		if err := trx.Create(&identity).Error; err != nil {
+			trx.Rollback()
+			return nil, err
+		}
+		utils.GetLogger(ctx).Info("oidc identity linked.", zap.String("service", "LoginOIDC()"),
+			zap.String("provider", provider), zap.String("user_name", auth.UserName))
+	}
+
+	if auth.ID == 0 {
+		return nil, ErrNoSuchAuth
+	}
+	if auth.State == 0 {
+		return nil, ErrAuthDisabled
+	}
+	return &auth, nil
+}
+
+// provisionAuth func create a user of an oidc identity, it has no password so it can only log in by the provider
+func provisionAuth(trx *gorm.DB, auth *Auth, claims *utils.OIDCClaims) error {
+	base := claims.PreferredUsername
+	if base == "" {
+		base = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	base = invalidUserNameChars.ReplaceAllString(base, "")
+	if len(base) > 10 {
+		base = base[:10]
+	}
+
+	// the user name must be unique and 6 to 16 chars
+	userName := base
+	for i := 0; len(userName) < 6 || trx.Where("user_name = ?", userName).First(&Auth{}).RowsAffected > 0; i++ {
+		if i == constant.OIDCUserNameAttempts {
+			return ErrAuthExist
+		}
+		suffix, err := newRandomID()
+		if err != nil {
+			return err
+		}
+		userName = base + "_" + suffix[:5]
+	}
+
+	auth.UserName = userName
+	auth.Email = claims.Email
+	auth.EmailVerified = true
+	auth.Role = constant.RoleUser
+	auth.State = 1
+	return trx.Create(auth).Error
+}
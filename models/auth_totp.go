@@ -0,0 +1,163 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/conf"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+)
+
+// RecoveryCode struct model represent a single-use code to log in without the totp device
+type RecoveryCode struct {
+	BaseModel
+	AuthID   uint       `json:"auth_id" gorm:"type:int;index"`
+	CodeHash string     `json:"-" gorm:"type:char(64)"`
+	UsedAt   *time.Time `json:"used_at"`
+}
+
+var ErrTOTPEnabled = apperr.New(constant.MFAEnabled, "totp is already enabled")
+var ErrTOTPNotEnabled = apperr.New(constant.MFANotEnabled, "totp is not enabled")
+var ErrInvalidTOTPCode = apperr.New(constant.MFACodeInvalid, "invalid totp or recovery code")
+
+// EnrollTOTP func generate a new totp secret of the user, it is not required to log in until confirmed
+func EnrollTOTP(ctx context.Context, authID uint) (string, string, error) {
+	auth, err := GetAuthByID(ctx, authID)
+	if err != nil {
+		return "", "", err
+	}
+	if auth.TOTPEnabled {
+		return "", "", ErrTOTPEnabled
+	}
+
+	secret, err := utils.NewTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	if err := withContext(ctx, db).Model(auth).UpdateColumn("totp_secret", secret).Error; err != nil {
+		return "", "", err
+	}
+	issuer := conf.ServerCfg.GetDefault(constant.TOTPIssuer, constant.DefaultTOTPIssuer)
+	return secret, utils.TOTPURI(issuer, auth.UserName, secret), nil
+}
+
+// ConfirmTOTP func enable totp of the user by a code of the enrolled secret,
+// the recovery codes are generated and only returned here.
+func ConfirmTOTP(ctx context.Context, authID uint, code string) ([]string, error) {
+	auth, err := GetAuthByID(ctx, authID)
+	if err != nil {
+		return nil, err
+	}
+	if auth.TOTPEnabled {
+		return nil, ErrTOTPEnabled
+	}
+	if auth.TOTPSecret == "" {
+		return nil, ErrTOTPNotEnabled
+	}
+	if !checkTOTPCode(ctx, auth, code) {
+		return nil, ErrInvalidTOTPCode
+	}
+
+	trx := withContext(ctx, db).Begin()
+	if err := trx.Model(auth).UpdateColumn("totp_enabled", true).Error; err != nil {
+		trx.Rollback()
+		return nil, err
+	}
+	codes, err := addRecoveryCodes(trx, auth.ID)
+	if err != nil {
+		trx.Rollback()
+		return nil, err
+	}
+	if err := trx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP func disable totp of the user, a totp or recovery code is required
+func DisableTOTP(ctx context.Context, authID uint, code string) error {
+	auth, err := GetAuthByID(ctx, authID)
+	if err != nil {
+		return err
+	}
+	if err := CheckSecondFactor(ctx, auth, code); err != nil {
+		return err
+	}
+
+	trx := withContext(ctx, db).Begin()
+	err = trx.Model(auth).UpdateColumns(map[string]interface{}{
+		"totp_secret":  "",
+		"totp_enabled": false,
+	}).Error
+	if err == nil {
+		err = trx.Where("auth_id = ?", auth.ID).Delete(RecoveryCode{}).Error
+	}
+	if err != nil {
+		trx.Rollback()
+		return err
+	}
+	return trx.Commit().Error
+}
+
+// CheckSecondFactor func check a totp code or an unused recovery code of the user,
+// a totp code is accepted once and a recovery code is used up.
+func CheckSecondFactor(ctx context.Context, auth *Auth, code string) error {
+	if !auth.TOTPEnabled {
+		return ErrTOTPNotEnabled
+	}
+	code = strings.TrimSpace(code)
+	if checkTOTPCode(ctx, auth, code) {
+		return nil
+	}
+
+	result := withContext(ctx, db).Model(&RecoveryCode{}).
+		Where("auth_id = ? AND code_hash = ? AND used_at IS NULL", auth.ID, hashRecoveryCode(code)).
+		UpdateColumn("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidTOTPCode
+	}
+	return nil
+}
+
+// checkTOTPCode func check a totp code of the secret of the user which has not been used yet
+func checkTOTPCode(ctx context.Context, auth *Auth, code string) bool {
+	step, ok := utils.CheckTOTP(auth.TOTPSecret, code, time.Now())
+	return ok && utils.UseTOTPStep(ctx, auth.UserName, step)
+}
+
+// addRecoveryCodes func replace the recovery codes of the user with new ones
+func addRecoveryCodes(trx *gorm.DB, authID uint) ([]string, error) {
+	if err := trx.Where("auth_id = ?", authID).Delete(RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, constant.RecoveryCodeCount)
+	for i := 0; i < constant.RecoveryCodeCount; i++ {
+		id, err := newRandomID()
+		if err != nil {
+			return nil, err
+		}
+		code := id[:5] + "-" + id[5:10]
+		recoveryCode := RecoveryCode{AuthID: authID, CodeHash: hashRecoveryCode(code)}
+		if err := trx.Create(&recoveryCode).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// hashRecoveryCode func hash a recovery code to store and look it up
+func hashRecoveryCode(code string) string {
+	hash := sha256.Sum256([]byte(strings.ToLower(code)))
+	return hex.EncodeToString(hash[:])
+}
@@ -1,8 +1,10 @@
 package models
 
 import (
-	"errors"
+	"context"
+	"time"
 
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
 	"github.com/walk1ng/gin-photo-gallery-storage/constant"
 	"github.com/walk1ng/gin-photo-gallery-storage/utils"
 	"go.uber.org/zap"
@@ -11,28 +13,36 @@ import (
 // Bucket struct model represent bucket table
 type Bucket struct {
 	BaseModel
-	AuthID      uint   `json:"auth_id" gorm:"type:int" form:"auth_id"`
-	Name        string `json:"name" gorm:"type:varchar(64)" form:"bucket_name"`
-	State       int    `json:"state" gorm:"type:tinyint(1)" form:"state"`
-	Size        int    `json:"size" gorm:"type:int" form:"bucket_size"`
-	Description string `json:"description" gorm:"type:text" form:"description"`
+	AuthID      uint       `json:"auth_id" gorm:"type:int" form:"-"` // always the current user, never bound
+	Name        string     `json:"name" gorm:"type:varchar(64)" form:"bucket_name"`
+	State       int        `json:"state" gorm:"type:tinyint(1)" form:"-"`
+	Size        int        `json:"size" gorm:"type:int" form:"-"`
+	Description string     `json:"description" gorm:"type:text" form:"description"`
+	MaxPhotos   int64      `json:"max_photos" gorm:"type:bigint" form:"max_photos"`  // 0 or less means no limit
+	MaxBytes    int64      `json:"max_bytes" gorm:"type:bigint" form:"max_bytes"`    // 0 or less means no limit
+	MaxVersions int64      `json:"max_versions" gorm:"type:int" form:"max_versions"` // 0 means the default, less than 0 means no limit
+	DeletedAt   *time.Time `json:"deleted_at,omitempty" gorm:"index" form:"-"`
 }
 
-var ErrBucketExists = errors.New("bucket already exists")
-var ErrNoSuchBucket = errors.New("no such bucket")
+var ErrBucketExists = apperr.New(constant.BucketAlreadyExist, "bucket already exists")
+var ErrNoSuchBucket = apperr.New(constant.BucketNotExist, "no such bucket")
+var ErrBucketInTrash = apperr.New(constant.BucketInTrash, "bucket with the same name is in trash")
 
 // AddBucket func add a new bucket
-func AddBucket(bucketToAdd *Bucket) error {
-	trx := db.Begin()
+func AddBucket(ctx context.Context, bucketToAdd *Bucket) error {
+	trx := withContext(ctx, db).Begin()
 	defer trx.Commit()
 
-	// check if the bucket exists
+	// check if the bucket exists, trashed buckets still hold their names
 	bucket := Bucket{}
-	trx.Set("gorm:query_option", "FOR UPDAE").
+	trx.Unscoped().Set("gorm:query_option", "FOR UPDAE").
 		Where("auth_id = ? AND name = ? AND state = ?", bucketToAdd.AuthID, bucketToAdd.Name, 1).
 		First(&bucket)
 
 	if bucket.ID > 0 {
+		if bucket.DeletedAt != nil {
+			return ErrBucketInTrash
+		}
 		return ErrBucketExists
 	}
 
@@ -42,21 +52,28 @@ func AddBucket(bucketToAdd *Bucket) error {
 	bucket.State = 1
 	bucket.Size = 0
 	bucket.Description = bucketToAdd.Description
+	bucket.MaxPhotos = bucketToAdd.MaxPhotos
+	bucket.MaxBytes = bucketToAdd.MaxBytes
+	bucket.MaxVersions = bucketToAdd.MaxVersions
 
 	if err := trx.Create(&bucket).Error; err != nil {
-		utils.AppLogger.Info(err.Error(), zap.String("service", "AddBucket()"))
+		utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "AddBucket()"))
 		return err
 	}
 
 	return nil
 }
 
-// DeleteBucket func delete an existed bucket
-func DeleteBucket(bucketID uint) error {
-	trx := db.Begin()
+// DeleteBucket func move an existed bucket and its photos to the trash
+func DeleteBucket(ctx context.Context, bucketID uint) error {
+	trx := withContext(ctx, db).Begin()
 	defer trx.Commit()
 
-	result := trx.Where("id = ? AND state = ?", bucketID, 1).Delete(Bucket{})
+	// the bucket and its photos share the deletion time to be restored together
+	now := time.Now()
+	result := trx.Model(&Bucket{}).
+		Where("id = ? AND state = ?", bucketID, 1).
+		UpdateColumn("deleted_at", now)
 	if err := result.Error; err != nil {
 		return err
 	}
@@ -65,12 +82,21 @@ func DeleteBucket(bucketID uint) error {
 		return ErrNoSuchBucket
 	}
 
+	err := trx.Model(&Photo{}).
+		Where("bucket_id = ?", bucketID).
+		UpdateColumn("deleted_at", now).
+		Error
+	if err != nil {
+		trx.Rollback()
+		return err
+	}
+
 	return nil
 }
 
 // UpdateBucket func update an existed bucket
-func UpdateBucket(bucketToUpdate *Bucket) error {
-	trx := db.Begin()
+func UpdateBucket(ctx context.Context, bucketToUpdate *Bucket) error {
+	trx := withContext(ctx, db).Begin()
 	defer trx.Commit()
 
 	bucket := Bucket{}
@@ -88,8 +114,8 @@ func UpdateBucket(bucketToUpdate *Bucket) error {
 }
 
 // GetBucketByID func get bucket by bucket id
-func GetBucketByID(bucketID uint) (Bucket, error) {
-	trx := db.Begin()
+func GetBucketByID(ctx context.Context, bucketID uint) (Bucket, error) {
+	trx := withContext(ctx, db).Begin()
 	defer trx.Commit()
 
 	bucket := Bucket{}
@@ -103,13 +129,19 @@ func GetBucketByID(bucketID uint) (Bucket, error) {
 
 }
 
-// GetBucketByAuthID func get all buckets by the given user
-func GetBucketByAuthID(authID uint, offset int) ([]Bucket, error) {
-	trx := db.Begin()
+// GetBucketByAuthID func get the buckets of the given user which the viewer can see,
+// other users only see the buckets shared with them
+func GetBucketByAuthID(ctx context.Context, authID, viewerID uint, offset int) ([]Bucket, error) {
+	trx := withContext(ctx, db).Begin()
 	defer trx.Commit()
 
+	query := trx.Where("auth_id = ?", authID)
+	if viewerID != authID {
+		query = query.Where("id IN (?)", trx.Table("collaborator").Select("bucket_id").Where("auth_id = ?", viewerID).SubQuery())
+	}
+
 	buckets := make([]Bucket, 0, constant.PageSize)
-	err := trx.Where("auth_id = ?", authID).
+	err := query.
 		Offset(offset).
 		Limit(constant.PageSize).
 		Find(&buckets).
@@ -0,0 +1,293 @@
+package models
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+)
+
+// ExportManifestItem struct describe an exported photo in the manifest
+type ExportManifestItem struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	File        string    `json:"file"`
+	Tags        []string  `json:"tags"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ExportManifest struct is the manifest.json written at the end of a bucket export
+type ExportManifest struct {
+	Bucket     Bucket               `json:"bucket"`
+	Tag        string               `json:"tag,omitempty"`
+	ExportedAt time.Time            `json:"exported_at"`
+	Photos     []ExportManifestItem `json:"photos"`
+	Failed     []uint               `json:"failed"`
+}
+
+// BucketExport struct represent a background bucket export
+type BucketExport struct {
+	ExportID string `json:"export_id"`
+	BucketID uint   `json:"bucket_id"`
+	BlobName string `json:"-"`
+	Code     int    `json:"code"`
+}
+
+var ErrNoSuchExport = apperr.New(constant.BucketExportNotExist, "no such export")
+
+// WriteBucketArchive func stream the photos of a bucket and a manifest as a zip archive,
+// photos are copied from the storage one by one so the archive is never buffered.
+func WriteBucketArchive(ctx context.Context, w io.Writer, bucket *Bucket, tag string) error {
+	photos, err := getExportPhotos(ctx, bucket.ID, tag)
+	if err != nil {
+		return err
+	}
+
+	zipWriter := zip.NewWriter(w)
+	manifest := ExportManifest{
+		Bucket:     *bucket,
+		Tag:        tag,
+		ExportedAt: time.Now(),
+	}
+	manifest.Photos, manifest.Failed, err = writeArchivePhotos(ctx, zipWriter, photos, constant.ExportPhotoDirectory)
+	if err != nil {
+		return err
+	}
+
+	if err := writeArchiveJSON(zipWriter, constant.ExportManifestName, manifest); err != nil {
+		return err
+	}
+	return zipWriter.Close()
+}
+
+// writeArchivePhotos func copy the photos from the storage into the directory of a zip archive,
+// the photos which cannot be downloaded are skipped and returned as failed.
+func writeArchivePhotos(ctx context.Context, zipWriter *zip.Writer, photos []Photo, directory string) ([]ExportManifestItem, []uint, error) {
+	items := make([]ExportManifestItem, 0, len(photos))
+	failed := make([]uint, 0)
+	for i := range photos {
+		photo := &photos[i]
+		blobName, _ := GetPhotoBlobName(photo, constant.PhotoRenditionOriginal)
+		reader, err := utils.PhotoStorage.Download(ctx, blobName, 0, 0)
+		if err != nil {
+			utils.AppLogger.Warn(err.Error(), zap.String("service", "writeArchivePhotos()"))
+			failed = append(failed, photo.ID)
+			continue
+		}
+
+		// photos are compressed already, store them as they are
+		item := ExportManifestItem{
+			ID:          photo.ID,
+			Name:        photo.Name,
+			File:        directory + strings.TrimLeft(path.Clean("/"+photo.Name), "/"),
+			Tags:        splitTags(photo.Tag),
+			Description: photo.Description,
+			CreatedAt:   photo.CreatedAt,
+			UpdatedAt:   photo.UpdatedAt,
+		}
+		entry, err := zipWriter.CreateHeader(&zip.FileHeader{
+			Name:     item.File,
+			Method:   zip.Store,
+			Modified: photo.UpdatedAt,
+		})
+		if err == nil {
+			_, err = io.Copy(entry, reader)
+		}
+		reader.Close()
+		if err != nil {
+			utils.AppLogger.Warn(err.Error(), zap.String("service", "writeArchivePhotos()"))
+			return nil, nil, err
+		}
+		items = append(items, item)
+	}
+	return items, failed, nil
+}
+
+// writeArchiveJSON func write a value as an indented json file of a zip archive
+func writeArchiveJSON(zipWriter *zip.Writer, name string, value interface{}) error {
+	entry, err := zipWriter.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// StartBucketExport func export a bucket to a zip blob in the background
+func StartBucketExport(ctx context.Context, userName string, bucket *Bucket, tag string) (string, error) {
+	return startExport(ctx, userName, bucket.ID, func(ctx context.Context, w io.Writer) error {
+		return WriteBucketArchive(ctx, w, bucket, tag)
+	})
+}
+
+// startExport func write an archive to a zip blob in the background, bucket id 0 means the whole account
+func startExport(ctx context.Context, userName string, bucketID uint, write func(context.Context, io.Writer) error) (string, error) {
+	exportID, err := newRandomID()
+	if err != nil {
+		return "", err
+	}
+
+	blobName := fmt.Sprintf(constant.ExportBlobFormat, exportID)
+	if !utils.SetExportStatus(ctx, exportID, map[string]interface{}{
+		"user_name": userName,
+		"bucket_id": bucketID,
+		"blob_name": blobName,
+		"status":    1,
+	}) {
+		return "", ErrNoSuchExport
+	}
+
+	go runExport(utils.DetachContext(ctx), exportID, blobName, write)
+	return exportID, nil
+}
+
+// GetBucketExport func get a background bucket export of the user
+func GetBucketExport(ctx context.Context, exportID, userName string) (*BucketExport, error) {
+	fields, err := utils.GetExportStatus(ctx, exportID)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 || fields["user_name"] != userName {
+		return nil, ErrNoSuchExport
+	}
+
+	bucketID, _ := strconv.ParseUint(fields["bucket_id"], 10, 64)
+	export := BucketExport{
+		ExportID: exportID,
+		BucketID: uint(bucketID),
+		BlobName: fields["blob_name"],
+	}
+	switch fields["status"] {
+	case "0":
+		export.Code = constant.BucketExportSuccess
+	case "1":
+		export.Code = constant.BucketExportInProcess
+	default:
+		export.Code = constant.BucketExportError
+	}
+	return &export, nil
+}
+
+// runExport func write an archive to a temp file and upload it
+func runExport(ctx context.Context, exportID, blobName string, write func(context.Context, io.Writer) error) {
+	status := -1
+	defer func() {
+		utils.SetExportStatus(ctx, exportID, map[string]interface{}{"status": status})
+	}()
+
+	tmp, err := ioutil.TempFile("", "export-")
+	if err != nil {
+		utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "runExport()"))
+		return
+	}
+	os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := write(ctx, tmp); err != nil {
+		utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "runExport()"))
+		return
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "runExport()"))
+		return
+	}
+	if err := utils.PhotoStorage.Upload(ctx, blobName, "application/zip", tmp); err != nil {
+		utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "runExport()"))
+		return
+	}
+	status = 0
+}
+
+// deleteExport func delete the blob of an export and forget the export
+func deleteExport(ctx context.Context, exportID string) {
+	blobName := fmt.Sprintf(constant.ExportBlobFormat, exportID)
+	if err := utils.PhotoStorage.Delete(ctx, blobName); err != nil {
+		// a failed export has no blob
+		utils.GetLogger(ctx).Debug(err.Error(), zap.String("service", "deleteExport()"), zap.String("export_id", exportID))
+	}
+	utils.RemoveExport(ctx, exportID)
+}
+
+// deleteUserExports func delete the exports of the user, an export still in process
+// is left to be deleted when it expires because its blob is not written yet.
+func deleteUserExports(ctx context.Context, userName string) {
+	exportIDs, err := utils.GetExportIDs(ctx, time.Now().Add(constant.ExportMaxAge*time.Second))
+	if err != nil {
+		return
+	}
+	for _, exportID := range exportIDs {
+		fields, err := utils.GetExportStatus(ctx, exportID)
+		if err != nil || fields["user_name"] != userName || fields["status"] == "1" {
+			continue
+		}
+		deleteExport(ctx, exportID)
+	}
+}
+
+// purgeExpiredExportsPeriodically func delete the blobs of the exports whose state expired
+func purgeExpiredExportsPeriodically() {
+	ctx := context.Background()
+	ticker := time.NewTicker(constant.ExportCleanInterval * time.Second)
+	for range ticker.C {
+		exportIDs, err := utils.GetExportIDs(ctx, time.Now())
+		if err != nil {
+			continue
+		}
+		for _, exportID := range exportIDs {
+			deleteExport(ctx, exportID)
+		}
+	}
+}
+
+// getExportPhotos func get the uploaded photos of a bucket, optionally only those with the tag
+func getExportPhotos(ctx context.Context, bucketID uint, tag string) ([]Photo, error) {
+	trx := withContext(ctx, db).Begin()
+	defer trx.Commit()
+
+	photos := make([]Photo, 0)
+	err := trx.Where("bucket_id = ? AND url <> ?", bucketID, "").
+		Order("id").
+		Find(&photos).
+		Error
+	if err != nil || tag == "" {
+		return photos, err
+	}
+
+	tagged := make([]Photo, 0, len(photos))
+	for _, photo := range photos {
+		for _, photoTag := range splitTags(photo.Tag) {
+			if photoTag == tag {
+				tagged = append(tagged, photo)
+				break
+			}
+		}
+	}
+	return tagged, nil
+}
+
+// splitTags func split the stored tag string into tags
+func splitTags(tag string) []string {
+	tags := make([]string, 0)
+	for _, t := range strings.Split(tag, ";") {
+		if t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
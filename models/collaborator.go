@@ -0,0 +1,201 @@
+package models
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/jinzhu/gorm"
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+)
+
+// Collaborator struct model represent a user the owner shares a bucket with
+type Collaborator struct {
+	BaseModel
+	BucketID   uint   `json:"bucket_id" gorm:"type:int"`
+	AuthID     uint   `json:"auth_id" gorm:"type:int"`
+	Permission string `json:"permission" gorm:"type:varchar(16)"`
+}
+
+// CollaboratorInfo struct represent a collaborator with the name of the user
+type CollaboratorInfo struct {
+	Collaborator
+	UserName string `json:"user_name"`
+}
+
+var ErrCollaboratorExists = apperr.New(constant.CollaboratorExist, "collaborator already exists")
+var ErrNoSuchCollaborator = apperr.New(constant.CollaboratorNotExist, "no such collaborator")
+var ErrInvalidPermission = apperr.New(constant.InvalidParams, "invalid permission")
+var ErrPermissionDenied = apperr.New(constant.UserDenied, "permission denied")
+
+// permissionLevels orders the permissions, every permission includes the lower ones
+var permissionLevels = map[string]int{
+	constant.PermissionViewer:      1,
+	constant.PermissionContributor: 2,
+	constant.PermissionEditor:      3,
+	constant.PermissionOwner:       4,
+}
+
+// GetBucketAccess func get a bucket on which the user has at least the permission,
+// buckets the user cannot see at all are reported as not existing.
+func GetBucketAccess(ctx context.Context, bucketID, authID uint, permission string) (*Bucket, error) {
+	bucket := Bucket{}
+	withContext(ctx, db).Where("id = ?", bucketID).First(&bucket)
+	if bucket.ID == 0 {
+		return nil, ErrNoSuchBucket
+	}
+	if bucket.AuthID == authID {
+		return &bucket, nil
+	}
+
+	collaborator := Collaborator{}
+	withContext(ctx, db).Where("bucket_id = ? AND auth_id = ?", bucketID, authID).First(&collaborator)
+	if collaborator.ID == 0 {
+		return nil, ErrNoSuchBucket
+	}
+	if permissionLevels[collaborator.Permission] < permissionLevels[permission] {
+		return nil, ErrPermissionDenied
+	}
+	return &bucket, nil
+}
+
+// GetPhotoAccess func get a photo on whose bucket the user has at least the permission
+func GetPhotoAccess(ctx context.Context, photoID, authID uint, permission string) (*Photo, error) {
+	photo, err := GetPhotoByID(ctx, photoID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := GetBucketAccess(ctx, photo.BucketID, authID, permission); err != nil {
+		if err == ErrNoSuchBucket {
+			return nil, ErrNoSuchPhoto
+		}
+		return nil, err
+	}
+	return photo, nil
+}
+
+// AddCollaborator func share a bucket with a user by the user's name
+func AddCollaborator(ctx context.Context, bucketID uint, userName, permission string) (*Collaborator, error) {
+	if !isCollaboratorPermission(permission) {
+		return nil, ErrInvalidPermission
+	}
+
+	trx := withContext(ctx, db).Begin()
+	defer trx.Commit()
+
+	auth := Auth{}
+	trx.Where("user_name = ?", userName).First(&auth)
+	if auth.ID == 0 {
+		return nil, ErrNoSuchAuth
+	}
+
+	bucket := Bucket{}
+	trx.Where("id = ?", bucketID).First(&bucket)
+	if bucket.ID == 0 {
+		return nil, ErrNoSuchBucket
+	}
+
+	collaborator := Collaborator{}
+	trx.Set("gorm:query_option", "FOR UPDATE").
+		Where("bucket_id = ? AND auth_id = ?", bucketID, auth.ID).
+		First(&collaborator)
+	if collaborator.ID > 0 || bucket.AuthID == auth.ID {
+		return nil, ErrCollaboratorExists
+	}
+
+	collaborator.BucketID = bucketID
+	collaborator.AuthID = auth.ID
+	collaborator.Permission = permission
+	if err := trx.Create(&collaborator).Error; err != nil {
+		utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "AddCollaborator()"))
+		return nil, err
+	}
+	return &collaborator, nil
+}
+
+// UpdateCollaborator func change the permission of a collaborator
+func UpdateCollaborator(ctx context.Context, bucketID, authID uint, permission string) (*Collaborator, error) {
+	if !isCollaboratorPermission(permission) {
+		return nil, ErrInvalidPermission
+	}
+
+	trx := withContext(ctx, db).Begin()
+	defer trx.Commit()
+
+	collaborator := Collaborator{}
+	trx.Set("gorm:query_option", "FOR UPDATE").
+		Where("bucket_id = ? AND auth_id = ?", bucketID, authID).
+		First(&collaborator)
+	if collaborator.ID == 0 {
+		return nil, ErrNoSuchCollaborator
+	}
+
+	if err := trx.Model(&collaborator).Update("permission", permission).Error; err != nil {
+		trx.Rollback()
+		return nil, err
+	}
+	return &collaborator, nil
+}
+
+// RemoveCollaborator func stop sharing a bucket with a user
+func RemoveCollaborator(ctx context.Context, bucketID, authID uint) error {
+	result := withContext(ctx, db).Where("bucket_id = ? AND auth_id = ?", bucketID, authID).Delete(Collaborator{})
+	if err := result.Error; err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return ErrNoSuchCollaborator
+	}
+	return nil
+}
+
+// GetCollaborators func get the collaborators of a bucket
+func GetCollaborators(ctx context.Context, bucketID uint) ([]CollaboratorInfo, error) {
+	collaborators := make([]CollaboratorInfo, 0)
+	err := withContext(ctx, db).Table("collaborator").
+		Select("collaborator.*, auth.user_name").
+		Joins("JOIN auth ON auth.id = collaborator.auth_id").
+		Where("collaborator.bucket_id = ?", bucketID).
+		Order("collaborator.id").
+		Scan(&collaborators).
+		Error
+	return collaborators, err
+}
+
+// GetSharedBuckets func get the buckets other users share with the user
+func GetSharedBuckets(ctx context.Context, authID uint, offset int) ([]Bucket, error) {
+	trx := withContext(ctx, db).Begin()
+	defer trx.Commit()
+
+	buckets := make([]Bucket, 0, constant.PageSize)
+	err := trx.Where("id IN (?)", trx.Table("collaborator").Select("bucket_id").Where("auth_id = ?", authID).SubQuery()).
+		Offset(offset).
+		Limit(constant.PageSize).
+		Find(&buckets).
+		Error
+	return buckets, err
+}
+
+// bucketAccessScope func get the scope of the photos in the buckets on which the user has at least the permission
+func bucketAccessScope(authID uint, permission string) func(*gorm.DB) *gorm.DB {
+	permissions := make([]string, 0, len(permissionLevels))
+	for name, level := range permissionLevels {
+		if level >= permissionLevels[permission] {
+			permissions = append(permissions, name)
+		}
+	}
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Where("bucket_id IN (SELECT id FROM bucket WHERE auth_id = ?) OR "+
+			"bucket_id IN (SELECT bucket_id FROM collaborator WHERE auth_id = ? AND permission IN (?))",
+			authID, authID, permissions)
+	}
+}
+
+// isCollaboratorPermission func check if a permission can be granted to a collaborator
+func isCollaboratorPermission(permission string) bool {
+	return permission == constant.PermissionViewer ||
+		permission == constant.PermissionContributor ||
+		permission == constant.PermissionEditor
+}
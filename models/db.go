@@ -40,21 +40,29 @@ func init() {
 	}
 
 	db.SingularTable(true)
-	if !db.HasTable(&Auth{}) {
-		db.CreateTable(&Auth{})
-	}
+	registerTraceCallbacks(db)
 
-	if !db.HasTable(&Bucket{}) {
-		db.CreateTable(&Bucket{})
-	}
+	// create the missing tables and add the missing columns
+	db.AutoMigrate(&Auth{}, &Bucket{}, &Photo{}, &PhotoVersion{}, &Collaborator{}, &APIToken{}, &RecoveryCode{}, &AuthIdentity{})
 
-	if !db.HasTable(&Photo{}) {
-		db.CreateTable(&Photo{})
-	}
+	// photos added before blob_name existed are stored under their names
+	db.Model(&Photo{}).Where("blob_name = ?", "").UpdateColumn("blob_name", gorm.Expr("name"))
+
+	// the configured user is always an admin
+	promoteAdmin()
 
 	// run a goroutine never exit to listen to redis callbacks
 	go listenRedisCallback()
 
+	// run a goroutine never exit to purge the expired trash
+	go purgeTrashPeriodically()
+
+	// run a goroutine never exit to delete the photos of the expired uploads
+	go purgeExpiredUploadsPeriodically()
+
+	// run a goroutine never exit to delete the blobs of the expired exports
+	go purgeExpiredExportsPeriodically()
+
 }
 
 func listenRedisCallback() {
@@ -68,21 +76,26 @@ func listenRedisCallback() {
 	for {
 		select {
 		case msg := <-updateChan:
-			photoID, _ := strconv.Atoi(msg.Payload[:strings.Index(msg.Payload, "-")])
-			photoURL := msg.Payload[strings.Index(msg.Payload, "-")+1:]
-			dberr := UpdatePhotoURL(uint(photoID), photoURL)
+			// the outcome of the upload is traced as a part of the upload
+			ctx, payload := utils.ReceiveFromChannel(msg)
+			photoID, _ := strconv.Atoi(payload[:strings.Index(payload, "-")])
+			photoURL := payload[strings.Index(payload, "-")+1:]
+			dberr := UpdatePhotoURL(ctx, uint(photoID), photoURL)
 			if dberr != nil {
-				utils.AppLogger.Info("callback error: update photo url.", zap.String("service", "listenRedisCallback()"))
+				utils.GetLogger(ctx).Error("callback error: update photo url.", zap.String("service", "listenRedisCallback()"),
+					zap.Int("photo_id", photoID))
 			} else {
-				utils.SetUploadStatus(fmt.Sprintf(constant.PhotoUpdateIDFormat, photoID), 0)
+				utils.SetUploadStatus(ctx, fmt.Sprintf(constant.PhotoUpdateIDFormat, photoID), 0)
 			}
 
 		case msg := <-deleteChan:
-			photoID, _ := strconv.Atoi(msg.Payload)
-			if err := DeletePhotoByID(uint(photoID)); err != nil {
-				utils.AppLogger.Info("callback error: delete photo.", zap.String("service", "listenRedisCallback()"))
+			ctx, payload := utils.ReceiveFromChannel(msg)
+			photoID, _ := strconv.Atoi(payload)
+			if err := DeletePhotoByID(ctx, uint(photoID)); err != nil {
+				utils.GetLogger(ctx).Error("callback error: delete photo.", zap.String("service", "listenRedisCallback()"),
+					zap.Int("photo_id", photoID))
 			} else {
-				utils.SetUploadStatus(fmt.Sprintf(constant.PhotoUpdateIDFormat, photoID), -1)
+				utils.SetUploadStatus(ctx, fmt.Sprintf(constant.PhotoUpdateIDFormat, photoID), -1)
 			}
 		}
 	}
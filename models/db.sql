@@ -10,6 +10,13 @@ create table auth
     user_name varchar(16) unique not null,
     password varchar(255) not null,
     email varchar(128) unique not null,
+    max_photos bigint default 0,
+    max_bytes bigint default 0,
+    role varchar(16) default 'user',
+    state tinyint(1) default 1,
+    email_verified tinyint(1) default 0,
+    totp_secret varchar(64),
+    totp_enabled tinyint(1) default 0,
     created_at timestamp default CURRENT_TIMESTAMP,
     updated_at timestamp default CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
 );
@@ -24,8 +31,12 @@ create table `bucket`
     state tinyint(1) default 1,
     size int default 0,
     description text,
+    max_photos bigint default 0,
+    max_bytes bigint default 0,
+    max_versions int default 0,
     created_at timestamp default CURRENT_TIMESTAMP,
     updated_at timestamp default CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    deleted_at timestamp null,
     CONSTRAINT UC_bucket UNIQUE(auth_id, name),
 	INDEX idx_aid_name (auth_id, name)
 );
@@ -40,10 +51,89 @@ create table `photo`
     name varchar(255) not null,
     tag varchar(255),
     url varchar(255) not null,
+    blob_name varchar(255),
+    size bigint default 0,
+    content_type varchar(64),
+    version int default 1,
     description text,
     state tinyint(1) default 1,
     created_at timestamp default CURRENT_TIMESTAMP,
     updated_at timestamp default CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    deleted_at timestamp null,
     CONSTRAINT UC_photo UNIQUE(bucket_id, name),
 	INDEX idx_bid_name (bucket_id, name)
-);
\ No newline at end of file
+);
+# table photo_version
+drop table if exists `photo_version`;
+create table `photo_version`
+(
+    id int primary key auto_increment,
+    photo_id int,
+    auth_id int,
+    version int,
+    url varchar(255),
+    blob_name varchar(255),
+    size bigint default 0,
+    content_type varchar(64),
+    created_at timestamp default CURRENT_TIMESTAMP,
+    updated_at timestamp default CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_pid_version (photo_id, version)
+);
+
+# table collaborator
+drop table if exists `collaborator`;
+create table `collaborator`
+(
+    id int primary key auto_increment,
+    bucket_id int,
+    auth_id int,
+    permission varchar(16) not null,
+    created_at timestamp default CURRENT_TIMESTAMP,
+    updated_at timestamp default CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT UC_collaborator UNIQUE(bucket_id, auth_id)
+);
+
+# table api_token
+drop table if exists `api_token`;
+create table `api_token`
+(
+    id int primary key auto_increment,
+    auth_id int,
+    name varchar(64),
+    scope varchar(16) not null,
+    prefix varchar(16),
+    token_hash char(64) unique not null,
+    last_used_at timestamp null,
+    revoked_at timestamp null,
+    created_at timestamp default CURRENT_TIMESTAMP,
+    updated_at timestamp default CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_aid (auth_id)
+);
+
+# table recovery_code
+drop table if exists `recovery_code`;
+create table `recovery_code`
+(
+    id int primary key auto_increment,
+    auth_id int,
+    code_hash char(64) not null,
+    used_at timestamp null,
+    created_at timestamp default CURRENT_TIMESTAMP,
+    updated_at timestamp default CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_aid (auth_id)
+);
+
+# table auth_identity
+drop table if exists `auth_identity`;
+create table `auth_identity`
+(
+    id int primary key auto_increment,
+    auth_id int,
+    provider varchar(32) not null,
+    subject varchar(255) not null,
+    email varchar(128),
+    created_at timestamp default CURRENT_TIMESTAMP,
+    updated_at timestamp default CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_aid (auth_id),
+    UNIQUE INDEX idx_provider_subject (provider, subject)
+);
@@ -1,12 +1,19 @@
 package models
 
 import (
-	"errors"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
 	"mime/multipart"
 	"os"
+	"path"
+	"time"
 
 	"go.uber.org/zap"
 
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
 	"github.com/walk1ng/gin-photo-gallery-storage/utils"
 
 	"github.com/walk1ng/gin-photo-gallery-storage/constant"
@@ -17,34 +24,142 @@ import (
 // Photo struct model repesent the photo table
 type Photo struct {
 	BaseModel
-	AuthID      uint     `json:"auth_id" gorm:"type:int" form:"auth_id"`
-	BucketID    uint     `json:"bucket_id" gorm:"type:int" form:"bucket_id"`
-	Name        string   `json:"name" gorm:"type:varchar(255)" form:"name"`
-	Tag         string   `json:"tag" gorm:"type:varchar(255)" form:"tag"`
-	Tags        []string `json:"tags" gorm:"-" form:"tags"`
-	URL         string   `json:"url" gorm:"type:varchar(255)" form:"url"`
-	Description string   `json:"description" gorm:"type:text" form:"description"`
-	State       int      `json:"state" gorm:"type:tinyint(1)" form:"state"`
-}
-
-var ErrPhotoExists = errors.New("photo already exists")
-var ErrNoSuchPhoto = errors.New("no such photo")
-var ErrPhotoFileBroken = errors.New("photo file is broken")
-
-// AddPhoto func add a new photo
-func AddPhoto(photoToAdd *Photo, photoFileHeader *multipart.FileHeader) (*Photo, string, error) {
-	trx := db.Begin()
+	AuthID      uint       `json:"auth_id" gorm:"type:int" form:"-"` // always the owner of the bucket, never bound
+	BucketID    uint       `json:"bucket_id" gorm:"type:int" form:"bucket_id"`
+	Name        string     `json:"name" gorm:"type:varchar(255)" form:"name"`
+	Tag         string     `json:"tag" gorm:"type:varchar(255)" form:"tag"`
+	Tags        []string   `json:"tags" gorm:"-" form:"tags"`
+	URL         string     `json:"url" gorm:"type:varchar(255)" form:"url"`
+	BlobName    string     `json:"blob_name" gorm:"type:varchar(255)" form:"-"`
+	Size        int64      `json:"size" gorm:"type:bigint" form:"-"`
+	ContentType string     `json:"content_type" gorm:"type:varchar(64)" form:"-"`
+	Version     int        `json:"version" gorm:"type:int;default:1" form:"-"`
+	Description string     `json:"description" gorm:"type:text" form:"description"`
+	State       int        `json:"state" gorm:"type:tinyint(1)" form:"state"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty" gorm:"index" form:"-"`
+}
+
+var ErrPhotoExists = apperr.New(constant.PhotoAlreadyExist, "photo already exists")
+var ErrNoSuchPhoto = apperr.New(constant.PhotoNotExist, "no such photo")
+var ErrPhotoFileBroken = apperr.New(constant.PhotoUploadError, "photo file is broken")
+var ErrNoSuchRendition = apperr.New(constant.InvalidParams, "no such rendition")
+var ErrPhotoInTrash = apperr.New(constant.PhotoInTrash, "photo with the same name is in trash")
+var ErrPhotoTypeNotAllowed = apperr.New(constant.PhotoTypeNotAllowed, "photo file type is not allowed")
+var ErrPhotoFileCorrupt = apperr.New(constant.PhotoFileCorrupt, "photo file is corrupt")
+var ErrPhotoFileTooLarge = apperr.New(constant.PhotoFileTooLarge, "photo file is too large")
+
+// AddPhoto func add a new photo, the upload job is traced and logged as part of the request in the context
+func AddPhoto(ctx context.Context, photoToAdd *Photo, photoFileHeader *multipart.FileHeader) (*Photo, string, error) {
+	photoFile, err := openPhotoFile(photoFileHeader)
+	if err != nil {
+		utils.GetLogger(ctx).Debug(err.Error(), zap.String("service", "AddPhoto()"))
+		return nil, "", ErrPhotoFileBroken
+	}
+	return addPhotoFile(ctx, photoToAdd, photoFile)
+}
+
+// addPhotoFile func add a new photo and start uploading its file to the cloud
+func addPhotoFile(ctx context.Context, photoToAdd *Photo, photoFile *os.File) (*Photo, string, error) {
+	if stat, err := photoFile.Stat(); err == nil {
+		photoToAdd.Size = stat.Size()
+	}
+
+	contentType, err := validatePhotoFile(ctx, photoFile, photoToAdd.Name)
+	if err != nil {
+		photoFile.Close()
+		return nil, "", err
+	}
+	photoToAdd.ContentType = contentType
+
+	trx := withContext(ctx, db).Begin()
 	defer trx.Commit()
 
-	// check if the photo exist
+	photo, err := createPhoto(ctx, trx, photoToAdd)
+	if err != nil {
+		photoFile.Close()
+		return nil, "", err
+	}
+
+	ctx = utils.WithLogFields(ctx, zap.Uint("photo_id", photo.ID))
+	utils.GetLogger(ctx).Info("photo added, start uploading.", zap.String("service", "addPhotoFile()"))
+	uploadID := utils.Upload(ctx, photo.ID, photo.BlobName, photo.ContentType, photoFile)
+	return photo, uploadID, nil
+}
+
+// validatePhotoFile func check the photo file is an allowed image and get its content type
+func validatePhotoFile(ctx context.Context, photoFile *os.File, name string) (string, error) {
+	contentType, err := utils.ValidateImage(photoFile, name)
+	switch err {
+	case nil:
+		return contentType, nil
+	case utils.ErrImageTypeNotAllowed:
+		return "", ErrPhotoTypeNotAllowed
+	case utils.ErrImageCorrupt:
+		return "", ErrPhotoFileCorrupt
+	case utils.ErrImageTooLarge:
+		return "", ErrPhotoFileTooLarge
+	default:
+		utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "validatePhotoFile()"))
+		return "", ErrPhotoFileBroken
+	}
+}
+
+// openPhotoFile func open an uploaded file as the *os.File needed by the upload job,
+// small files which multipart keeps in memory are copied to a temp file.
+func openPhotoFile(photoFileHeader *multipart.FileHeader) (*os.File, error) {
+	file, err := photoFileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	if osFile, ok := file.(*os.File); ok {
+		return osFile, nil
+	}
+	defer file.Close()
+	return tempPhotoFile(file)
+}
+
+// tempPhotoFile func copy the reader to an unlinked temp file
+func tempPhotoFile(reader io.Reader) (*os.File, error) {
+	tmp, err := ioutil.TempFile("", "photo-")
+	if err != nil {
+		return nil, err
+	}
+
+	// the opened file is still readable after it is unlinked
+	os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, reader); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	return tmp, nil
+}
+
+// createPhoto func insert a new photo and update its bucket in the transaction
+func createPhoto(ctx context.Context, trx *gorm.DB, photoToAdd *Photo) (*Photo, error) {
+	// check if the photo exist, trashed photos still hold their names
 	photo := Photo{}
-	trx.Set("gorm:query_option", "FOR UPDATE").
+	trx.Unscoped().Set("gorm:query_option", "FOR UPDATE").
 		Where("bucket_id = ? AND name = ?", photoToAdd.BucketID, photoToAdd.Name).
 		First(&photo)
 
 	if photo.ID > 0 {
-		return nil, "", ErrPhotoExists
+		if photo.DeletedAt != nil {
+			return nil, ErrPhotoInTrash
+		}
+		return nil, ErrPhotoExists
+	}
+
+	// photos belong to the owner of the bucket whoever adds them
+	bucket := Bucket{}
+	trx.Where("id = ?", photoToAdd.BucketID).First(&bucket)
+	if bucket.ID == 0 {
+		return nil, ErrNoSuchBucket
 	}
+	photoToAdd.AuthID = bucket.AuthID
 
 	photo.AuthID = photoToAdd.AuthID
 	photo.BucketID = photoToAdd.BucketID
@@ -52,12 +167,28 @@ func AddPhoto(photoToAdd *Photo, photoFileHeader *multipart.FileHeader) (*Photo,
 	photo.Tag = photoToAdd.Tag
 	photo.Description = photoToAdd.Description
 	photo.State = 1
+	photo.Size = photoToAdd.Size
+	photo.ContentType = photoToAdd.ContentType
+	photo.Version = 1
+
+	// check the quotas of the user and the bucket
+	if err := checkQuota(trx, photoToAdd.AuthID, photoToAdd.BucketID, 1, photoToAdd.Size, true); err != nil {
+		return nil, err
+	}
+
+	// every photo file gets its own blob, copies of the photo share it
+	blobPrefix, err := newRandomID()
+	if err != nil {
+		utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "createPhoto()"))
+		return nil, err
+	}
+	photo.BlobName = blobPrefix + "/" + path.Base(photoToAdd.Name)
 
 	// insert the new photo to photo table
-	err := trx.Create(&photo).Error
+	err = trx.Create(&photo).Error
 	if err != nil {
-		utils.AppLogger.Info(err.Error(), zap.String("service", "AddPhoto()"))
-		return nil, "", err
+		utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "createPhoto()"))
+		return nil, err
 	}
 
 	// update the related bucket
@@ -68,54 +199,56 @@ func AddPhoto(photoToAdd *Photo, photoFileHeader *multipart.FileHeader) (*Photo,
 
 	if err != nil {
 		trx.Rollback()
-		utils.AppLogger.Info(err.Error(), zap.String("service", "AddPhoto()"))
-		return nil, "", err
+		utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "createPhoto()"))
+		return nil, err
 	}
 
-	// todo: upload the photo to the cloud
-	if photoFile, err := photoFileHeader.Open(); err == nil {
-		uploadID := utils.Upload(photo.ID, photo.Name, photoFile.(*os.File))
-		return &photo, uploadID, nil
-	} else {
-		utils.AppLogger.Info(err.Error(), zap.String("service", "AddPhoto()"))
-		return nil, "", ErrPhotoFileBroken
-	}
+	return &photo, nil
 }
 
-// DeletePhotoByID func delete a photo by ID
-func DeletePhotoByID(photoID uint) error {
-	trx := db.Begin()
+// DeletePhotoByID func move a photo to the trash by ID, it is called when the upload of a photo fails
+func DeletePhotoByID(ctx context.Context, photoID uint) error {
+	trx := withContext(ctx, db).Begin()
 	defer trx.Commit()
 
-	result := trx.Where("id = ?", photoID).Delete(Photo{})
-	if err := result.Error; err != nil {
-		return err
-	}
-	if result.RowsAffected == 0 {
-		return ErrNoSuchPhoto
-	}
-
-	return nil
+	return trashPhoto(trx, trx.Where("id = ?", photoID))
 }
 
-// DeletePhotoByBucketIDAndPhotoName func delete a photo by its bucket id and its name
-func DeletePhotoByBucketIDAndPhotoName(bucketID uint, name string) error {
-	trx := db.Begin()
+// DeletePhotoByBucketIDAndPhotoName func move a photo to the trash by its bucket id and its name
+func DeletePhotoByBucketIDAndPhotoName(ctx context.Context, bucketID uint, name string) error {
+	trx := withContext(ctx, db).Begin()
 	defer trx.Commit()
 
-	result := trx.Where("bucket_id = ? AND name = ?", bucketID, name).Delete(Photo{})
-	if err := result.Error; err != nil {
+	return trashPhoto(trx, trx.Where("bucket_id = ? AND name = ?", bucketID, name))
+}
+
+// trashPhoto func soft delete the photo found by the query and update its bucket,
+// a photo never uploaded can't be restored so it is deleted for good to free its name.
+func trashPhoto(trx *gorm.DB, query *gorm.DB) error {
+	photo := Photo{}
+	query.Set("gorm:query_option", "FOR UPDATE").First(&photo)
+	if photo.ID == 0 {
+		return ErrNoSuchPhoto
+	}
+
+	deleteQuery := trx
+	if photo.URL == "" {
+		deleteQuery = trx.Unscoped()
+	}
+	if err := deleteQuery.Delete(&photo).Error; err != nil {
+		trx.Rollback()
 		return err
 	}
-	if result.RowsAffected == 0 {
-		return ErrNoSuchPhoto
+	if err := updateBucketSize(trx, photo.BucketID, -1); err != nil {
+		trx.Rollback()
+		return err
 	}
 	return nil
 }
 
 // UpdatePhoto func update a photo
-func UpdatePhoto(photoToUpdate *Photo) (*Photo, error) {
-	trx := db.Begin()
+func UpdatePhoto(ctx context.Context, photoToUpdate *Photo) (*Photo, error) {
+	trx := withContext(ctx, db).Begin()
 	defer trx.Commit()
 
 	photo := Photo{}
@@ -123,7 +256,7 @@ func UpdatePhoto(photoToUpdate *Photo) (*Photo, error) {
 
 	result := trx.Model(&photo).Updates(*photoToUpdate)
 	if err := result.Error; err != nil {
-		utils.AppLogger.Info(err.Error(), zap.String("service", "AddPhoto()"))
+		utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "AddPhoto()"))
 		return nil, err
 	}
 
@@ -135,8 +268,8 @@ func UpdatePhoto(photoToUpdate *Photo) (*Photo, error) {
 }
 
 // UpdatePhotoURL func update the url of a photo
-func UpdatePhotoURL(photoID uint, url string) error {
-	trx := db.Begin()
+func UpdatePhotoURL(ctx context.Context, photoID uint, url string) error {
+	trx := withContext(ctx, db).Begin()
 	defer trx.Commit()
 
 	photo := Photo{}
@@ -144,21 +277,24 @@ func UpdatePhotoURL(photoID uint, url string) error {
 
 	err := trx.Model(&photo).Update("url", url).Error
 	if err != nil {
-		utils.AppLogger.Info(err.Error(), zap.String("service", "AddPhoto()"))
+		utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "AddPhoto()"))
 		return err
 	}
 	return nil
 }
 
 // GetPhotoByID func get the photo by its photo ID
-func GetPhotoByID(photoID uint) (*Photo, error) {
-	trx := db.Begin()
+func GetPhotoByID(ctx context.Context, photoID uint) (*Photo, error) {
+	trx := withContext(ctx, db).Begin()
 	defer trx.Commit()
 
 	photo := Photo{}
 	err := trx.Where("id = ?", photoID).First(&photo).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return &photo, ErrNoSuchPhoto
+	}
 	if err != nil || photoID == 0 {
-		utils.AppLogger.Info(err.Error(), zap.String("service", "AddPhoto()"))
+		utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "AddPhoto()"))
 		return &photo, err
 	}
 
@@ -166,8 +302,8 @@ func GetPhotoByID(photoID uint) (*Photo, error) {
 }
 
 // GetPhotosByBucketID func get photos by its bucket ID
-func GetPhotosByBucketID(bucketID uint, offset int) ([]Photo, error) {
-	trx := db.Begin()
+func GetPhotosByBucketID(ctx context.Context, bucketID uint, offset int) ([]Photo, error) {
+	trx := withContext(ctx, db).Begin()
 	defer trx.Commit()
 
 	photos := make([]Photo, 0, constant.PageSize)
@@ -185,8 +321,8 @@ func GetPhotosByBucketID(bucketID uint, offset int) ([]Photo, error) {
 }
 
 // GetPhotoUploadStatus func check photo upload status
-func GetPhotoUploadStatus(uploadID string) int {
-	status := utils.GetUploadStatus(uploadID)
+func GetPhotoUploadStatus(ctx context.Context, uploadID string) int {
+	status := utils.GetUploadStatus(ctx, uploadID)
 	switch status {
 	case -2:
 		return constant.PhotoNotExist
@@ -200,3 +336,25 @@ func GetPhotoUploadStatus(uploadID string) int {
 		return constant.InvalidParams
 	}
 }
+
+// GetPhotoBlobName func get the name of the blob storing a rendition of the photo
+func GetPhotoBlobName(photo *Photo, rendition string) (string, error) {
+	switch rendition {
+	case "", constant.PhotoRenditionOriginal:
+		if photo.BlobName == "" {
+			return photo.Name, nil
+		}
+		return photo.BlobName, nil
+	default:
+		return "", ErrNoSuchRendition
+	}
+}
+
+// newRandomID func generate a random hex id
+func newRandomID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,268 @@
+package models
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/conf"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+)
+
+// BatchItem struct represent the state of one file in a batch upload
+type BatchItem struct {
+	Name     string `json:"name"`
+	PhotoID  uint   `json:"photo_id"`
+	UploadID string `json:"photo_upload_id"`
+	Code     int    `json:"code"`
+	Msg      string `json:"msg"`
+}
+
+// BatchStatus struct represent the aggregate state of a batch upload
+type BatchStatus struct {
+	BatchID   string      `json:"batch_id"`
+	Total     int         `json:"total"`
+	InProcess int         `json:"in_process"`
+	Success   int         `json:"success"`
+	Failed    int         `json:"failed"`
+	Items     []BatchItem `json:"items"`
+}
+
+var ErrNoSuchBatch = apperr.New(constant.PhotoBatchNotExist, "no such batch")
+var ErrBatchTooLarge = apperr.New(constant.PhotoBatchTooLarge, "batch has too many files or bytes")
+
+// AddPhotoBatch func add every photo file and every file in the zip archives to a bucket,
+// each file becomes a photo with its own upload job. Only the user adding the batch can get its status.
+// The whole batch is rejected before extracting anything when it is too large or exceeds the quotas.
+func AddPhotoBatch(ctx context.Context, photoTemplate *Photo, userName string, photoFiles, archives []*multipart.FileHeader) (*BatchStatus, error) {
+	// open the archives and count the files and bytes before adding anything,
+	// the size in a zip header is enforced by the zip reader which fails an entry larger than it
+	maxBytes := batchMaxBytes()
+	total := len(photoFiles)
+	size := int64(0)
+	for _, photoFile := range photoFiles {
+		size += photoFile.Size
+	}
+	entries := make([]*zip.File, 0)
+	for _, archive := range archives {
+		archiveFile, err := archive.Open()
+		if err != nil {
+			utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "AddPhotoBatch()"))
+			return nil, ErrPhotoFileBroken
+		}
+		defer archiveFile.Close()
+
+		zipReader, err := zip.NewReader(archiveFile, archive.Size)
+		if err != nil {
+			utils.GetLogger(ctx).Debug(err.Error(), zap.String("service", "AddPhotoBatch()"))
+			return nil, ErrPhotoFileBroken
+		}
+		for _, entry := range zipReader.File {
+			if isArchivePhoto(entry) {
+				if entry.UncompressedSize64 > uint64(maxBytes) {
+					return nil, ErrBatchTooLarge
+				}
+				entries = append(entries, entry)
+				size += int64(entry.UncompressedSize64)
+			}
+		}
+	}
+	total += len(entries)
+	if total > constant.BatchMaxFiles || size > maxBytes {
+		return nil, ErrBatchTooLarge
+	}
+	if err := checkBatchQuota(ctx, photoTemplate.BucketID, int64(total), size); err != nil {
+		return nil, err
+	}
+
+	batchID, err := newRandomID()
+	if err != nil {
+		utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "AddPhotoBatch()"))
+		return nil, err
+	}
+
+	if !utils.SetBatchItem(ctx, batchID, constant.BatchOwnerField, userName) {
+		return nil, ErrPhotoFileBroken
+	}
+
+	status := BatchStatus{BatchID: batchID, Items: make([]BatchItem, 0, total)}
+	for _, photoFile := range photoFiles {
+		photoFile := photoFile
+		status.add(addBatchPhoto(ctx, batchID, len(status.Items), photoTemplate, path.Base(photoFile.Filename),
+			func() (*os.File, error) {
+				return openPhotoFile(photoFile)
+			}))
+	}
+	for _, entry := range entries {
+		entry := entry
+		status.add(addBatchPhoto(ctx, batchID, len(status.Items), photoTemplate, path.Base(entry.Name),
+			func() (*os.File, error) {
+				return openArchivePhoto(entry)
+			}))
+	}
+
+	return &status, nil
+}
+
+// GetPhotoBatchStatus func get the state of every file in a batch upload added by the user
+func GetPhotoBatchStatus(ctx context.Context, batchID, userName string) (*BatchStatus, error) {
+	values, err := utils.GetBatchItems(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+	// the batches of other users are hidden as if they don't exist
+	if len(values) == 0 || values[constant.BatchOwnerField] != userName {
+		return nil, ErrNoSuchBatch
+	}
+	delete(values, constant.BatchOwnerField)
+
+	indexes := make([]int, 0, len(values))
+	for field := range values {
+		index, _ := strconv.Atoi(field)
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	status := BatchStatus{BatchID: batchID, Items: make([]BatchItem, 0, len(values))}
+	for _, index := range indexes {
+		item := BatchItem{}
+		if err := json.Unmarshal([]byte(values[strconv.Itoa(index)]), &item); err != nil {
+			utils.GetLogger(ctx).Warn(err.Error(), zap.String("service", "GetPhotoBatchStatus()"))
+			continue
+		}
+
+		// files which were accepted follow the state of their upload job
+		if item.UploadID != "" {
+			item.Code = GetPhotoUploadStatus(ctx, item.UploadID)
+			item.Msg = constant.GetMessage(item.Code)
+		}
+		status.add(item)
+	}
+	return &status, nil
+}
+
+// addBatchPhoto func add one file of a batch upload and record its state
+func addBatchPhoto(ctx context.Context, batchID string, index int, photoTemplate *Photo, name string, open func() (*os.File, error)) BatchItem {
+	item := BatchItem{Name: name}
+	photoToAdd := *photoTemplate
+	photoToAdd.Name = name
+
+	photoFile, err := open()
+	if err == nil {
+		var photo *Photo
+		if photo, item.UploadID, err = addPhotoFile(utils.WithLogFields(ctx, zap.String("batch_id", batchID)), &photoToAdd, photoFile); err == nil {
+			item.PhotoID = photo.ID
+		}
+	} else {
+		utils.GetLogger(ctx).Warn(err.Error(), zap.String("service", "addBatchPhoto()"))
+	}
+
+	switch err {
+	case nil:
+		item.Code = constant.PhotoAddInProcess
+	case ErrPhotoExists:
+		item.Code = constant.PhotoAlreadyExist
+	case ErrPhotoInTrash:
+		item.Code = constant.PhotoInTrash
+	case ErrQuotaExceeded:
+		item.Code = constant.PhotoQuotaExceeded
+	case ErrPhotoTypeNotAllowed:
+		item.Code = constant.PhotoTypeNotAllowed
+	case ErrPhotoFileCorrupt:
+		item.Code = constant.PhotoFileCorrupt
+	case ErrPhotoFileTooLarge:
+		item.Code = constant.PhotoFileTooLarge
+	default:
+		item.Code = constant.PhotoUploadError
+	}
+	item.Msg = constant.GetMessage(item.Code)
+
+	value, _ := json.Marshal(item)
+	if !utils.SetBatchItem(ctx, batchID, strconv.Itoa(index), string(value)) {
+		utils.GetLogger(ctx).Warn("failed to record batch item.", zap.String("service", "addBatchPhoto()"))
+	}
+	return item
+}
+
+// checkBatchQuota func check the whole batch fits in the quotas of the bucket and its owner,
+// each photo of the batch is still checked when it is added
+func checkBatchQuota(ctx context.Context, bucketID uint, photos, bytes int64) error {
+	trx := withContext(ctx, db).Begin()
+	defer trx.Commit()
+
+	bucket := Bucket{}
+	trx.Where("id = ?", bucketID).First(&bucket)
+	if bucket.ID == 0 {
+		return ErrNoSuchBucket
+	}
+	return checkQuota(trx, bucket.AuthID, bucketID, photos, bytes, true)
+}
+
+// batchMaxBytes func get the configured max total size of the files in a batch
+func batchMaxBytes() int64 {
+	maxBytes, err := strconv.ParseInt(conf.ServerCfg.GetDefault(constant.BatchMaxBytes, constant.DefaultBatchMaxBytes), 10, 64)
+	if err != nil || maxBytes <= 0 {
+		maxBytes, _ = strconv.ParseInt(constant.DefaultBatchMaxBytes, 10, 64)
+	}
+	return maxBytes
+}
+
+// openArchivePhoto func copy a zip entry to a temp file, the size in the zip header can't be
+// trusted so the copy stops after the max photo size and a larger entry is rejected.
+func openArchivePhoto(entry *zip.File) (*os.File, error) {
+	entryReader, err := entry.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer entryReader.Close()
+
+	maxSize := utils.MaxImageSize()
+	photoFile, err := tempPhotoFile(io.LimitReader(entryReader, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if stat, err := photoFile.Stat(); err != nil || stat.Size() > maxSize {
+		photoFile.Close()
+		return nil, ErrPhotoFileTooLarge
+	}
+	return photoFile, nil
+}
+
+// add func count an item into the batch status
+func (status *BatchStatus) add(item BatchItem) {
+	status.Total++
+	switch item.Code {
+	case constant.PhotoAddInProcess:
+		status.InProcess++
+	case constant.PhotoUploadSuccess:
+		status.Success++
+	default:
+		status.Failed++
+	}
+	status.Items = append(status.Items, item)
+}
+
+// isArchivePhoto func check if a zip entry should be added as a photo,
+// directories and hidden files such as the __MACOSX metadata are skipped.
+func isArchivePhoto(entry *zip.File) bool {
+	if entry.FileInfo().IsDir() {
+		return false
+	}
+	for _, part := range strings.Split(entry.Name, "/") {
+		if strings.HasPrefix(part, ".") || strings.HasPrefix(part, "__") {
+			return false
+		}
+	}
+	return true
+}
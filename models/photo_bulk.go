@@ -0,0 +1,230 @@
+package models
+
+import (
+	"context"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/jinzhu/gorm"
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+)
+
+// BulkResult struct represent the result of a bulk operation on one photo
+type BulkResult struct {
+	PhotoID    uint   `json:"photo_id"`
+	NewPhotoID uint   `json:"new_photo_id,omitempty"`
+	Code       int    `json:"code"`
+	Msg        string `json:"msg"`
+}
+
+var ErrBulkAborted = apperr.New(constant.PhotoBulkAborted, "bulk operation aborted")
+
+// bulkItemCodes maps the errors of a single item to its response code,
+// any other error aborts the whole bulk operation.
+var bulkItemCodes = map[error]int{
+	ErrNoSuchPhoto:   constant.PhotoNotExist,
+	ErrPhotoExists:   constant.PhotoAlreadyExist,
+	ErrPhotoInTrash:  constant.PhotoInTrash,
+	ErrNoSuchBucket:  constant.BucketNotExist,
+	ErrQuotaExceeded: constant.PhotoQuotaExceeded,
+	ErrPhotoNotReady: constant.PhotoNotReady,
+}
+
+// bulkOperation func apply an operation to one photo in the transaction
+type bulkOperation func(trx *gorm.DB, photo *Photo, result *BulkResult) error
+
+// MovePhotos func move photos the user can edit to another bucket the user can contribute to,
+// the photos moved to a bucket of another owner count in the quota of that owner
+func MovePhotos(ctx context.Context, authID uint, photoIDs []uint, bucketID uint, atomic bool) ([]BulkResult, error) {
+	return runBulk(ctx, authID, photoIDs, atomic, func(trx *gorm.DB, photo *Photo, result *BulkResult) error {
+		if photo.BucketID == bucketID {
+			return nil
+		}
+		bucket, err := checkBulkTarget(ctx, trx, authID, bucketID, photo.Name)
+		if err != nil {
+			return err
+		}
+		if err := checkQuota(trx, bucket.AuthID, bucketID, 1, photo.Size, bucket.AuthID != photo.AuthID); err != nil {
+			return err
+		}
+
+		err = trx.Model(photo).Updates(map[string]interface{}{"bucket_id": bucketID, "auth_id": bucket.AuthID}).Error
+		if err != nil {
+			return err
+		}
+		if err := updateBucketSize(trx, photo.BucketID, -1); err != nil {
+			return err
+		}
+		return updateBucketSize(trx, bucketID, 1)
+	})
+}
+
+// CopyPhotos func copy photos the user can edit to another bucket the user can contribute to,
+// the copies share the blobs and belong to the owner of the bucket. Photos still uploading have no blob to share.
+func CopyPhotos(ctx context.Context, authID uint, photoIDs []uint, bucketID uint, atomic bool) ([]BulkResult, error) {
+	return runBulk(ctx, authID, photoIDs, atomic, func(trx *gorm.DB, photo *Photo, result *BulkResult) error {
+		if photo.URL == "" {
+			return ErrPhotoNotReady
+		}
+		bucket, err := checkBulkTarget(ctx, trx, authID, bucketID, photo.Name)
+		if err != nil {
+			return err
+		}
+		if err := checkQuota(trx, bucket.AuthID, bucketID, 1, photo.Size, true); err != nil {
+			return err
+		}
+
+		photoCopy := *photo
+		photoCopy.BaseModel = BaseModel{}
+		photoCopy.AuthID = bucket.AuthID
+		photoCopy.BucketID = bucketID
+		if err := trx.Create(&photoCopy).Error; err != nil {
+			return err
+		}
+		result.NewPhotoID = photoCopy.ID
+		return updateBucketSize(trx, bucketID, 1)
+	})
+}
+
+// DeletePhotos func move photos the user can edit to the trash, their blobs are removed when the trash is purged.
+// A photo never uploaded can't be restored so it is deleted for good to free its name.
+func DeletePhotos(ctx context.Context, authID uint, photoIDs []uint, atomic bool) ([]BulkResult, error) {
+	return runBulk(ctx, authID, photoIDs, atomic, func(trx *gorm.DB, photo *Photo, result *BulkResult) error {
+		deleteQuery := trx
+		if photo.URL == "" {
+			deleteQuery = trx.Unscoped()
+		}
+		if err := deleteQuery.Delete(photo).Error; err != nil {
+			return err
+		}
+		return updateBucketSize(trx, photo.BucketID, -1)
+	})
+}
+
+// RetagPhotos func add and remove tags of photos the user can edit
+func RetagPhotos(ctx context.Context, authID uint, photoIDs []uint, addTags, removeTags []string, atomic bool) ([]BulkResult, error) {
+	return runBulk(ctx, authID, photoIDs, atomic, func(trx *gorm.DB, photo *Photo, result *BulkResult) error {
+		tags := retag(strings.Split(photo.Tag, ";"), addTags, removeTags)
+		return trx.Model(photo).Update("tag", strings.Join(tags, ";")).Error
+	})
+}
+
+// runBulk func run the operation on every photo in one transaction and report each photo,
+// an atomic bulk operation is rolled back if any photo fails.
+func runBulk(ctx context.Context, authID uint, photoIDs []uint, atomic bool, operation bulkOperation) ([]BulkResult, error) {
+	trx := withContext(ctx, db).Begin()
+
+	results := make([]BulkResult, 0, len(photoIDs))
+	failed := false
+	for _, photoID := range photoIDs {
+		result := BulkResult{PhotoID: photoID}
+
+		photo := Photo{}
+		trx.Set("gorm:query_option", "FOR UPDATE").
+			Scopes(bucketAccessScope(authID, constant.PermissionEditor)).
+			Where("id = ?", photoID).
+			First(&photo)
+
+		var err error
+		if photo.ID == 0 {
+			err = ErrNoSuchPhoto
+		} else {
+			err = operation(trx, &photo, &result)
+		}
+
+		if code, ok := bulkItemCodes[err]; ok {
+			result.Code = code
+			failed = true
+		} else if err != nil {
+			trx.Rollback()
+			utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "runBulk()"))
+			return nil, err
+		} else {
+			result.Code = constant.PhotoBulkSuccess
+		}
+		result.Msg = constant.GetMessage(result.Code)
+		results = append(results, result)
+	}
+
+	if atomic && failed {
+		trx.Rollback()
+		return results, ErrBulkAborted
+	}
+	if err := trx.Commit().Error; err != nil {
+		utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "runBulk()"))
+		return nil, err
+	}
+	return results, nil
+}
+
+// checkBulkTarget func check the user can contribute to the target bucket and it has no photo with the name
+func checkBulkTarget(ctx context.Context, trx *gorm.DB, authID, bucketID uint, name string) (*Bucket, error) {
+	bucket, err := GetBucketAccess(ctx, bucketID, authID, constant.PermissionContributor)
+	if err != nil {
+		return nil, ErrNoSuchBucket
+	}
+
+	photo := Photo{}
+	trx.Unscoped().Where("bucket_id = ? AND name = ?", bucketID, name).First(&photo)
+	if photo.ID > 0 {
+		if photo.DeletedAt != nil {
+			return nil, ErrPhotoInTrash
+		}
+		return nil, ErrPhotoExists
+	}
+	return bucket, nil
+}
+
+// updateBucketSize func change the photo count of a bucket
+func updateBucketSize(trx *gorm.DB, bucketID uint, delta int) error {
+	return trx.Model(&Bucket{}).
+		Where("id = ?", bucketID).
+		Update("size", gorm.Expr("size + ?", delta)).
+		Error
+}
+
+// deleteUnusedBlobs func delete the blobs which are not used by any photo, including trashed photos
+func deleteUnusedBlobs(ctx context.Context, blobNames []string) {
+	for _, blobName := range blobNames {
+		count, versionCount := 0, 0
+		if err := withContext(ctx, db).Unscoped().Model(&Photo{}).Where("blob_name = ?", blobName).Count(&count).Error; err != nil || count > 0 {
+			continue
+		}
+		if err := withContext(ctx, db).Model(&PhotoVersion{}).Where("blob_name = ?", blobName).Count(&versionCount).Error; err != nil || versionCount > 0 {
+			continue
+		}
+		if err := utils.PhotoStorage.Delete(utils.DetachContext(ctx), blobName); err != nil {
+			utils.GetLogger(ctx).Warn(err.Error(), zap.String("service", "deleteUnusedBlobs()"))
+		}
+
+		// the transformed variants go with their original
+		variants, _ := utils.PopPhotoVariants(ctx, blobName)
+		for _, variant := range variants {
+			if err := utils.PhotoStorage.Delete(utils.DetachContext(ctx), variant); err != nil {
+				utils.GetLogger(ctx).Warn(err.Error(), zap.String("service", "deleteUnusedBlobs()"))
+			}
+		}
+	}
+}
+
+// retag func add and remove tags, keeping the order and dropping empty or duplicated tags
+func retag(tags, addTags, removeTags []string) []string {
+	removed := make(map[string]bool, len(removeTags))
+	for _, tag := range removeTags {
+		removed[tag] = true
+	}
+
+	seen := make(map[string]bool, len(tags)+len(addTags))
+	result := make([]string, 0, len(tags)+len(addTags))
+	for _, tag := range append(tags, addTags...) {
+		if tag == "" || removed[tag] || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	return result
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+)
+
+var ErrTransformUnsupported = apperr.New(constant.PhotoTransformFailed, "photo format cannot be transformed")
+
+// GetPhotoVariant func get the blob of a transformed photo, the variant is generated
+// from the original on first use and cached in the storage next to it.
+func GetPhotoVariant(ctx context.Context, photo *Photo, options *utils.TransformOptions) (string, error) {
+	if photo.ContentType != "" && !utils.CanDecodeImage(photo.ContentType) {
+		return "", ErrTransformUnsupported
+	}
+	blobName, err := GetPhotoBlobName(photo, constant.PhotoRenditionOriginal)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256([]byte(options.Key()))
+	variantBlobName := fmt.Sprintf(constant.VariantBlobFormat, blobName, hex.EncodeToString(hash[:8]), options.Format)
+
+	if _, err := utils.PhotoStorage.Properties(ctx, variantBlobName); err == nil {
+		return variantBlobName, nil
+	}
+
+	original, err := utils.PhotoStorage.Download(ctx, blobName, 0, 0)
+	if err != nil {
+		utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "GetPhotoVariant()"))
+		return "", err
+	}
+	defer original.Close()
+
+	variant, err := utils.TransformImage(original, options)
+	if err == utils.ErrImageCorrupt {
+		return "", ErrTransformUnsupported
+	}
+	if err != nil {
+		utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "GetPhotoVariant()"))
+		return "", err
+	}
+
+	variantFile, err := tempPhotoFile(bytes.NewReader(variant))
+	if err != nil {
+		return "", err
+	}
+	defer variantFile.Close()
+
+	if err := utils.PhotoStorage.Upload(ctx, variantBlobName, options.ContentType(), variantFile); err != nil {
+		utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "GetPhotoVariant()"))
+		return "", err
+	}
+	if !utils.AddPhotoVariant(ctx, blobName, variantBlobName) {
+		utils.GetLogger(ctx).Warn("failed to record photo variant.", zap.String("service", "GetPhotoVariant()"))
+	}
+	return variantBlobName, nil
+}
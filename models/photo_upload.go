@@ -0,0 +1,265 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+)
+
+// UploadProgress struct represent the progress of a resumable photo upload
+type UploadProgress struct {
+	PhotoID      uint  `json:"photo_id"`
+	TotalSize    int64 `json:"total_size"`
+	ReceivedSize int64 `json:"received_size"`
+	Chunks       []int `json:"chunks"`
+}
+
+var ErrNoSuchUpload = apperr.New(constant.PhotoUploadNotExist, "no such upload")
+var ErrUploadIncomplete = apperr.New(constant.PhotoUploadIncomplete, "upload is incomplete")
+var ErrInvalidChunk = apperr.New(constant.InvalidParams, "invalid upload chunk")
+
+// InitPhotoUpload func add a new photo whose file will be uploaded in chunks
+func InitPhotoUpload(ctx context.Context, photoToAdd *Photo, userName string, totalSize int64) (*Photo, string, error) {
+	if totalSize > utils.MaxImageSize() {
+		return nil, "", ErrPhotoFileTooLarge
+	}
+	photoToAdd.Size = totalSize
+
+	trx := withContext(ctx, db).Begin()
+	defer trx.Commit()
+
+	photo, err := createPhoto(ctx, trx, photoToAdd)
+	if err != nil {
+		return nil, "", err
+	}
+
+	uploadID := fmt.Sprintf(constant.PhotoUpdateIDFormat, photo.ID)
+	session := utils.UploadSession{
+		UserName:  userName,
+		PhotoID:   photo.ID,
+		BlobName:  photo.BlobName,
+		TotalSize: totalSize,
+	}
+	if err := utils.SaveUploadSession(ctx, uploadID, &session); err != nil || !utils.SetUploadStatus(ctx, uploadID, 1) {
+		trx.Rollback()
+		return nil, "", ErrPhotoFileBroken
+	}
+
+	utils.GetLogger(ctx).Info("resumable upload started.", zap.String("service", "InitPhotoUpload()"),
+		zap.Uint("photo_id", photo.ID), zap.String("upload_id", uploadID))
+	return photo, uploadID, nil
+}
+
+// UploadPhotoChunk func stage the index-th chunk of a resumable photo upload,
+// the content type is detected from the header in the first chunk.
+func UploadPhotoChunk(ctx context.Context, uploadID, userName string, index int, chunk []byte) error {
+	session, err := getUploadSession(ctx, uploadID, userName)
+	if err != nil {
+		return err
+	}
+	if index < 0 || index >= constant.UploadMaxChunks || len(chunk) == 0 || int64(len(chunk)) > session.TotalSize {
+		return ErrInvalidChunk
+	}
+	if index == 0 {
+		if err := setUploadContentType(ctx, uploadID, session, chunk); err != nil {
+			return err
+		}
+	}
+
+	err = utils.PhotoStorage.StageBlock(ctx, session.BlobName, index, bytes.NewReader(chunk))
+	if err != nil {
+		utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "UploadPhotoChunk()"), zap.Uint("photo_id", session.PhotoID))
+		return err
+	}
+	return utils.AddUploadChunk(ctx, uploadID, index, int64(len(chunk)))
+}
+
+// GetPhotoUploadProgress func get the staged chunks of a resumable photo upload
+func GetPhotoUploadProgress(ctx context.Context, uploadID, userName string) (*UploadProgress, error) {
+	session, err := getUploadSession(ctx, uploadID, userName)
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := utils.GetUploadChunks(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := UploadProgress{
+		PhotoID:   session.PhotoID,
+		TotalSize: session.TotalSize,
+		Chunks:    make([]int, 0, len(chunks)),
+	}
+	for index, size := range chunks {
+		progress.Chunks = append(progress.Chunks, index)
+		progress.ReceivedSize += size
+	}
+	sort.Ints(progress.Chunks)
+	return &progress, nil
+}
+
+// CompletePhotoUpload func commit all staged chunks of a resumable photo upload,
+// the file is checked as an added photo file before the photo gets its url and a file
+// which fails the check is deleted with its photo as if the upload was aborted.
+func CompletePhotoUpload(ctx context.Context, uploadID, userName string) (*UploadProgress, error) {
+	progress, err := GetPhotoUploadProgress(ctx, uploadID, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	// chunks must be 0..n-1 without gaps and cover the whole file
+	for i, index := range progress.Chunks {
+		if i != index {
+			return progress, ErrUploadIncomplete
+		}
+	}
+	if len(progress.Chunks) == 0 || progress.ReceivedSize != progress.TotalSize {
+		return progress, ErrUploadIncomplete
+	}
+
+	session, err := utils.GetUploadSession(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if session.ContentType == "" {
+		return progress, ErrUploadIncomplete
+	}
+	err = utils.PhotoStorage.CommitBlocks(ctx, session.BlobName, session.ContentType, len(progress.Chunks))
+	if err != nil {
+		utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "CompletePhotoUpload()"), zap.Uint("photo_id", session.PhotoID))
+		return nil, err
+	}
+
+	// the staged blocks can't be read, so the committed file is decoded before it is published
+	photoFile, err := downloadUploadedFile(ctx, session)
+	if err != nil {
+		utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "CompletePhotoUpload()"), zap.Uint("photo_id", session.PhotoID))
+		return nil, err
+	}
+	defer photoFile.Close()
+	if _, err := validatePhotoFile(ctx, photoFile, session.BlobName); err != nil {
+		if err := utils.PhotoStorage.Delete(ctx, session.BlobName); err != nil {
+			utils.GetLogger(ctx).Warn(err.Error(), zap.String("service", "CompletePhotoUpload()"), zap.Uint("photo_id", session.PhotoID))
+		}
+		if err := AbortPhotoUpload(ctx, uploadID, userName); err != nil {
+			return nil, err
+		}
+		return progress, err
+	}
+
+	// the url update callback marks the upload as success
+	updateURLMessage := fmt.Sprintf("%d-%s", session.PhotoID, utils.PhotoStorage.URL(session.BlobName))
+	if !utils.SendToChannel(ctx, constant.PhotoURLUpdateChannel, updateURLMessage) {
+		utils.GetLogger(ctx).Error("failed to send update-photo-url message to channel.", zap.String("service", "CompletePhotoUpload()"),
+			zap.Uint("photo_id", session.PhotoID))
+	}
+	utils.RemoveUploadSession(ctx, uploadID)
+	return progress, nil
+}
+
+// AbortPhotoUpload func abort a resumable photo upload and delete its photo,
+// the photo was never uploaded so it is deleted for good and its name can be used again.
+func AbortPhotoUpload(ctx context.Context, uploadID, userName string) error {
+	session, err := getUploadSession(ctx, uploadID, userName)
+	if err != nil {
+		return err
+	}
+
+	// staged but uncommitted blocks are garbage collected by the storage
+	if err := DeletePhotoByID(ctx, session.PhotoID); err != nil && err != ErrNoSuchPhoto {
+		utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "AbortPhotoUpload()"), zap.Uint("photo_id", session.PhotoID))
+		return err
+	}
+	utils.SetUploadStatus(ctx, uploadID, -1)
+	utils.RemoveUploadSession(ctx, uploadID)
+	return nil
+}
+
+// purgeExpiredUploads func delete the photos created before the time whose file was never uploaded,
+// uploads still having a session are resumable and kept.
+func purgeExpiredUploads(ctx context.Context, before time.Time) error {
+	photos := make([]Photo, 0)
+	err := withContext(ctx, db).Where("url = ? AND created_at < ?", "", before).Find(&photos).Error
+	if err != nil {
+		utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "purgeExpiredUploads()"))
+		return err
+	}
+
+	for _, photo := range photos {
+		uploadID := fmt.Sprintf(constant.PhotoUpdateIDFormat, photo.ID)
+		if _, err := utils.GetUploadSession(ctx, uploadID); err != utils.ErrNoUploadSession {
+			continue
+		}
+		if err := DeletePhotoByID(ctx, photo.ID); err != nil && err != ErrNoSuchPhoto {
+			utils.GetLogger(ctx).Warn(err.Error(), zap.String("service", "purgeExpiredUploads()"), zap.Uint("photo_id", photo.ID))
+			continue
+		}
+		utils.RemoveUploadSession(ctx, uploadID)
+		utils.GetLogger(ctx).Info("expired upload deleted.", zap.String("service", "purgeExpiredUploads()"), zap.Uint("photo_id", photo.ID))
+	}
+	return nil
+}
+
+// purgeExpiredUploadsPeriodically func delete the photos whose upload session expired
+func purgeExpiredUploadsPeriodically() {
+	ticker := time.NewTicker(constant.UploadCleanInterval * time.Second)
+	for range ticker.C {
+		purgeExpiredUploads(context.Background(), time.Now().Add(-constant.UploadSessionMaxAge*time.Second))
+	}
+}
+
+// setUploadContentType func check the first chunk is an allowed image and record its content type,
+// the whole file is never on the server so only the header can be checked.
+func setUploadContentType(ctx context.Context, uploadID string, session *utils.UploadSession, chunk []byte) error {
+	header := chunk
+	if len(header) > constant.UploadSniffSize {
+		header = header[:constant.UploadSniffSize]
+	}
+	contentType := utils.DetectImageType(header, session.BlobName)
+	if !utils.IsImageTypeAllowed(contentType) {
+		return ErrPhotoTypeNotAllowed
+	}
+
+	err := withContext(ctx, db).Model(&Photo{}).Where("id = ?", session.PhotoID).UpdateColumn("content_type", contentType).Error
+	if err != nil {
+		utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "setUploadContentType()"), zap.Uint("photo_id", session.PhotoID))
+		return err
+	}
+	return utils.SetUploadContentType(ctx, uploadID, contentType)
+}
+
+// downloadUploadedFile func copy the committed file of an upload to a temp file,
+// the copy is limited to the total size the chunks were checked to add up to
+func downloadUploadedFile(ctx context.Context, session *utils.UploadSession) (*os.File, error) {
+	reader, err := utils.PhotoStorage.Download(ctx, session.BlobName, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return tempPhotoFile(io.LimitReader(reader, session.TotalSize))
+}
+
+// getUploadSession func get the upload session owned by the user
+func getUploadSession(ctx context.Context, uploadID, userName string) (*utils.UploadSession, error) {
+	session, err := utils.GetUploadSession(ctx, uploadID)
+	if err == utils.ErrNoUploadSession {
+		return nil, ErrNoSuchUpload
+	}
+	if err != nil {
+		return nil, err
+	}
+	if session.UserName != userName {
+		return nil, ErrNoSuchUpload
+	}
+	return session, nil
+}
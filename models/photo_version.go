@@ -0,0 +1,241 @@
+package models
+
+import (
+	"context"
+	"mime/multipart"
+	"path"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/jinzhu/gorm"
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/conf"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+)
+
+// PhotoVersion struct model represent a previous version of a photo
+type PhotoVersion struct {
+	BaseModel
+	PhotoID     uint   `json:"photo_id" gorm:"type:int;index"`
+	AuthID      uint   `json:"auth_id" gorm:"type:int"`
+	Version     int    `json:"version" gorm:"type:int"`
+	URL         string `json:"url" gorm:"type:varchar(255)"`
+	BlobName    string `json:"blob_name" gorm:"type:varchar(255)"`
+	Size        int64  `json:"size" gorm:"type:bigint"`
+	ContentType string `json:"content_type" gorm:"type:varchar(64)"`
+}
+
+var ErrNoSuchVersion = apperr.New(constant.PhotoVersionNotExist, "no such photo version")
+var ErrPhotoNotReady = apperr.New(constant.PhotoNotReady, "photo is still uploading")
+
+// ReplacePhoto func upload a new version of a photo, the current version is kept in the history
+func ReplacePhoto(ctx context.Context, authID, photoID uint, photoFileHeader *multipart.FileHeader) (*Photo, error) {
+	ctx = utils.WithLogFields(ctx, zap.Uint("photo_id", photoID))
+	photo, err := getVersionedPhoto(withContext(ctx, db), authID, photoID)
+	if err != nil {
+		return nil, err
+	}
+
+	photoFile, err := openPhotoFile(photoFileHeader)
+	if err != nil {
+		utils.GetLogger(ctx).Debug(err.Error(), zap.String("service", "ReplacePhoto()"))
+		return nil, ErrPhotoFileBroken
+	}
+	defer photoFile.Close()
+
+	contentType, err := validatePhotoFile(ctx, photoFile, photo.Name)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := photoFile.Stat()
+	if err != nil {
+		return nil, ErrPhotoFileBroken
+	}
+
+	// the previous version keeps its blob so the new file always gets a new one
+	blobPrefix, err := newRandomID()
+	if err != nil {
+		return nil, err
+	}
+	blobName := blobPrefix + "/" + path.Base(photo.Name)
+
+	trx := withContext(ctx, db).Begin()
+	err = checkQuota(trx, authID, photo.BucketID, 0, stat.Size(), true)
+	trx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	// upload before touching the photo, a failed upload leaves the photo as it was
+	if err := utils.PhotoStorage.Upload(ctx, blobName, contentType, photoFile); err != nil {
+		utils.GetLogger(ctx).Error(err.Error(), zap.String("service", "ReplacePhoto()"))
+		return nil, err
+	}
+
+	current := PhotoVersion{
+		URL:         utils.PhotoStorage.URL(blobName),
+		BlobName:    blobName,
+		Size:        stat.Size(),
+		ContentType: contentType,
+	}
+	photo, prunedBlobs, err := setPhotoVersion(ctx, authID, photoID, &current)
+	if err != nil {
+		deleteUnusedBlobs(ctx, []string{blobName})
+		return nil, err
+	}
+	deleteUnusedBlobs(ctx, prunedBlobs)
+	return photo, nil
+}
+
+// GetPhotoVersions func get the previous versions of a photo, the newest first
+func GetPhotoVersions(ctx context.Context, authID, photoID uint) ([]PhotoVersion, error) {
+	trx := withContext(ctx, db).Begin()
+	defer trx.Commit()
+
+	if _, err := getVersionedPhoto(trx, authID, photoID); err != nil {
+		return nil, err
+	}
+
+	versions := make([]PhotoVersion, 0)
+	err := trx.Where("photo_id = ?", photoID).
+		Order("version DESC").
+		Find(&versions).
+		Error
+	return versions, err
+}
+
+// GetPhotoVersion func get a previous version of a photo
+func GetPhotoVersion(ctx context.Context, photoID uint, version int) (*PhotoVersion, error) {
+	photoVersion := PhotoVersion{}
+	withContext(ctx, db).Where("photo_id = ? AND version = ?", photoID, version).First(&photoVersion)
+	if photoVersion.ID == 0 {
+		return nil, ErrNoSuchVersion
+	}
+	return &photoVersion, nil
+}
+
+// RestorePhotoVersion func make a previous version the current version of a photo,
+// the restored version gets a new version number and leaves the history.
+func RestorePhotoVersion(ctx context.Context, authID, photoID uint, version int) (*Photo, error) {
+	photoVersion, err := GetPhotoVersion(ctx, photoID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	photo, prunedBlobs, err := setPhotoVersion(ctx, authID, photoID, photoVersion)
+	if err != nil {
+		return nil, err
+	}
+	deleteUnusedBlobs(ctx, prunedBlobs)
+	return photo, nil
+}
+
+// setPhotoVersion func move the current file of a photo to the history and replace it with the new version,
+// the blobs of the versions pruned from the history are returned to be deleted after commit.
+func setPhotoVersion(ctx context.Context, authID, photoID uint, newVersion *PhotoVersion) (*Photo, []string, error) {
+	trx := withContext(ctx, db).Begin()
+
+	photo, err := getVersionedPhoto(trx.Set("gorm:query_option", "FOR UPDATE"), authID, photoID)
+	if err != nil {
+		trx.Rollback()
+		return nil, nil, err
+	}
+
+	previous := PhotoVersion{
+		PhotoID:     photo.ID,
+		AuthID:      photo.AuthID,
+		Version:     photo.Version,
+		URL:         photo.URL,
+		BlobName:    photo.BlobName,
+		Size:        photo.Size,
+		ContentType: photo.ContentType,
+	}
+	if err := trx.Create(&previous).Error; err != nil {
+		trx.Rollback()
+		return nil, nil, err
+	}
+
+	// a restored version leaves the history to become the current version
+	if newVersion.ID > 0 {
+		if err := trx.Delete(newVersion).Error; err != nil {
+			trx.Rollback()
+			return nil, nil, err
+		}
+	}
+
+	photo.Version++
+	photo.URL = newVersion.URL
+	photo.BlobName = newVersion.BlobName
+	photo.Size = newVersion.Size
+	photo.ContentType = newVersion.ContentType
+	err = trx.Model(photo).Updates(map[string]interface{}{
+		"version":      photo.Version,
+		"url":          photo.URL,
+		"blob_name":    photo.BlobName,
+		"size":         photo.Size,
+		"content_type": photo.ContentType,
+	}).Error
+	if err != nil {
+		trx.Rollback()
+		return nil, nil, err
+	}
+
+	prunedBlobs, err := pruneVersions(trx, photo)
+	if err != nil {
+		trx.Rollback()
+		return nil, nil, err
+	}
+	if err := trx.Commit().Error; err != nil {
+		return nil, nil, err
+	}
+	return photo, prunedBlobs, nil
+}
+
+// pruneVersions func delete the oldest versions of a photo over the max versions of its bucket,
+// 0 falls back to the configured default and less than 0 means no limit
+func pruneVersions(trx *gorm.DB, photo *Photo) ([]string, error) {
+	bucket := Bucket{}
+	trx.Where("id = ?", photo.BucketID).First(&bucket)
+	maxVersions := bucket.MaxVersions
+	if maxVersions == 0 {
+		maxVersions, _ = strconv.ParseInt(conf.ServerCfg.GetDefault(constant.PhotoMaxVersions, constant.DefaultPhotoMaxVersions), 10, 64)
+	}
+	if maxVersions < 0 {
+		return nil, nil
+	}
+
+	versions := make([]PhotoVersion, 0)
+	err := trx.Where("photo_id = ?", photo.ID).
+		Order("version DESC").
+		Find(&versions).
+		Error
+	if err != nil || int64(len(versions)) <= maxVersions {
+		return nil, err
+	}
+
+	blobNames := make([]string, 0, int64(len(versions))-maxVersions)
+	for _, version := range versions[maxVersions:] {
+		if err := trx.Delete(&version).Error; err != nil {
+			return nil, err
+		}
+		blobNames = append(blobNames, version.BlobName)
+	}
+	return blobNames, nil
+}
+
+// getVersionedPhoto func get a photo of the user whose file can be versioned
+func getVersionedPhoto(trx *gorm.DB, authID, photoID uint) (*Photo, error) {
+	photo := Photo{}
+	trx.Where("id = ? AND auth_id = ?", photoID, authID).First(&photo)
+	if photo.ID == 0 {
+		return nil, ErrNoSuchPhoto
+	}
+
+	// the file of a photo being uploaded is not in the storage yet
+	if photo.URL == "" {
+		return nil, ErrPhotoNotReady
+	}
+	return &photo, nil
+}
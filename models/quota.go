@@ -0,0 +1,163 @@
+package models
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/jinzhu/gorm"
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/conf"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+)
+
+// Usage struct represent the used storage and its quota, a quota of 0 means no limit
+type Usage struct {
+	Photos    int64 `json:"photos"`
+	Bytes     int64 `json:"bytes"`
+	MaxPhotos int64 `json:"max_photos"`
+	MaxBytes  int64 `json:"max_bytes"`
+}
+
+// BucketUsage struct represent the usage of a bucket
+type BucketUsage struct {
+	BucketID uint   `json:"bucket_id"`
+	Name     string `json:"name"`
+	Usage
+}
+
+// AuthUsage struct represent the usage of a user and of each of the user's buckets
+type AuthUsage struct {
+	Usage
+	Buckets []BucketUsage `json:"buckets"`
+}
+
+var ErrQuotaExceeded = apperr.New(constant.PhotoQuotaExceeded, "storage quota exceeded")
+
+// GetUsageByAuthID func get the usage of a user and the user's buckets
+func GetUsageByAuthID(ctx context.Context, authID uint) (*AuthUsage, error) {
+	trx := withContext(ctx, db).Begin()
+	defer trx.Commit()
+
+	usage := AuthUsage{Buckets: make([]BucketUsage, 0)}
+	var err error
+	if usage.Usage, err = getAuthUsage(trx, authID); err != nil {
+		return nil, err
+	}
+
+	buckets := make([]Bucket, 0)
+	if err := trx.Where("auth_id = ?", authID).Find(&buckets).Error; err != nil {
+		return nil, err
+	}
+	for i := range buckets {
+		bucketUsage, err := getBucketUsage(trx, &buckets[i])
+		if err != nil {
+			return nil, err
+		}
+		usage.Buckets = append(usage.Buckets, BucketUsage{
+			BucketID: buckets[i].ID,
+			Name:     buckets[i].Name,
+			Usage:    bucketUsage,
+		})
+	}
+	return &usage, nil
+}
+
+// checkQuota func check the photos and bytes can be added to the bucket,
+// the quota of the user is only checked when new storage is used.
+func checkQuota(trx *gorm.DB, authID, bucketID uint, photos, bytes int64, checkAuth bool) error {
+	if checkAuth {
+		usage, err := getAuthUsage(trx, authID)
+		if err != nil {
+			return err
+		}
+		if usage.exceeded(photos, bytes) {
+			return ErrQuotaExceeded
+		}
+	}
+
+	bucket := Bucket{}
+	trx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", bucketID).First(&bucket)
+	if bucket.ID == 0 {
+		return nil
+	}
+	usage, err := getBucketUsage(trx, &bucket)
+	if err != nil {
+		return err
+	}
+	if usage.exceeded(photos, bytes) {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// getAuthUsage func get the usage of a user, trashed photos use storage until they are purged
+// and the previous versions of photos use storage until they are pruned
+func getAuthUsage(trx *gorm.DB, authID uint) (Usage, error) {
+	auth := Auth{}
+	trx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", authID).First(&auth)
+	auth.ID = authID
+	return authUsage(trx, &auth)
+}
+
+// authUsage func get the usage of a loaded user with the user's effective quota
+func authUsage(trx *gorm.DB, auth *Auth) (Usage, error) {
+	authID := auth.ID
+	usage := Usage{
+		MaxPhotos: quotaOrDefault(auth.MaxPhotos, constant.QuotaUserMaxPhotos),
+		MaxBytes:  quotaOrDefault(auth.MaxBytes, constant.QuotaUserMaxBytes),
+	}
+	err := trx.Unscoped().Model(&Photo{}).
+		Where("auth_id = ?", authID).
+		Select("COUNT(*), COALESCE(SUM(size), 0)").
+		Row().
+		Scan(&usage.Photos, &usage.Bytes)
+	if err != nil {
+		return usage, err
+	}
+
+	var versionBytes int64
+	err = trx.Model(&PhotoVersion{}).
+		Where("auth_id = ?", authID).
+		Select("COALESCE(SUM(size), 0)").
+		Row().
+		Scan(&versionBytes)
+	usage.Bytes += versionBytes
+	return usage, err
+}
+
+// getBucketUsage func get the usage of the photos in a bucket
+func getBucketUsage(trx *gorm.DB, bucket *Bucket) (Usage, error) {
+	usage := Usage{
+		MaxPhotos: quotaOrDefault(bucket.MaxPhotos, ""),
+		MaxBytes:  quotaOrDefault(bucket.MaxBytes, ""),
+	}
+	err := trx.Model(&Photo{}).
+		Where("bucket_id = ?", bucket.ID).
+		Select("COUNT(*), COALESCE(SUM(size), 0)").
+		Row().
+		Scan(&usage.Photos, &usage.Bytes)
+	return usage, err
+}
+
+// exceeded func check if adding the photos and bytes exceeds the quota
+func (usage Usage) exceeded(photos, bytes int64) bool {
+	if usage.MaxPhotos > 0 && usage.Photos+photos > usage.MaxPhotos {
+		return true
+	}
+	if usage.MaxBytes > 0 && usage.Bytes+bytes > usage.MaxBytes {
+		return true
+	}
+	return false
+}
+
+// quotaOrDefault func get the effective quota, 0 falls back to the configured default
+// and less than 0 means no limit
+func quotaOrDefault(quota int64, key string) int64 {
+	if quota < 0 {
+		return 0
+	}
+	if quota == 0 && key != "" {
+		quota, _ = strconv.ParseInt(conf.ServerCfg.GetDefault(key, constant.DefaultQuota), 10, 64)
+	}
+	return quota
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"context"
+
+	"github.com/jinzhu/gorm"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const traceSpanKey = "trace:span"
+
+// withContext func get a db whose queries are traced as children of the span in the context
+func withContext(ctx context.Context, db *gorm.DB) *gorm.DB {
+	return db.Set(constant.TraceContextKey, ctx)
+}
+
+// registerTraceCallbacks func wrap the sql statement of each operation in a span,
+// only the operations run on a db from withContext are traced.
+func registerTraceCallbacks(db *gorm.DB) {
+	callbacks := db.Callback()
+	processors := []struct {
+		processor *gorm.CallbackProcessor
+		operation string
+		callback  string
+	}{
+		{callbacks.Create(), "INSERT", "gorm:create"},
+		{callbacks.Query(), "SELECT", "gorm:query"},
+		{callbacks.RowQuery(), "SELECT", "gorm:row_query"},
+		{callbacks.Update(), "UPDATE", "gorm:update"},
+		{callbacks.Delete(), "DELETE", "gorm:delete"},
+	}
+	for _, p := range processors {
+		operation := p.operation
+		p.processor.Before(p.callback).Register("trace:before_"+p.callback, func(scope *gorm.Scope) {
+			value, ok := scope.Get(constant.TraceContextKey)
+			if !ok {
+				return
+			}
+			ctx, _ := value.(context.Context)
+			if ctx == nil || !trace.SpanContextFromContext(ctx).IsValid() {
+				return
+			}
+			_, span := utils.Tracer.Start(ctx, operation+" "+scope.TableName(), trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					attribute.String("db.system", "mysql"),
+					attribute.String("db.operation", operation),
+					attribute.String("db.sql.table", scope.TableName()),
+				))
+			scope.InstanceSet(traceSpanKey, span)
+		})
+		p.processor.After(p.callback).Register("trace:after_"+p.callback, func(scope *gorm.Scope) {
+			value, ok := scope.InstanceGet(traceSpanKey)
+			if !ok {
+				return
+			}
+			span := value.(trace.Span)
+			span.SetAttributes(attribute.String("db.statement", scope.SQL), attribute.Int64("db.rows_affected", scope.DB().RowsAffected))
+			if err := scope.DB().Error; err != nil && err != gorm.ErrRecordNotFound {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package models
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/conf"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
+)
+
+// Trash struct represent the trashed buckets and photos of a user
+type Trash struct {
+	Buckets []Bucket `json:"buckets"`
+	Photos  []Photo  `json:"photos"`
+}
+
+// GetTrashByAuthID func get the trashed buckets and photos of the user
+func GetTrashByAuthID(ctx context.Context, authID uint, offset int) (*Trash, error) {
+	trx := withContext(ctx, db).Begin()
+	defer trx.Commit()
+
+	trash := Trash{
+		Buckets: make([]Bucket, 0, constant.PageSize),
+		Photos:  make([]Photo, 0, constant.PageSize),
+	}
+	err := trx.Unscoped().
+		Where("auth_id = ? AND deleted_at IS NOT NULL", authID).
+		Order("deleted_at DESC").
+		Offset(offset).
+		Limit(constant.PageSize).
+		Find(&trash.Buckets).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = trx.Unscoped().
+		Where("auth_id = ? AND deleted_at IS NOT NULL", authID).
+		Order("deleted_at DESC").
+		Offset(offset).
+		Limit(constant.PageSize).
+		Find(&trash.Photos).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	return &trash, nil
+}
+
+// RestorePhoto func restore a trashed photo of the user to its bucket
+func RestorePhoto(ctx context.Context, authID, photoID uint) (*Photo, error) {
+	trx := withContext(ctx, db).Begin()
+	defer trx.Commit()
+
+	photo := Photo{}
+	trx.Unscoped().Set("gorm:query_option", "FOR UPDATE").
+		Where("id = ? AND auth_id = ? AND deleted_at IS NOT NULL", photoID, authID).
+		First(&photo)
+
+	// photos whose upload failed have nothing to restore
+	if photo.ID == 0 || photo.URL == "" {
+		return nil, ErrNoSuchPhoto
+	}
+
+	// the bucket must be restored first
+	bucket := Bucket{}
+	trx.Where("id = ?", photo.BucketID).First(&bucket)
+	if bucket.ID == 0 {
+		return nil, ErrNoSuchBucket
+	}
+
+	if err := trx.Unscoped().Model(&photo).UpdateColumn("deleted_at", nil).Error; err != nil {
+		trx.Rollback()
+		return nil, err
+	}
+	if err := updateBucketSize(trx, photo.BucketID, 1); err != nil {
+		trx.Rollback()
+		return nil, err
+	}
+	return &photo, nil
+}
+
+// RestoreBucket func restore a trashed bucket of the user with the photos trashed together with it
+func RestoreBucket(ctx context.Context, authID, bucketID uint) (*Bucket, error) {
+	trx := withContext(ctx, db).Begin()
+	defer trx.Commit()
+
+	bucket := Bucket{}
+	trx.Unscoped().Set("gorm:query_option", "FOR UPDATE").
+		Where("id = ? AND auth_id = ? AND deleted_at IS NOT NULL", bucketID, authID).
+		First(&bucket)
+	if bucket.ID == 0 {
+		return nil, ErrNoSuchBucket
+	}
+
+	err := trx.Unscoped().Model(&Photo{}).
+		Where("bucket_id = ? AND deleted_at = ?", bucketID, bucket.DeletedAt).
+		UpdateColumn("deleted_at", nil).
+		Error
+	if err != nil {
+		trx.Rollback()
+		return nil, err
+	}
+
+	if err := trx.Unscoped().Model(&bucket).UpdateColumn("deleted_at", nil).Error; err != nil {
+		trx.Rollback()
+		return nil, err
+	}
+	return &bucket, nil
+}
+
+// PurgeTrash func permanently delete the buckets and photos trashed before the time and their blobs,
+// auth id 0 means the trash of all users.
+func PurgeTrash(ctx context.Context, authID uint, before time.Time) error {
+	trx := withContext(ctx, db).Begin()
+
+	query := trx.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", before)
+	if authID > 0 {
+		query = query.Where("auth_id = ?", authID)
+	}
+
+	photos := make([]Photo, 0)
+	if err := query.Find(&photos).Error; err != nil {
+		trx.Rollback()
+		return err
+	}
+
+	// the previous versions are purged together with their photos
+	photoIDs := make([]uint, 0, len(photos))
+	for _, photo := range photos {
+		photoIDs = append(photoIDs, photo.ID)
+	}
+	versions := make([]PhotoVersion, 0)
+	if len(photoIDs) > 0 {
+		if err := trx.Where("photo_id IN (?)", photoIDs).Find(&versions).Error; err != nil {
+			trx.Rollback()
+			return err
+		}
+		if err := trx.Where("photo_id IN (?)", photoIDs).Delete(PhotoVersion{}).Error; err != nil {
+			trx.Rollback()
+			return err
+		}
+	}
+	if err := query.Delete(Photo{}).Error; err != nil {
+		trx.Rollback()
+		return err
+	}
+	// the collaborators of the purged buckets are dropped with them
+	bucketIDs := query.Table("bucket").Select("id").SubQuery()
+	if err := trx.Where("bucket_id IN (?)", bucketIDs).Delete(Collaborator{}).Error; err != nil {
+		trx.Rollback()
+		return err
+	}
+	if err := query.Delete(Bucket{}).Error; err != nil {
+		trx.Rollback()
+		return err
+	}
+	if err := trx.Commit().Error; err != nil {
+		return err
+	}
+
+	blobNames := make([]string, 0, len(photos)+len(versions))
+	for _, photo := range photos {
+		blobNames = append(blobNames, photo.BlobName)
+	}
+	for _, version := range versions {
+		blobNames = append(blobNames, version.BlobName)
+	}
+	deleteUnusedBlobs(ctx, blobNames)
+	return nil
+}
+
+// purgeTrashPeriodically func purge the trash older than the retention forever
+func purgeTrashPeriodically() {
+	retentionHours, err := strconv.Atoi(conf.ServerCfg.GetDefault(constant.TrashRetentionHours, constant.DefaultTrashRetentionHours))
+	if err != nil {
+		utils.AppLogger.Fatal(err.Error(), zap.String("service", "purgeTrashPeriodically()"))
+	}
+	intervalMinutes, err := strconv.Atoi(conf.ServerCfg.GetDefault(constant.TrashPurgeIntervalMinutes, constant.DefaultTrashPurgeIntervalMinutes))
+	if err != nil || intervalMinutes <= 0 {
+		utils.AppLogger.Fatal("invalid trash purge interval.", zap.String("service", "purgeTrashPeriodically()"))
+	}
+
+	ticker := time.NewTicker(time.Duration(intervalMinutes) * time.Minute)
+	for range ticker.C {
+		before := time.Now().Add(-time.Duration(retentionHours) * time.Hour)
+		if err := PurgeTrash(context.Background(), 0, before); err != nil {
+			utils.AppLogger.Error(err.Error(), zap.String("service", "purgeTrashPeriodically()"))
+		}
+	}
+}
@@ -1,19 +1,47 @@
 package routers
 
 import (
+	"go.uber.org/zap"
+
 	"github.com/gin-gonic/gin"
 	v1 "github.com/walk1ng/gin-photo-gallery-storage/apis/v1"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
 	"github.com/walk1ng/gin-photo-gallery-storage/middlewares"
+	"github.com/walk1ng/gin-photo-gallery-storage/utils"
 )
 
 var Router *gin.Engine
 
 func init() {
-	Router = gin.Default()
+	// the output of gin itself goes to the app log
+	gin.DefaultWriter = &utils.LogWriter{Logger: utils.AppLogger, Level: zap.DebugLevel, Service: "gin"}
+	gin.DefaultErrorWriter = &utils.LogWriter{Logger: utils.AppLogger, Level: zap.ErrorLevel, Service: "gin"}
+
+	Router = gin.New()
+	// the handlers use the gin context to reach the request logger
+	Router.ContextWithFallback = true
+	// the rate limits and the login lockout are keyed on the client ip,
+	// so X-Forwarded-For is only trusted from the configured proxies
+	if err := Router.SetTrustedProxies(utils.GetTrustedProxies()); err != nil {
+		utils.AppLogger.Fatal(err.Error(), zap.String("service", "init()"))
+	}
+	Router.Use(middlewares.GetRequestIDMiddleware(), middlewares.GetTracingMiddleware(),
+		middlewares.GetAccessLogMiddleware(), middlewares.GetRecoveryMiddleware())
 
 	authMiddleware := middlewares.GetAuthMiddleware()
-	refreshMiddleware := middlewares.GetRefreshMiddleware()
 	paginationMiddleware := middlewares.GetPaginationMiddleware()
+	writeMiddleware := middlewares.GetRoleMiddleware(constant.RoleAdmin, constant.RoleUser)
+	adminMiddleware := middlewares.GetRoleMiddleware(constant.RoleAdmin)
+	uploadMiddleware := middlewares.GetRoleMiddleware(constant.RoleAdmin, constant.RoleUser, constant.RoleUploader)
+	authLimitMiddleware := middlewares.GetRateLimitMiddleware(constant.RateLimitAuth)
+	uploadLimitMiddleware := middlewares.GetRateLimitMiddleware(constant.RateLimitUpload)
+	listLimitMiddleware := middlewares.GetRateLimitMiddleware(constant.RateLimitList)
+
+	// every client ip is limited before the per-route limits
+	Router.Use(middlewares.GetRateLimitMiddleware(constant.RateLimitGlobal))
+
+	// public keys of the jwt
+	Router.GET("/.well-known/jwks.json", v1.GetJWKS)
 
 	v1Group := Router.Group("/api/v1")
 	{
@@ -21,28 +49,121 @@ func init() {
 		authGroup := v1Group.Group("/auth")
 		{
 			authGroup.POST("/add", v1.AddAuth)
-			authGroup.POST("/check", v1.CheckAuth)
+			authGroup.POST("/check", authLimitMiddleware, v1.CheckAuth)
+			authGroup.POST("/refresh", authLimitMiddleware, v1.RefreshAuth)
+
+			// two-factor authentication
+			authGroup.POST("/2fa/verify", authLimitMiddleware, v1.VerifyMFA)
+			authGroup.POST("/2fa/enroll", authMiddleware, v1.EnrollMFA)
+			authGroup.POST("/2fa/confirm", authMiddleware, v1.ConfirmMFA)
+			authGroup.POST("/2fa/disable", authMiddleware, v1.DisableMFA)
+
+			// openid connect login
+			authGroup.GET("/oidc/providers", v1.GetOIDCProviders)
+			authGroup.GET("/oidc/login", v1.StartOIDCLogin)
+			authGroup.GET("/oidc/callback", v1.FinishOIDCLogin)
+
+			// email verification and password reset
+			authGroup.GET("/verify", v1.VerifyEmail)
+			authGroup.POST("/verify/resend", authMiddleware, v1.ResendVerification)
+			authGroup.POST("/password/reset_request", authLimitMiddleware, v1.RequestPasswordReset)
+			authGroup.POST("/password/reset", authLimitMiddleware, v1.ResetPassword)
+			authGroup.GET("/usage", authMiddleware, v1.GetAuthUsage)
+
+			// profile and account
+			authGroup.GET("/profile", authMiddleware, v1.GetProfile)
+			authGroup.PUT("/profile/email", authMiddleware, v1.UpdateEmail)
+			authGroup.PUT("/profile/password", authMiddleware, v1.ChangePassword)
+			authGroup.POST("/export_async", authMiddleware, v1.StartAccountExport)
+			authGroup.GET("/export_status", authMiddleware, v1.GetBucketExportStatus)
+			authGroup.GET("/export_download", authMiddleware, v1.DownloadBucketExport)
+			authGroup.POST("/delete", authMiddleware, v1.DeleteAccount)
+
+			// personal api tokens
+			authGroup.POST("/token/add", authMiddleware, v1.AddAPIToken)
+			authGroup.GET("/token/list", authMiddleware, v1.GetAPITokens)
+			authGroup.DELETE("/token/revoke", authMiddleware, v1.RevokeAPIToken)
 		}
 
 		// bucket
 		bucketGroup := v1Group.Group("/bucket")
 		{
-			bucketGroup.POST("/add", authMiddleware, refreshMiddleware, v1.AddBucket)
-			bucketGroup.DELETE("/delete", authMiddleware, refreshMiddleware, v1.DeleteBucket)
-			bucketGroup.PUT("/update", authMiddleware, refreshMiddleware, v1.UpdateBucket)
-			bucketGroup.GET("/get_by_id", authMiddleware, refreshMiddleware, v1.GetBucketByID)
-			bucketGroup.GET("/get_by_auth_id", authMiddleware, refreshMiddleware, paginationMiddleware, v1.GetBucketByAuthID)
+			bucketGroup.POST("/add", authMiddleware, writeMiddleware, v1.AddBucket)
+			bucketGroup.DELETE("/delete", authMiddleware, writeMiddleware, v1.DeleteBucket)
+			bucketGroup.PUT("/update", authMiddleware, writeMiddleware, v1.UpdateBucket)
+			bucketGroup.GET("/get_by_id", authMiddleware, v1.GetBucketByID)
+			bucketGroup.GET("/get_by_auth_id", authMiddleware, paginationMiddleware, listLimitMiddleware, v1.GetBucketByAuthID)
+			bucketGroup.GET("/get_shared", authMiddleware, paginationMiddleware, listLimitMiddleware, v1.GetSharedBuckets)
+
+			// collaborators
+			bucketGroup.POST("/collaborator/add", authMiddleware, writeMiddleware, v1.AddCollaborator)
+			bucketGroup.PUT("/collaborator/update", authMiddleware, writeMiddleware, v1.UpdateCollaborator)
+			bucketGroup.DELETE("/collaborator/remove", authMiddleware, writeMiddleware, v1.RemoveCollaborator)
+			bucketGroup.GET("/collaborator/list", authMiddleware, v1.GetCollaborators)
+
+			// export
+			bucketGroup.GET("/export", authMiddleware, v1.ExportBucket)
+			bucketGroup.POST("/export_async", authMiddleware, v1.StartBucketExport)
+			bucketGroup.GET("/export_status", authMiddleware, v1.GetBucketExportStatus)
+			bucketGroup.GET("/export_download", authMiddleware, v1.DownloadBucketExport)
 		}
 
 		// photo
 		photoGroup := v1Group.Group("/photo")
 		{
-			photoGroup.POST("/add", authMiddleware, refreshMiddleware, v1.AddPhoto)
-			photoGroup.DELETE("/delete", authMiddleware, refreshMiddleware, v1.DeletePhoto)
-			photoGroup.PUT("/update", authMiddleware, refreshMiddleware, v1.UpdatePhoto)
-			photoGroup.GET("/get_by_id", authMiddleware, refreshMiddleware, v1.GetPhotoByID)
-			photoGroup.GET("/get_by_bucket_id", authMiddleware, refreshMiddleware, paginationMiddleware, v1.GetPhotoByBucketID)
-			photoGroup.GET("/upload_status", authMiddleware, refreshMiddleware, v1.GetPhotoUploadStatus)
+			photoGroup.POST("/add", authMiddleware, uploadMiddleware, uploadLimitMiddleware, v1.AddPhoto)
+			photoGroup.DELETE("/delete", authMiddleware, writeMiddleware, v1.DeletePhoto)
+			photoGroup.PUT("/update", authMiddleware, writeMiddleware, v1.UpdatePhoto)
+			photoGroup.GET("/get_by_id", authMiddleware, v1.GetPhotoByID)
+			photoGroup.GET("/get_by_bucket_id", authMiddleware, paginationMiddleware, listLimitMiddleware, v1.GetPhotoByBucketID)
+			photoGroup.GET("/upload_status", authMiddleware, v1.GetPhotoUploadStatus)
+			photoGroup.GET("/content", authMiddleware, v1.GetPhotoContent)
+			photoGroup.GET("/transform", authMiddleware, v1.TransformPhoto)
+
+			// resumable upload
+			photoGroup.POST("/upload/init", authMiddleware, uploadMiddleware, uploadLimitMiddleware, v1.InitPhotoUpload)
+			photoGroup.PUT("/upload/chunk", authMiddleware, uploadMiddleware, uploadLimitMiddleware, v1.UploadPhotoChunk)
+			photoGroup.GET("/upload/progress", authMiddleware, v1.GetPhotoUploadProgress)
+			photoGroup.POST("/upload/complete", authMiddleware, uploadMiddleware, uploadLimitMiddleware, v1.CompletePhotoUpload)
+			photoGroup.DELETE("/upload/abort", authMiddleware, uploadMiddleware, uploadLimitMiddleware, v1.AbortPhotoUpload)
+
+			// batch upload
+			photoGroup.POST("/batch_add", authMiddleware, uploadMiddleware, uploadLimitMiddleware, v1.AddPhotoBatch)
+			photoGroup.GET("/batch_status", authMiddleware, v1.GetPhotoBatchStatus)
+
+			// bulk operations
+			photoGroup.POST("/bulk/move", authMiddleware, writeMiddleware, v1.MovePhotos)
+			photoGroup.POST("/bulk/copy", authMiddleware, writeMiddleware, v1.CopyPhotos)
+			photoGroup.POST("/bulk/delete", authMiddleware, writeMiddleware, v1.DeletePhotos)
+			photoGroup.POST("/bulk/retag", authMiddleware, writeMiddleware, v1.RetagPhotos)
+
+			// versions
+			photoGroup.POST("/replace", authMiddleware, writeMiddleware, uploadLimitMiddleware, v1.ReplacePhoto)
+			photoGroup.GET("/versions", authMiddleware, v1.GetPhotoVersions)
+			photoGroup.PUT("/versions/restore", authMiddleware, writeMiddleware, v1.RestorePhotoVersion)
+		}
+
+		// trash
+		trashGroup := v1Group.Group("/trash")
+		{
+			trashGroup.GET("/get", authMiddleware, paginationMiddleware, listLimitMiddleware, v1.GetTrash)
+			trashGroup.PUT("/restore_photo", authMiddleware, writeMiddleware, v1.RestorePhoto)
+			trashGroup.PUT("/restore_bucket", authMiddleware, writeMiddleware, v1.RestoreBucket)
+			trashGroup.DELETE("/empty", authMiddleware, writeMiddleware, v1.EmptyTrash)
+		}
+
+		// admin
+		adminGroup := v1Group.Group("/admin", authMiddleware, adminMiddleware)
+		{
+			adminGroup.GET("/users", paginationMiddleware, listLimitMiddleware, v1.GetUsers)
+			adminGroup.PUT("/user/state", v1.SetUserState)
+			adminGroup.PUT("/user/role", v1.SetUserRole)
+			adminGroup.PUT("/user/quota", v1.SetUserQuota)
+			adminGroup.PUT("/user/unlock", v1.UnlockUser)
+			adminGroup.GET("/bucket", paginationMiddleware, listLimitMiddleware, v1.InspectBucket)
+			adminGroup.GET("/usage", v1.GetSystemUsage)
+			adminGroup.GET("/log_level", v1.GetLogLevel)
+			adminGroup.PUT("/log_level", v1.SetLogLevel)
 		}
 	}
 }
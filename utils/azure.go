@@ -2,10 +2,17 @@ package utils
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
+	"io"
+	"mime"
 	"net/url"
 	"os"
+	"path/filepath"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 
 	"github.com/walk1ng/gin-photo-gallery-storage/conf"
@@ -33,44 +40,142 @@ func init() {
 
 	p := azblob.NewPipeline(cred, azblob.PipelineOptions{})
 	containerURL = azblob.NewContainerURL(*URL, p)
+	PhotoStorage = &azureStorage{containerURL: containerURL}
 }
 
-// Upload func upload a photo to azure blob storage
-func Upload(photoID uint, fileName string, file *os.File) string {
+// azureStorage struct implements Storage on an azure blob container
+type azureStorage struct {
+	containerURL azblob.ContainerURL
+}
+
+// Upload func upload a local file to a block blob
+func (s *azureStorage) Upload(ctx context.Context, blobName string, contentType string, file *os.File) error {
+	blobURL := s.containerURL.NewBlockBlobURL(blobName)
+	_, err := azblob.UploadFileToBlockBlob(ctx, file, blobURL, azblob.UploadToBlockBlobOptions{
+		BlockSize:       4 * 1024 * 1024,
+		Parallelism:     16,
+		BlobHTTPHeaders: azblob.BlobHTTPHeaders{ContentType: blobContentType(blobName, contentType)},
+	})
+	return err
+}
+
+// StageBlock func stage a block of a block blob
+func (s *azureStorage) StageBlock(ctx context.Context, blobName string, index int, data io.ReadSeeker) error {
+	blobURL := s.containerURL.NewBlockBlobURL(blobName)
+	_, err := blobURL.StageBlock(ctx, azureBlockID(index), data, azblob.LeaseAccessConditions{}, nil, azblob.ClientProvidedKeyOptions{})
+	return err
+}
+
+// CommitBlocks func commit the staged blocks of a block blob in order
+func (s *azureStorage) CommitBlocks(ctx context.Context, blobName string, contentType string, count int) error {
+	blockIDs := make([]string, count)
+	for i := range blockIDs {
+		blockIDs[i] = azureBlockID(i)
+	}
+
+	blobURL := s.containerURL.NewBlockBlobURL(blobName)
+	_, err := blobURL.CommitBlockList(ctx, blockIDs,
+		azblob.BlobHTTPHeaders{ContentType: blobContentType(blobName, contentType)},
+		azblob.Metadata{}, azblob.BlobAccessConditions{}, azblob.DefaultAccessTier, nil,
+		azblob.ClientProvidedKeyOptions{}, azblob.ImmutabilityPolicyOptions{})
+	return err
+}
+
+// blobContentType func get the content type of a blob, it falls back to the extension of the blob name
+func blobContentType(blobName, contentType string) string {
+	if contentType != "" {
+		return contentType
+	}
+	return mime.TypeByExtension(filepath.Ext(blobName))
+}
+
+// azureBlockID func get the block id of the index-th block, all ids of a blob must have the same length
+// so the index must be below constant.UploadMaxChunks.
+func azureBlockID(index int) string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%08d", index)))
+}
+
+// Download func open a ranged download stream of a blob
+func (s *azureStorage) Download(ctx context.Context, blobName string, offset, count int64) (io.ReadCloser, error) {
+	blobURL := s.containerURL.NewBlobURL(blobName)
+	resp, err := blobURL.Download(ctx, offset, count, azblob.BlobAccessConditions{}, false, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body(azblob.RetryReaderOptions{MaxRetryRequests: 3}), nil
+}
+
+// Properties func get the properties of a blob
+func (s *azureStorage) Properties(ctx context.Context, blobName string) (BlobProperties, error) {
+	blobURL := s.containerURL.NewBlobURL(blobName)
+	resp, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return BlobProperties{}, err
+	}
+	return BlobProperties{
+		Size:         resp.ContentLength(),
+		ContentType:  resp.ContentType(),
+		ETag:         string(resp.ETag()),
+		LastModified: resp.LastModified(),
+	}, nil
+}
+
+// Delete func delete a blob with its snapshots
+func (s *azureStorage) Delete(ctx context.Context, blobName string) error {
+	blobURL := s.containerURL.NewBlobURL(blobName)
+	_, err := blobURL.Delete(ctx, azblob.DeleteSnapshotsOptionInclude, azblob.BlobAccessConditions{})
+	return err
+}
+
+// URL func get the url of a blob
+func (s *azureStorage) URL(blobName string) string {
+	return fmt.Sprintf(constant.AzStorageBlobURLEndpointFormat, azStorageAccountName, azStorageContainerName) + "/" + blobName
+}
+
+// Upload func upload a photo to azure blob storage,
+// the upload job keeps the trace and the logger of the request in the context.
+func Upload(ctx context.Context, photoID uint, fileName string, contentType string, file *os.File) string {
 	uploadID := fmt.Sprintf(constant.PhotoUpdateIDFormat, photoID)
-	go AsyncUpload(uploadID, photoID, fileName, file)
+	jobCtx := WithLogFields(DetachContext(ctx), zap.String("upload_id", uploadID))
+	go AsyncUpload(jobCtx, uploadID, photoID, fileName, contentType, file)
 	return uploadID
 }
 
 // AsyncUpload func upload a photo to the azure blob storage async
-func AsyncUpload(uploadID string, photoID uint, fileName string, file *os.File) {
+func AsyncUpload(ctx context.Context, uploadID string, photoID uint, fileName string, contentType string, file *os.File) {
+	defer file.Close()
+
+	ctx, span := Tracer.Start(ctx, "AsyncUpload", trace.WithAttributes(
+		attribute.String("upload.id", uploadID), attribute.Int64("photo.id", int64(photoID))))
+	defer span.End()
+
 	// set upload status in redis
-	if !SetUploadStatus(uploadID, 1) {
-		AppLogger.Info("failed to set upload status before upload.", zap.String("service", "AsyncUpload()"))
+	if !SetUploadStatus(ctx, uploadID, 1) {
+		GetLogger(ctx).Error("failed to set upload status before upload.", zap.String("service", "AsyncUpload()"))
 		return
 	}
 
-	// upload the photo to az blob storage
-	blobURL := containerURL.NewBlockBlobURL(fileName)
-	_, err := azblob.UploadFileToBlockBlob(context.Background(), file, blobURL, azblob.UploadToBlockBlobOptions{
-		BlockSize:   4 * 1024 * 1024,
-		Parallelism: 16,
-	})
+	// upload the photo to the photo storage
+	err := PhotoStorage.Upload(ctx, fileName, contentType, file)
 
 	// if failed to upload, send callback to redis to delete photo
 	if err != nil {
-		AppLogger.Info(err.Error(), zap.String("service", "AsyncUpload()"))
-		if !SendToChannel(constant.PhotoDeleteChannel, fmt.Sprintf("%d", photoID)) {
-			AppLogger.Info("failed to send delete-photo message to channel.", zap.String("service", "AsyncUpload()"))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "AsyncUpload()"))
+		if !SendToChannel(ctx, constant.PhotoDeleteChannel, fmt.Sprintf("%d", photoID)) {
+			GetLogger(ctx).Error("failed to send delete-photo message to channel.", zap.String("service", "AsyncUpload()"))
 		}
 		return
 	}
 
 	// if success to upload, send callback to redis to update url for the photo
-	photoURL := fmt.Sprintf(constant.AzStorageBlobURLEndpointFormat, azStorageAccountName, azStorageContainerName) + "/" + fileName
+	photoURL := PhotoStorage.URL(fileName)
 	updateURLMessage := fmt.Sprintf("%d-%s", photoID, photoURL)
-	if !SendToChannel(constant.PhotoURLUpdateChannel, updateURLMessage) {
-		AppLogger.Info("failed to send update-photo-url message to channel.", zap.String("service", "AsyncUpload()"))
+	if !SendToChannel(ctx, constant.PhotoURLUpdateChannel, updateURLMessage) {
+		GetLogger(ctx).Error("failed to send update-photo-url message to channel.", zap.String("service", "AsyncUpload()"))
+		return
 	}
+	GetLogger(ctx).Info("photo uploaded.", zap.String("service", "AsyncUpload()"))
 	return
 }
@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	_ "image/gif"  // register gif decoder
+	_ "image/jpeg" // register jpeg decoder
+	_ "image/png"  // register png decoder
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	_ "golang.org/x/image/tiff" // register tiff decoder
+	_ "golang.org/x/image/webp" // register webp decoder
+
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/conf"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+)
+
+var ErrImageTypeNotAllowed = apperr.New(constant.PhotoTypeNotAllowed, "image type is not allowed")
+var ErrImageCorrupt = apperr.New(constant.PhotoFileCorrupt, "image is corrupt")
+var ErrImageTooLarge = apperr.New(constant.PhotoFileTooLarge, "image is too large")
+
+// decodableImageTypes are the image types which can be decoded to check they are not corrupt
+var decodableImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+	"image/tiff": true,
+}
+
+// tiffRawTypes are the raw formats built on a plain tiff header, told apart by their extension
+var tiffRawTypes = map[string]string{
+	".dng": "image/x-adobe-dng",
+	".nef": "image/x-nikon-nef",
+	".nrw": "image/x-nikon-nrw",
+	".arw": "image/x-sony-arw",
+	".srf": "image/x-sony-srf",
+	".sr2": "image/x-sony-sr2",
+	".pef": "image/x-pentax-pef",
+	".3fr": "image/x-hasselblad-3fr",
+	".erf": "image/x-epson-erf",
+	".mos": "image/x-leaf-mos",
+	".srw": "image/x-samsung-srw",
+}
+
+// heifBrands maps the ftyp brands of the iso media formats to their image types
+var heifBrands = map[string]string{
+	"heic": "image/heic",
+	"heix": "image/heic",
+	"heim": "image/heic",
+	"heis": "image/heic",
+	"hevc": "image/heic-sequence",
+	"hevx": "image/heic-sequence",
+	"mif1": "image/heif",
+	"msf1": "image/heif-sequence",
+	"avif": "image/avif",
+	"crx ": "image/x-canon-cr3",
+}
+
+// DetectImageType func detect the image type by the magic bytes of the file header,
+// the file name is only used to tell apart the raw formats which share the tiff header.
+func DetectImageType(header []byte, fileName string) string {
+	switch {
+	case bytes.HasPrefix(header, []byte{0xFF, 0xD8, 0xFF}):
+		return "image/jpeg"
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		return "image/png"
+	case bytes.HasPrefix(header, []byte("GIF87a")), bytes.HasPrefix(header, []byte("GIF89a")):
+		return "image/gif"
+	case len(header) >= 12 && bytes.Equal(header[0:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WEBP")):
+		return "image/webp"
+	case len(header) >= 12 && bytes.Equal(header[4:8], []byte("ftyp")):
+		return heifBrands[string(header[8:12])]
+	case bytes.HasPrefix(header, []byte("FUJIFILMCCD-RAW")):
+		return "image/x-fuji-raf"
+	case bytes.HasPrefix(header, []byte("IIRO")), bytes.HasPrefix(header, []byte("IIRS")), bytes.HasPrefix(header, []byte("MMOR")):
+		return "image/x-olympus-orf"
+	case bytes.HasPrefix(header, []byte{'I', 'I', 'U', 0}):
+		return "image/x-panasonic-rw2"
+	case bytes.HasPrefix(header, []byte{'I', 'I', '*', 0}), bytes.HasPrefix(header, []byte{'M', 'M', 0, '*'}):
+		if len(header) >= 10 && bytes.Equal(header[8:10], []byte("CR")) {
+			return "image/x-canon-cr2"
+		}
+		if rawType, ok := tiffRawTypes[strings.ToLower(filepath.Ext(fileName))]; ok {
+			return rawType
+		}
+		return "image/tiff"
+	}
+	return ""
+}
+
+// ValidateImage func check the size, the type and the content of an image file,
+// the detected image type is returned and the file is rewound to its start.
+func ValidateImage(file *os.File, fileName string) (string, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	if stat.Size() > MaxImageSize() {
+		return "", ErrImageTooLarge
+	}
+
+	header := make([]byte, constant.UploadSniffSize)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", ErrImageCorrupt
+	}
+	imageType := DetectImageType(header[:n], fileName)
+	if !IsImageTypeAllowed(imageType) {
+		return "", ErrImageTypeNotAllowed
+	}
+
+	// decode the whole image if it is possible, raw and heif files are only checked by their header
+	if decodableImageTypes[imageType] {
+		if err := decodeImage(file); err != nil {
+			AppLogger.Debug(err.Error(), zap.String("service", "ValidateImage()"))
+			return "", ErrImageCorrupt
+		}
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return imageType, nil
+}
+
+// CanDecodeImage func check if an image type can be decoded on the server
+func CanDecodeImage(imageType string) bool {
+	return decodableImageTypes[imageType]
+}
+
+// IsImageTypeAllowed func check if an image type is in the configured allowlist
+func IsImageTypeAllowed(imageType string) bool {
+	if imageType == "" {
+		return false
+	}
+	allowed := conf.ServerCfg.GetDefault(constant.UploadAllowedTypes, "")
+	if allowed == "" {
+		return true
+	}
+	for _, allowedType := range strings.Split(allowed, ",") {
+		if strings.TrimSpace(allowedType) == imageType {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxImageSize func get the configured max size of an image file in bytes
+func MaxImageSize() int64 {
+	maxSize, err := strconv.ParseInt(conf.ServerCfg.GetDefault(constant.UploadMaxFileSize, constant.DefaultUploadMaxFileSize), 10, 64)
+	if err != nil || maxSize <= 0 {
+		maxSize, _ = strconv.ParseInt(constant.DefaultUploadMaxFileSize, 10, 64)
+	}
+	return maxSize
+}
+
+// decodeImage func decode the image to make sure it is not corrupt,
+// the dimensions are checked first so a small file cannot claim a huge image.
+func decodeImage(file *os.File) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return err
+	}
+
+	maxPixels, _ := strconv.ParseInt(conf.ServerCfg.GetDefault(constant.UploadMaxPixels, constant.DefaultUploadMaxPixels), 10, 64)
+	if maxPixels > 0 && int64(config.Width)*int64(config.Height) > maxPixels {
+		return ErrImageTooLarge
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, _, err = image.Decode(file)
+	return err
+}
@@ -0,0 +1,44 @@
+package utils
+
+import "testing"
+
+func TestDetectImageType(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   []byte
+		fileName string
+		want     string
+	}{
+		{name: "jpeg", header: []byte{0xFF, 0xD8, 0xFF, 0xE0}, fileName: "a.jpg", want: "image/jpeg"},
+		{name: "png", header: []byte("\x89PNG\r\n\x1a\n\x00"), fileName: "a.png", want: "image/png"},
+		{name: "gif87a", header: []byte("GIF87a"), fileName: "a.gif", want: "image/gif"},
+		{name: "gif89a", header: []byte("GIF89a"), fileName: "a.gif", want: "image/gif"},
+		{name: "webp", header: []byte("RIFF\x00\x00\x00\x00WEBPVP8 "), fileName: "a.webp", want: "image/webp"},
+		{name: "riff but not webp", header: []byte("RIFF\x00\x00\x00\x00WAVEfmt "), fileName: "a.wav", want: ""},
+		{name: "heic", header: []byte("\x00\x00\x00\x18ftypheic"), fileName: "a.heic", want: "image/heic"},
+		{name: "heif", header: []byte("\x00\x00\x00\x18ftypmif1"), fileName: "a.heif", want: "image/heif"},
+		{name: "avif", header: []byte("\x00\x00\x00\x1cftypavif"), fileName: "a.avif", want: "image/avif"},
+		{name: "cr3", header: []byte("\x00\x00\x00\x18ftypcrx "), fileName: "a.cr3", want: "image/x-canon-cr3"},
+		{name: "mp4 is not an image", header: []byte("\x00\x00\x00\x18ftypisom"), fileName: "a.mp4", want: ""},
+		{name: "raf", header: []byte("FUJIFILMCCD-RAW 0201"), fileName: "a.raf", want: "image/x-fuji-raf"},
+		{name: "orf", header: []byte("IIRO\x08\x00\x00\x00"), fileName: "a.orf", want: "image/x-olympus-orf"},
+		{name: "rw2", header: []byte{'I', 'I', 'U', 0, 0x08, 0, 0, 0}, fileName: "a.rw2", want: "image/x-panasonic-rw2"},
+		{name: "cr2", header: []byte{'I', 'I', '*', 0, 0x10, 0, 0, 0, 'C', 'R'}, fileName: "a.cr2", want: "image/x-canon-cr2"},
+		{name: "dng by extension", header: []byte{'I', 'I', '*', 0, 0x08, 0, 0, 0}, fileName: "a.DNG", want: "image/x-adobe-dng"},
+		{name: "nef big endian", header: []byte{'M', 'M', 0, '*', 0, 0, 0, 0x08}, fileName: "a.nef", want: "image/x-nikon-nef"},
+		{name: "tiff", header: []byte{'I', 'I', '*', 0, 0x08, 0, 0, 0}, fileName: "a.tif", want: "image/tiff"},
+		{name: "tiff with a jpeg name", header: []byte{'M', 'M', 0, '*', 0, 0, 0, 0x08}, fileName: "a.jpg", want: "image/tiff"},
+		{name: "png with a jpeg name", header: []byte("\x89PNG\r\n\x1a\n\x00"), fileName: "a.jpg", want: "image/png"},
+		{name: "text", header: []byte("hello world!"), fileName: "a.jpg", want: ""},
+		{name: "short", header: []byte{0xFF, 0xD8}, fileName: "a.jpg", want: ""},
+		{name: "empty", header: nil, fileName: "a.jpg", want: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := DetectImageType(test.header, test.fileName); got != test.want {
+				t.Errorf("DetectImageType() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
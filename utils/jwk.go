@@ -0,0 +1,22 @@
+package utils
+
+// JWK struct is a json web key as in RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+
+	// rsa public keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// okp public keys
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS struct is a json web key set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
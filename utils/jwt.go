@@ -5,6 +5,7 @@ import (
 	"github.com/walk1ng/gin-photo-gallery-storage/constant"
 	"go.uber.org/zap"
 
+	"context"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
@@ -13,34 +14,60 @@ import (
 // UserClaim struct
 type UserClaim struct {
 	UserName string `json:"userName"`
+	Role     string `json:"role"`
 	jwt.StandardClaims
 }
 
-// GenerateJWT func to gen a JWT string based on the user name
-func GenerateJWT(userName string) (string, error) {
+// GenerateJWT func to gen a JWT string based on the user name and role,
+// it is signed by the current signing key and names the key in its header
+func GenerateJWT(ctx context.Context, userName, role string) (string, error) {
 	// define a user claim
 	claim := UserClaim{
 		userName,
+		role,
 		jwt.StandardClaims{
 			Issuer:    constant.PhotoStorageAdmin,
 			ExpiresAt: time.Now().Add(constant.JwtExpMinute * time.Minute).Unix(),
 		},
 	}
 
+	key, err := currentSigningKey(ctx)
+	if err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "GenerateJWT()"))
+		return "", err
+	}
+
 	// generate the claim and the digital signature
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claim)
-	jwtString, err := token.SignedString([]byte(conf.ServerCfg.Get(constant.JwtSecret)))
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Alg), claim)
+	token.Header["kid"] = key.Kid
+	jwtString, err := token.SignedString(key.private)
 	if err != nil {
-		AppLogger.Fatal(err.Error(), zap.String("service", "GenerateJWT()"))
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "GenerateJWT()"))
 		return "", err
 	}
 	return jwtString, nil
 }
 
-// ParseJWT func to parse a JWT into a user claim
-func ParseJWT(jwtString string) (*UserClaim, error) {
+// ParseJWT func to parse a JWT into a user claim, the key is found by the key id in its header.
+// The jwt signed by the secret before the keys existed are only accepted when it is allowed.
+func ParseJWT(ctx context.Context, jwtString string) (*UserClaim, error) {
 	token, err := jwt.ParseWithClaims(jwtString, &UserClaim{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(conf.ServerCfg.Get(constant.JwtSecret)), nil
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			if token.Method != jwt.SigningMethodHS256 || conf.ServerCfg.GetDefault(constant.JwtAcceptSecret, "false") != "true" {
+				return nil, ErrNoSigningKey
+			}
+			return []byte(conf.ServerCfg.Get(constant.JwtSecret)), nil
+		}
+
+		key, err := verificationKey(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != key.Alg {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return key.private.Public(), nil
 	})
 
 	if token != nil && err == nil {
@@ -0,0 +1,298 @@
+package utils
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/conf"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+)
+
+// signingKey struct is a key pair to sign the jwt, the keys are kept in redis
+// so all the servers sign and verify with the same keys.
+type signingKey struct {
+	Kid        string `json:"kid"`
+	Alg        string `json:"alg"`
+	CreatedAt  int64  `json:"created_at"`
+	PrivateKey string `json:"private_key"` // pkcs8 in pem
+
+	private crypto.Signer
+}
+
+var ErrNoSigningKey = apperr.New(constant.JwtParseError, "no such jwt signing key")
+
+var signingKeys = struct {
+	sync.RWMutex
+	keys     []*signingKey // the newest first, it is the one to sign with
+	loadedAt time.Time
+	forcedAt time.Time // when the keys were last loaded for an unknown key id
+}{}
+var rotateOnce sync.Once
+
+// currentSigningKey func get the newest key to sign the jwt, the first key is generated when there is none.
+// A server losing the race to generate it waits for the winner to save it.
+func currentSigningKey(ctx context.Context) (*signingKey, error) {
+	keys, err := getSigningKeys(ctx, false)
+	for retry := 0; err == nil && len(keys) == 0 && retry < constant.JwtKeyWaitRetries; retry++ {
+		if retry > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(constant.JwtKeyWaitMillis * time.Millisecond):
+			}
+		}
+		if err = RotateSigningKeys(ctx); err == nil {
+			keys, err = getSigningKeys(ctx, true)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrNoSigningKey
+	}
+	return keys[0], nil
+}
+
+// verificationKey func get the public key of a key id, the keys are loaded again
+// when the id is unknown as another server may have rotated them.
+func verificationKey(ctx context.Context, kid string) (*signingKey, error) {
+	keys, err := getSigningKeys(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	if key := findSigningKey(keys, kid); key != nil {
+		return key, nil
+	}
+	if !allowForcedReload() {
+		return nil, ErrNoSigningKey
+	}
+
+	keys, err = getSigningKeys(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	if key := findSigningKey(keys, kid); key != nil {
+		return key, nil
+	}
+	return nil, ErrNoSigningKey
+}
+
+// findSigningKey func find the key of a key id
+func findSigningKey(keys []*signingKey, kid string) *signingKey {
+	for _, key := range keys {
+		if key.Kid == kid {
+			return key
+		}
+	}
+	return nil
+}
+
+// allowForcedReload func check if the keys can be loaded again for an unknown key id,
+// it is allowed once per interval so jwt with made-up key ids cannot flood redis.
+func allowForcedReload() bool {
+	signingKeys.Lock()
+	defer signingKeys.Unlock()
+	if time.Since(signingKeys.forcedAt) < constant.JwtKeyReloadSeconds*time.Second {
+		return false
+	}
+	signingKeys.forcedAt = time.Now()
+	return true
+}
+
+// GetJWKS func get the public keys which can verify the jwt
+func GetJWKS(ctx context.Context) (*JWKS, error) {
+	keys, err := getSigningKeys(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	jwks := JWKS{Keys: make([]JWK, 0, len(keys))}
+	for _, key := range keys {
+		jwk := JWK{Kid: key.Kid, Use: "sig", Alg: key.Alg}
+		switch public := key.private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return &jwks, nil
+}
+
+// RotateSigningKeys func generate a new signing key when the newest one is older than the rotation interval
+// or of another algorithm, and delete the old keys which can no longer have valid jwt signed by them.
+func RotateSigningKeys(ctx context.Context) error {
+	// only one server rotates at a time
+	locked, err := RedisWithContext(ctx).SetNX(constant.JwtKeysLock, 1, constant.JwtKeysLockMaxAge*time.Second).Result()
+	if err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "RotateSigningKeys()"))
+		return err
+	}
+	if !locked {
+		return nil
+	}
+	defer RedisWithContext(ctx).Del(constant.JwtKeysLock)
+
+	keys, err := loadSigningKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	alg := conf.ServerCfg.GetDefault(constant.JwtAlg, constant.DefaultJwtAlg)
+	rotationHours, _ := strconv.Atoi(conf.ServerCfg.GetDefault(constant.JwtKeyRotationHours, constant.DefaultJwtKeyRotationHours))
+	if len(keys) == 0 || keys[0].Alg != alg || (rotationHours > 0 && now-keys[0].CreatedAt >= int64(rotationHours)*3600) {
+		key, err := newSigningKey(alg)
+		if err != nil {
+			GetLogger(ctx).Error(err.Error(), zap.String("service", "RotateSigningKeys()"))
+			return err
+		}
+		value, _ := json.Marshal(key)
+		if err := RedisWithContext(ctx).HSet(constant.JwtKeys, key.Kid, value).Err(); err != nil {
+			GetLogger(ctx).Error(err.Error(), zap.String("service", "RotateSigningKeys()"))
+			return err
+		}
+		GetLogger(ctx).Info("jwt signing key rotated.", zap.String("service", "RotateSigningKeys()"),
+			zap.String("kid", key.Kid), zap.String("alg", key.Alg))
+		keys = append([]*signingKey{key}, keys...)
+	}
+
+	// a key is kept until the last jwt signed before the next key was added expires
+	for i := 1; i < len(keys); i++ {
+		if now > keys[i-1].CreatedAt+constant.JwtExpMinute*60+constant.JwtKeyGraceSeconds {
+			if err := RedisWithContext(ctx).HDel(constant.JwtKeys, keys[i].Kid).Err(); err != nil {
+				GetLogger(ctx).Error(err.Error(), zap.String("service", "RotateSigningKeys()"))
+			}
+		}
+	}
+
+	_, err = getSigningKeys(ctx, true)
+	return err
+}
+
+// rotateSigningKeysPeriodically func check if the signing keys need to be rotated forever
+func rotateSigningKeysPeriodically() {
+	ticker := time.NewTicker(constant.JwtKeyCheckMinutes * time.Minute)
+	for range ticker.C {
+		if err := RotateSigningKeys(context.Background()); err != nil {
+			AppLogger.Error(err.Error(), zap.String("service", "rotateSigningKeysPeriodically()"))
+		}
+	}
+}
+
+// getSigningKeys func get the signing keys from the memory, they are loaded from redis
+// when asked to or when they are older than the check interval.
+func getSigningKeys(ctx context.Context, reload bool) ([]*signingKey, error) {
+	rotateOnce.Do(func() {
+		go rotateSigningKeysPeriodically()
+	})
+
+	signingKeys.RLock()
+	keys, loadedAt := signingKeys.keys, signingKeys.loadedAt
+	signingKeys.RUnlock()
+	if !reload && time.Since(loadedAt) < constant.JwtKeyCheckMinutes*time.Minute {
+		return keys, nil
+	}
+
+	keys, err := loadSigningKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	signingKeys.Lock()
+	signingKeys.keys, signingKeys.loadedAt = keys, time.Now()
+	signingKeys.Unlock()
+	return keys, nil
+}
+
+// loadSigningKeys func load the signing keys from redis, the newest first
+func loadSigningKeys(ctx context.Context) ([]*signingKey, error) {
+	values, err := RedisWithContext(ctx).HGetAll(constant.JwtKeys).Result()
+	if err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "loadSigningKeys()"))
+		return nil, err
+	}
+
+	keys := make([]*signingKey, 0, len(values))
+	for kid, value := range values {
+		key := signingKey{}
+		if err := json.Unmarshal([]byte(value), &key); err != nil {
+			GetLogger(ctx).Warn(err.Error(), zap.String("service", "loadSigningKeys()"), zap.String("kid", kid))
+			continue
+		}
+		block, _ := pem.Decode([]byte(key.PrivateKey))
+		if block == nil {
+			continue
+		}
+		private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			GetLogger(ctx).Warn(err.Error(), zap.String("service", "loadSigningKeys()"), zap.String("kid", kid))
+			continue
+		}
+		if key.private, _ = private.(crypto.Signer); key.private == nil {
+			continue
+		}
+		keys = append(keys, &key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt > keys[j].CreatedAt
+	})
+	return keys, nil
+}
+
+// newSigningKey func generate a signing key of the algorithm
+func newSigningKey(alg string) (*signingKey, error) {
+	var private crypto.Signer
+	var err error
+	switch alg {
+	case jwt.SigningMethodRS256.Alg():
+		private, err = rsa.GenerateKey(rand.Reader, constant.JwtRSAKeyBits)
+	case SigningMethodEdDSA.Alg():
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, errors.New("unsupported jwt algorithm " + alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	kid, err := randomHex(8)
+	if err != nil {
+		return nil, err
+	}
+	return &signingKey{
+		Kid:        kid,
+		Alg:        alg,
+		CreatedAt:  time.Now().Unix(),
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		private:    private,
+	}, nil
+}
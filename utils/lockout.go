@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/conf"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+)
+
+// GetLoginLock func get how long the logins of the user or from the ip are still locked, 0 means not locked
+func GetLoginLock(ctx context.Context, username, ip string) time.Duration {
+	var lock time.Duration
+	for _, key := range []string{
+		fmt.Sprintf(constant.LoginLockFormat, "USER", username),
+		fmt.Sprintf(constant.LoginLockFormat, "IP", ip),
+	} {
+		ttl, err := RedisWithContext(ctx).TTL(key).Result()
+		if err != nil {
+			GetLogger(ctx).Error(err.Error(), zap.String("service", "GetLoginLock()"))
+			continue
+		}
+		if ttl > lock {
+			lock = ttl
+		}
+	}
+	return lock
+}
+
+// AddLoginFailure func count a failed login of the user from the ip, the user or the ip is locked
+// once its failures reach the max attempts and every further failure doubles the lock.
+func AddLoginFailure(ctx context.Context, username, ip string) time.Duration {
+	userLock := addFailure(ctx, "USER", username, configInt(constant.LoginMaxAttempts, constant.DefaultLoginMaxAttempts))
+	ipLock := addFailure(ctx, "IP", ip, configInt(constant.LoginIPMaxAttempts, constant.DefaultLoginIPMaxAttempts))
+	if ipLock > userLock {
+		return ipLock
+	}
+	return userLock
+}
+
+// ResetLoginFailures func forget the failed logins and the lock of the user,
+// the failures of the ips are kept so one valid account cannot clear them.
+func ResetLoginFailures(ctx context.Context, username string) bool {
+	err := RedisWithContext(ctx).Del(
+		fmt.Sprintf(constant.LoginFailureFormat, "USER", username),
+		fmt.Sprintf(constant.LoginLockFormat, "USER", username),
+	).Err()
+	if err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "ResetLoginFailures()"))
+		return false
+	}
+	return true
+}
+
+// UnlockLoginIP func forget the failed logins and the lock of an ip
+func UnlockLoginIP(ctx context.Context, ip string) bool {
+	err := RedisWithContext(ctx).Del(
+		fmt.Sprintf(constant.LoginFailureFormat, "IP", ip),
+		fmt.Sprintf(constant.LoginLockFormat, "IP", ip),
+	).Err()
+	if err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "UnlockLoginIP()"))
+		return false
+	}
+	return true
+}
+
+// addFailure func count a failure of a login subject and lock it when the failures reach the max attempts
+func addFailure(ctx context.Context, kind, subject string, maxAttempts int) time.Duration {
+	failureKey := fmt.Sprintf(constant.LoginFailureFormat, kind, subject)
+	pipe := RedisWithContext(ctx).TxPipeline()
+	incr := pipe.Incr(failureKey)
+	pipe.Expire(failureKey, time.Duration(configInt(constant.LoginFailureWindow, constant.DefaultLoginFailureWindow))*time.Second)
+	if _, err := pipe.Exec(); err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "addFailure()"))
+		return 0
+	}
+
+	failures := int(incr.Val())
+	if maxAttempts <= 0 || failures < maxAttempts {
+		return 0
+	}
+
+	lock := time.Duration(configInt(constant.LoginLockSeconds, constant.DefaultLoginLockSeconds)) * time.Second
+	maxLock := time.Duration(configInt(constant.LoginMaxLockSeconds, constant.DefaultLoginMaxLockSeconds)) * time.Second
+	for i := maxAttempts; i < failures && lock < maxLock; i++ {
+		lock *= 2
+	}
+	if lock > maxLock {
+		lock = maxLock
+	}
+
+	err := RedisWithContext(ctx).Set(fmt.Sprintf(constant.LoginLockFormat, kind, subject), failures, lock).Err()
+	if err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "addFailure()"))
+		return 0
+	}
+	GetLogger(ctx).Warn("login locked.", zap.String("service", "addFailure()"),
+		zap.String(kind, subject), zap.Int("failures", failures), zap.Duration("lock", lock))
+	return lock
+}
+
+// configInt func get an int config or its default
+func configInt(key, defaultVal string) int {
+	value, err := strconv.Atoi(conf.ServerCfg.GetDefault(key, defaultVal))
+	if err != nil {
+		value, _ = strconv.Atoi(defaultVal)
+	}
+	return value
+}
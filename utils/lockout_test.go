@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+)
+
+func TestAddFailure(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAttempts int
+		failures    int
+		wantLock    time.Duration // the lock after the last failure
+	}{
+		{name: "below the max attempts", maxAttempts: 3, failures: 2, wantLock: 0},
+		{name: "reach the max attempts", maxAttempts: 3, failures: 3, wantLock: time.Minute},
+		{name: "one more failure doubles the lock", maxAttempts: 3, failures: 4, wantLock: 2 * time.Minute},
+		{name: "two more failures double it again", maxAttempts: 3, failures: 5, wantLock: 4 * time.Minute},
+		{name: "the lock is capped", maxAttempts: 3, failures: 6, wantLock: 5 * time.Minute},
+		{name: "the lock stays capped", maxAttempts: 3, failures: 20, wantLock: 5 * time.Minute},
+		{name: "single attempt", maxAttempts: 1, failures: 1, wantLock: time.Minute},
+		{name: "no max attempts never locks", maxAttempts: 0, failures: 10, wantLock: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setConfig(t, constant.LoginLockSeconds, "60")
+			setConfig(t, constant.LoginMaxLockSeconds, "300")
+			testRedis.FlushAll()
+
+			var lock time.Duration
+			for i := 0; i < test.failures; i++ {
+				lock = addFailure(context.Background(), "USER", "alice", test.maxAttempts)
+			}
+			if lock != test.wantLock {
+				t.Errorf("addFailure() = %v, want %v", lock, test.wantLock)
+			}
+
+			lockKey := fmt.Sprintf(constant.LoginLockFormat, "USER", "alice")
+			if ttl := testRedis.TTL(lockKey); ttl != test.wantLock {
+				t.Errorf("ttl of the lock = %v, want %v", ttl, test.wantLock)
+			}
+			if got := GetLoginLock(context.Background(), "alice", "127.0.0.1"); got != test.wantLock {
+				t.Errorf("GetLoginLock() = %v, want %v", got, test.wantLock)
+			}
+		})
+	}
+}
+
+func TestAddLoginFailure(t *testing.T) {
+	setConfig(t, constant.LoginMaxAttempts, "3")
+	setConfig(t, constant.LoginIPMaxAttempts, "5")
+	setConfig(t, constant.LoginLockSeconds, "60")
+	setConfig(t, constant.LoginMaxLockSeconds, "3600")
+	testRedis.FlushAll()
+
+	// the users fail from the same ip, the ip is locked once all its failures reach its max attempts
+	steps := []struct {
+		username string
+		wantLock time.Duration
+	}{
+		{username: "alice", wantLock: 0},
+		{username: "alice", wantLock: 0},
+		{username: "alice", wantLock: time.Minute},
+		{username: "bob", wantLock: 0},
+		{username: "bob", wantLock: time.Minute},
+		{username: "carol", wantLock: 2 * time.Minute},
+	}
+	for i, step := range steps {
+		if lock := AddLoginFailure(context.Background(), step.username, "10.0.0.1"); lock != step.wantLock {
+			t.Errorf("step %d: AddLoginFailure(%q) = %v, want %v", i, step.username, lock, step.wantLock)
+		}
+	}
+
+	// resetting a user keeps the failures of the ip
+	ResetLoginFailures(context.Background(), "alice")
+	if lock := GetLoginLock(context.Background(), "alice", "10.0.0.2"); lock != 0 {
+		t.Errorf("GetLoginLock() after the reset = %v, want 0", lock)
+	}
+	if lock := GetLoginLock(context.Background(), "alice", "10.0.0.1"); lock != 2*time.Minute {
+		t.Errorf("GetLoginLock() of the ip = %v, want %v", lock, 2*time.Minute)
+	}
+}
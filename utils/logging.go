@@ -1,20 +1,31 @@
 package utils
 
 import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"gopkg.in/natefinch/lumberjack.v2"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/conf"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
 )
 
 var AppLogger *zap.Logger
 
+// logLevel is the level of the app logger, it can be changed at runtime
+var logLevel = zap.NewAtomicLevelAt(zap.InfoLevel)
+
+// loggerKey is the key of the request logger in a context
+type loggerKey struct{}
+
 func init() {
-	writer := zapcore.AddSync(&lumberjack.Logger{
-		Filename:   "logs/app.log",
-		MaxSize:    100,
-		MaxBackups: 3,
-		MaxAge:     1,
-	})
+	if err := logLevel.UnmarshalText([]byte(conf.ServerCfg.GetDefault(constant.LogLevel, "info"))); err != nil {
+		logLevel.SetLevel(zap.InfoLevel)
+	}
 
 	encoderConfig := zapcore.EncoderConfig{
 		TimeKey:        "time",
@@ -29,13 +40,84 @@ func init() {
 		EncodeDuration: zapcore.SecondsDurationEncoder,
 		EncodeCaller:   zapcore.ShortCallerEncoder,
 	}
-
-	core := zapcore.NewCore(
-		zapcore.NewJSONEncoder(encoderConfig),
-		writer,
-		zap.InfoLevel,
-	)
+	var encoder zapcore.Encoder
+	if conf.ServerCfg.GetDefault(constant.LogEncoding, "json") == "console" {
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	} else {
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	}
 
 	caller := zap.AddCaller()
-	AppLogger = zap.New(core, caller)
-}
\ No newline at end of file
+	AppLogger = zap.New(zapcore.NewCore(encoder, logWriter(), logLevel), caller)
+}
+
+// logWriter func get the configured log output, stdout, file or both
+func logWriter() zapcore.WriteSyncer {
+	fileWriter := zapcore.AddSync(&lumberjack.Logger{
+		Filename:   conf.ServerCfg.GetDefault(constant.LogFile, "logs/app.log"),
+		MaxSize:    configInt(constant.LogMaxSizeMB, "100"),
+		MaxBackups: configInt(constant.LogMaxBackups, "3"),
+		MaxAge:     configInt(constant.LogMaxAgeDays, "1"),
+		Compress:   conf.ServerCfg.GetDefault(constant.LogCompress, "false") == "true",
+	})
+	stdoutWriter := zapcore.Lock(os.Stdout)
+
+	switch conf.ServerCfg.GetDefault(constant.LogOutput, "file") {
+	case "stdout":
+		return stdoutWriter
+	case "both":
+		return zapcore.NewMultiWriteSyncer(fileWriter, stdoutWriter)
+	default:
+		return fileWriter
+	}
+}
+
+// GetLogLevel func get the current level of the app logger
+func GetLogLevel() string {
+	return logLevel.Level().String()
+}
+
+// SetLogLevel func change the level of the app logger and every logger derived from it
+func SetLogLevel(level string) error {
+	return logLevel.UnmarshalText([]byte(level))
+}
+
+// LogWriter struct is an io.Writer writing each line to a logger,
+// it routes the output of libraries writing plain text into the app log.
+type LogWriter struct {
+	Logger  *zap.Logger
+	Level   zapcore.Level
+	Service string
+}
+
+// Write func write each line of the text as a log entry
+func (w *LogWriter) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(bytes.TrimRight(p, "\n"), []byte("\n")) {
+		if message := strings.TrimSpace(string(line)); message != "" {
+			if entry := w.Logger.Check(w.Level, message); entry != nil {
+				entry.Write(zap.String("service", w.Service))
+			}
+		}
+	}
+	return len(p), nil
+}
+
+// WithLogger func return a copy of the context carrying the logger
+func WithLogger(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// WithLogFields func return a copy of the context whose logger carries the extra fields
+func WithLogFields(ctx context.Context, fields ...zap.Field) context.Context {
+	return WithLogger(ctx, GetLogger(ctx).With(fields...))
+}
+
+// GetLogger func get the logger of a context, the app logger is used out of a request
+func GetLogger(ctx context.Context) *zap.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(loggerKey{}).(*zap.Logger); ok {
+			return logger
+		}
+	}
+	return AppLogger
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/conf"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+)
+
+// Mailer interface sends the emails to the users
+type Mailer interface {
+	// Send sends a plain text email
+	Send(to, subject, body string) error
+}
+
+// AppMailer is the global mailer chosen by the mail driver
+var AppMailer Mailer
+
+func init() {
+	from := conf.ServerCfg.GetDefault(constant.MailFrom, "")
+	switch conf.ServerCfg.GetDefault(constant.MailDriver, constant.MailDriverFile) {
+	case constant.MailDriverSMTP:
+		AppMailer = &smtpMailer{
+			addr:     net.JoinHostPort(conf.ServerCfg.Get(constant.SMTPHost), conf.ServerCfg.GetDefault(constant.SMTPPort, "587")),
+			host:     conf.ServerCfg.Get(constant.SMTPHost),
+			user:     conf.ServerCfg.GetDefault(constant.SMTPUser, ""),
+			password: conf.ServerCfg.GetDefault(constant.SMTPPassword, ""),
+			from:     from,
+		}
+	default:
+		AppMailer = &fileMailer{
+			dir:  conf.ServerCfg.GetDefault(constant.MailDir, constant.DefaultMailDir),
+			from: from,
+		}
+	}
+}
+
+// smtpMailer struct sends the emails by an smtp server
+type smtpMailer struct {
+	addr     string
+	host     string
+	user     string
+	password string
+	from     string
+}
+
+// Send func send an email by the smtp server, the auth is skipped when no user is configured
+func (m *smtpMailer) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.user != "" {
+		auth = smtp.PlainAuth("", m.user, m.password, m.host)
+	}
+	err := smtp.SendMail(m.addr, auth, m.from, []string{to}, buildMail(m.from, to, subject, body))
+	if err != nil {
+		AppLogger.Error(err.Error(), zap.String("service", "smtpMailer.Send()"))
+	}
+	return err
+}
+
+// fileMailer struct writes the emails to files for local testing
+type fileMailer struct {
+	dir  string
+	from string
+}
+
+// Send func write an email to a file of the mail directory and log where it is
+func (m *fileMailer) Send(to, subject, body string) error {
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		AppLogger.Error(err.Error(), zap.String("service", "fileMailer.Send()"))
+		return err
+	}
+
+	fileName := filepath.Join(m.dir, fmt.Sprintf("%d_%s.eml", time.Now().UnixNano(), strings.Replace(to, "/", "_", -1)))
+	if err := ioutil.WriteFile(fileName, buildMail(m.from, to, subject, body), 0644); err != nil {
+		AppLogger.Error(err.Error(), zap.String("service", "fileMailer.Send()"))
+		return err
+	}
+	AppLogger.Info("mail written.", zap.String("service", "fileMailer.Send()"),
+		zap.String("to", to), zap.String("file", fileName))
+	return nil
+}
+
+// buildMail func build the raw message of a plain text email, line breaks are dropped from the headers
+func buildMail(from, to, subject, body string) []byte {
+	headers := strings.NewReplacer("\r", "", "\n", "")
+	from, to, subject = headers.Replace(from), headers.Replace(to), headers.Replace(subject)
+	return []byte(fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		from, to, subject, body))
+}
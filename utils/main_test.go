@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"log"
+	"os"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+	"go.uber.org/zap"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/conf"
+)
+
+// testRedis is the in-memory redis the tests run against
+var testRedis *miniredis.Miniredis
+
+func TestMain(m *testing.M) {
+	var err error
+	if testRedis, err = miniredis.Run(); err != nil {
+		log.Fatalln(err)
+	}
+	RedisClient = redis.NewClient(&redis.Options{Addr: testRedis.Addr()})
+	AppLogger = zap.NewNop()
+
+	code := m.Run()
+	testRedis.Close()
+	os.Exit(code)
+}
+
+// setConfig func set a config term for a test, the config is restored when the test ends
+func setConfig(t *testing.T, key, value string) {
+	oldValue, ok := conf.ServerCfg.ConfigMap[key]
+	conf.ServerCfg.ConfigMap[key] = value
+	t.Cleanup(func() {
+		if ok {
+			conf.ServerCfg.ConfigMap[key] = oldValue
+		} else {
+			delete(conf.ServerCfg.ConfigMap, key)
+		}
+	})
+}
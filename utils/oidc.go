@@ -0,0 +1,388 @@
+package utils
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/go-redis/redis"
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/conf"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+)
+
+// OIDCProvider struct is an openid connect provider configured by OIDC_<NAME>_* terms,
+// its endpoints and keys are discovered from the issuer on first use.
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	mutex                 sync.Mutex
+	authorizationEndpoint string
+	tokenEndpoint         string
+	jwksURI               string
+	keys                  map[string]*rsa.PublicKey
+}
+
+// OIDCClaims struct is the claims of an id token used to log in
+type OIDCClaims struct {
+	Issuer            string       `json:"iss"`
+	Subject           string       `json:"sub"`
+	Audience          oidcAudience `json:"aud"`
+	ExpiresAt         int64        `json:"exp"`
+	Nonce             string       `json:"nonce"`
+	Email             string       `json:"email"`
+	EmailVerified     bool         `json:"email_verified"`
+	PreferredUsername string       `json:"preferred_username"`
+}
+
+// OIDCLogin struct is a pending login kept in redis between the redirect and the callback
+type OIDCLogin struct {
+	Provider string
+	Verifier string
+	Nonce    string
+}
+
+var ErrNoSuchOIDCProvider = apperr.New(constant.OIDCProviderNotExist, "no such oidc provider")
+var ErrOIDCLoginInvalid = apperr.New(constant.OIDCLoginError, "oidc login is invalid or expired")
+var ErrOIDCTokenInvalid = apperr.New(constant.OIDCLoginError, "oidc id token is invalid")
+
+var oidcProviders = make(map[string]*OIDCProvider)
+var oidcMutex sync.Mutex
+var oidcClient = &http.Client{Timeout: constant.OIDCTimeout * time.Second}
+
+// GetOIDCProviderNames func get the names of the configured oidc providers
+func GetOIDCProviderNames() []string {
+	names := make([]string, 0)
+	for _, name := range strings.Split(conf.ServerCfg.GetDefault(constant.OIDCProviders, ""), ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// GetOIDCProvider func get a configured oidc provider by its name
+func GetOIDCProvider(name string) (*OIDCProvider, error) {
+	oidcMutex.Lock()
+	defer oidcMutex.Unlock()
+
+	if provider, ok := oidcProviders[name]; ok {
+		return provider, nil
+	}
+	for _, configured := range GetOIDCProviderNames() {
+		if configured != name {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		provider := &OIDCProvider{
+			Name:         name,
+			Issuer:       strings.TrimSuffix(conf.ServerCfg.GetDefault(prefix+"ISSUER", ""), "/"),
+			ClientID:     conf.ServerCfg.GetDefault(prefix+"CLIENT_ID", ""),
+			ClientSecret: conf.ServerCfg.GetDefault(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  conf.ServerCfg.GetDefault(prefix+"REDIRECT_URL", ""),
+			Scopes:       strings.Fields(conf.ServerCfg.GetDefault(prefix+"SCOPES", "openid email profile")),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" || provider.RedirectURL == "" {
+			AppLogger.Warn("oidc provider is not fully configured.", zap.String("service", "GetOIDCProvider()"), zap.String("provider", name))
+			return nil, ErrNoSuchOIDCProvider
+		}
+		oidcProviders[name] = provider
+		return provider, nil
+	}
+	return nil, ErrNoSuchOIDCProvider
+}
+
+// StartOIDCLogin func start a login with the provider, the pkce verifier and the nonce are kept in redis
+// under the state, the url of the authorization endpoint to redirect to is returned with the state.
+func (provider *OIDCProvider) StartOIDCLogin(ctx context.Context) (string, string, error) {
+	if err := provider.discover(); err != nil {
+		return "", "", err
+	}
+
+	state, err := randomHex(16)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := randomHex(32)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomHex(16)
+	if err != nil {
+		return "", "", err
+	}
+
+	key := fmt.Sprintf(constant.OIDCStateFormat, state)
+	pipe := RedisWithContext(ctx).TxPipeline()
+	pipe.HMSet(key, map[string]interface{}{
+		"provider": provider.Name,
+		"verifier": verifier,
+		"nonce":    nonce,
+	})
+	pipe.Expire(key, constant.OIDCStateMaxAge*time.Second)
+	if _, err := pipe.Exec(); err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "StartOIDCLogin()"))
+		return "", "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", provider.ClientID)
+	query.Set("redirect_uri", provider.RedirectURL)
+	query.Set("scope", strings.Join(provider.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(provider.authorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return provider.authorizationEndpoint + separator + query.Encode(), state, nil
+}
+
+// PopOIDCLogin func get the pending login of a state and forget it, a state is used only once
+func PopOIDCLogin(ctx context.Context, state string) (*OIDCLogin, error) {
+	key := fmt.Sprintf(constant.OIDCStateFormat, state)
+	pipe := RedisWithContext(ctx).TxPipeline()
+	get := pipe.HGetAll(key)
+	pipe.Del(key)
+	if _, err := pipe.Exec(); err != nil && err != redis.Nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "PopOIDCLogin()"))
+		return nil, err
+	}
+
+	fields := get.Val()
+	if len(fields) == 0 {
+		return nil, ErrOIDCLoginInvalid
+	}
+	return &OIDCLogin{
+		Provider: fields["provider"],
+		Verifier: fields["verifier"],
+		Nonce:    fields["nonce"],
+	}, nil
+}
+
+// MarkOIDCReauth func remember the user just logged in by an oidc provider, for a short while
+// it confirms the account changes of a user who has neither a password nor totp
+func MarkOIDCReauth(ctx context.Context, username string) error {
+	key := fmt.Sprintf(constant.OIDCReauthFormat, username)
+	if err := RedisWithContext(ctx).Set(key, 1, constant.OIDCReauthMaxAge*time.Second).Err(); err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "MarkOIDCReauth()"))
+		return err
+	}
+	return nil
+}
+
+// UseOIDCReauth func use up the recent oidc login of the user, false means there was none
+func UseOIDCReauth(ctx context.Context, username string) bool {
+	deleted, err := RedisWithContext(ctx).Del(fmt.Sprintf(constant.OIDCReauthFormat, username)).Result()
+	if err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "UseOIDCReauth()"))
+		return false
+	}
+	return deleted > 0
+}
+
+// FinishOIDCLogin func exchange the authorization code for the id token and verify it
+func (provider *OIDCProvider) FinishOIDCLogin(login *OIDCLogin, code string) (*OIDCClaims, error) {
+	if err := provider.discover(); err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", provider.RedirectURL)
+	form.Set("client_id", provider.ClientID)
+	form.Set("code_verifier", login.Verifier)
+	request, err := http.NewRequest(http.MethodPost, provider.tokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	if provider.ClientSecret != "" {
+		request.SetBasicAuth(url.QueryEscape(provider.ClientID), url.QueryEscape(provider.ClientSecret))
+	}
+
+	tokens := struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}{}
+	if err := doOIDCRequest(request, &tokens); err != nil {
+		AppLogger.Warn(err.Error(), zap.String("service", "FinishOIDCLogin()"), zap.String("error", tokens.Error))
+		return nil, err
+	}
+	if tokens.IDToken == "" {
+		return nil, ErrOIDCTokenInvalid
+	}
+	return provider.verifyIDToken(tokens.IDToken, login.Nonce)
+}
+
+// verifyIDToken func check the signature, issuer, audience, expiry and nonce of an id token
+func (provider *OIDCProvider) verifyIDToken(idToken, nonce string) (*OIDCClaims, error) {
+	claims := OIDCClaims{}
+	_, err := jwt.ParseWithClaims(idToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, ErrOIDCTokenInvalid
+		}
+		kid, _ := token.Header["kid"].(string)
+		return provider.getKey(kid)
+	})
+	if err != nil {
+		AppLogger.Warn(err.Error(), zap.String("service", "verifyIDToken()"))
+		return nil, ErrOIDCTokenInvalid
+	}
+
+	if claims.Issuer != provider.Issuer || !claims.Audience.contains(provider.ClientID) ||
+		claims.Nonce != nonce || claims.Subject == "" {
+		return nil, ErrOIDCTokenInvalid
+	}
+	return &claims, nil
+}
+
+// Valid func check the id token is not expired, it is called by the jwt parser
+func (claims *OIDCClaims) Valid() error {
+	if claims.ExpiresAt == 0 || time.Now().Unix() > claims.ExpiresAt+constant.OIDCClockSkew {
+		return ErrOIDCTokenInvalid
+	}
+	return nil
+}
+
+// discover func get the endpoints of the provider from its openid configuration
+func (provider *OIDCProvider) discover() error {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	if provider.tokenEndpoint != "" {
+		return nil
+	}
+
+	request, err := http.NewRequest(http.MethodGet, provider.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return err
+	}
+	configuration := struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}{}
+	if err := doOIDCRequest(request, &configuration); err != nil {
+		AppLogger.Error(err.Error(), zap.String("service", "discover()"), zap.String("provider", provider.Name))
+		return err
+	}
+	if strings.TrimSuffix(configuration.Issuer, "/") != provider.Issuer {
+		return fmt.Errorf("oidc: issuer %s does not match %s", configuration.Issuer, provider.Issuer)
+	}
+
+	provider.authorizationEndpoint = configuration.AuthorizationEndpoint
+	provider.tokenEndpoint = configuration.TokenEndpoint
+	provider.jwksURI = configuration.JWKSURI
+	return nil
+}
+
+// getKey func get a signing key of the provider by its id, the keys are fetched again
+// when the id is unknown as the provider may have rotated them.
+func (provider *OIDCProvider) getKey(kid string) (*rsa.PublicKey, error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	if key, ok := provider.keys[kid]; ok {
+		return key, nil
+	}
+
+	request, err := http.NewRequest(http.MethodGet, provider.jwksURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	jwks := JWKS{}
+	if err := doOIDCRequest(request, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		n, nErr := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, eErr := base64.RawURLEncoding.DecodeString(jwk.E)
+		if nErr != nil || eErr != nil {
+			continue
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	provider.keys = keys
+
+	key, ok := keys[kid]
+	if !ok {
+		return nil, ErrOIDCTokenInvalid
+	}
+	return key, nil
+}
+
+// doOIDCRequest func send a request to the provider and decode its json response
+func doOIDCRequest(request *http.Request, result interface{}) error {
+	response, err := oidcClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	decodeErr := json.NewDecoder(response.Body).Decode(result)
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: %s responded %s", request.URL.Path, response.Status)
+	}
+	return decodeErr
+}
+
+// oidcAudience is the aud claim which is either a string or an array of strings
+type oidcAudience []string
+
+// UnmarshalJSON func decode the aud claim of both forms
+func (audience *oidcAudience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*audience = oidcAudience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*audience = multiple
+	return nil
+}
+
+// contains func check the audience includes the client
+func (audience oidcAudience) contains(clientID string) bool {
+	for _, aud := range audience {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/go-redis/redis"
+	"github.com/walk1ng/gin-photo-gallery-storage/conf"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+)
+
+// RateLimit struct is a token bucket of capacity tokens refilled over period
+type RateLimit struct {
+	Capacity int
+	Period   time.Duration
+}
+
+// RateLimitResult struct is the state of a token bucket after taking a token
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next token, 0 when allowed
+}
+
+// tokenBucketScript refills a bucket by the time passed since its last use and takes a token,
+// it runs in redis so the servers share the buckets.
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(bucket[1]) or capacity
+local ts = tonumber(bucket[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(capacity / rate) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// GetRateLimit func get a configured rate limit in the form <requests>/<seconds>,
+// false means the limit is not configured or disabled.
+func GetRateLimit(name string) (RateLimit, bool) {
+	value := conf.ServerCfg.GetDefault(fmt.Sprintf(constant.RateLimitFormat, name), "")
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 {
+		return RateLimit{}, false
+	}
+	capacity, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	seconds, secondsErr := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil || secondsErr != nil || capacity <= 0 || seconds <= 0 {
+		return RateLimit{}, false
+	}
+	return RateLimit{Capacity: capacity, Period: time.Duration(seconds) * time.Second}, true
+}
+
+// TakeRateLimitToken func take a token from the bucket of a subject of a limit
+func TakeRateLimitToken(ctx context.Context, name, subject string, limit RateLimit) (*RateLimitResult, error) {
+	// tokens per millisecond
+	rate := float64(limit.Capacity) / float64(limit.Period/time.Millisecond)
+	key := fmt.Sprintf(constant.RateLimitKeyFormat, name, subject)
+	values, err := tokenBucketScript.Run(RedisWithContext(ctx), []string{key},
+		limit.Capacity, strconv.FormatFloat(rate, 'f', -1, 64), time.Now().UnixNano()/int64(time.Millisecond)).Result()
+	if err != nil {
+		AppLogger.Error(err.Error(), zap.String("service", "TakeRateLimitToken()"))
+		return nil, err
+	}
+
+	result := values.([]interface{})
+	allowed, _ := result[0].(int64)
+	tokens, _ := strconv.ParseFloat(result[1].(string), 64)
+	limitResult := RateLimitResult{
+		Allowed:   allowed == 1,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration((float64(limit.Capacity) - tokens) / rate * float64(time.Millisecond)),
+	}
+	if !limitResult.Allowed {
+		limitResult.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Millisecond))
+	}
+	return &limitResult, nil
+}
+
+// GetTrustedProxies func get the configured proxies allowed to set the client ip,
+// nil means no proxy is trusted and the client ip is always the remote address.
+func GetTrustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(conf.ServerCfg.GetDefault(constant.TrustedProxies, ""), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+)
+
+func TestGetRateLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		want   RateLimit
+		wantOK bool
+	}{
+		{name: "requests per seconds", value: "30/60", want: RateLimit{Capacity: 30, Period: time.Minute}, wantOK: true},
+		{name: "spaces", value: " 10 / 1 ", want: RateLimit{Capacity: 10, Period: time.Second}, wantOK: true},
+		{name: "not configured", value: ""},
+		{name: "no period", value: "30"},
+		{name: "zero requests", value: "0/60"},
+		{name: "zero seconds", value: "30/0"},
+		{name: "negative requests", value: "-1/60"},
+		{name: "not a number", value: "many/60"},
+		{name: "too many parts", value: "30/60/2"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setConfig(t, fmt.Sprintf(constant.RateLimitFormat, "TEST"), test.value)
+			got, ok := GetRateLimit("TEST")
+			if ok != test.wantOK || got != test.want {
+				t.Errorf("GetRateLimit() = %+v, %v, want %+v, %v", got, ok, test.want, test.wantOK)
+			}
+		})
+	}
+}
+
+func TestTakeRateLimitToken(t *testing.T) {
+	limit := RateLimit{Capacity: 3, Period: time.Minute}
+	interval := limit.Period / time.Duration(limit.Capacity) // to refill one token
+
+	tests := []struct {
+		name       string
+		tokens     float64 // the tokens left in the bucket, less than 0 for a new bucket
+		idle       time.Duration
+		wantOK     bool
+		wantRemain int
+		wantReset  time.Duration
+		wantRetry  time.Duration
+	}{
+		{name: "new bucket", tokens: -1, wantOK: true, wantRemain: 2, wantReset: interval},
+		{name: "last token", tokens: 1, wantOK: true, wantRemain: 0, wantReset: limit.Period},
+		{name: "empty", tokens: 0, wantOK: false, wantRemain: 0, wantReset: limit.Period, wantRetry: interval},
+		{name: "half a token", tokens: 0.5, wantOK: false, wantRemain: 0, wantReset: limit.Period - interval/2, wantRetry: interval / 2},
+		{name: "refilled by the idle time", tokens: 0, idle: interval, wantOK: true, wantRemain: 0, wantReset: limit.Period},
+		{name: "refilled up to the capacity", tokens: 0, idle: time.Hour, wantOK: true, wantRemain: 2, wantReset: interval},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testRedis.FlushAll()
+			if test.tokens >= 0 {
+				ts := time.Now().Add(-test.idle).UnixNano() / int64(time.Millisecond)
+				testRedis.HSet(fmt.Sprintf(constant.RateLimitKeyFormat, "TEST", "subject"),
+					"tokens", strconv.FormatFloat(test.tokens, 'f', -1, 64), "ts", strconv.FormatInt(ts, 10))
+			}
+
+			result, err := TakeRateLimitToken(context.Background(), "TEST", "subject", limit)
+			if err != nil {
+				t.Fatalf("TakeRateLimitToken() error = %v", err)
+			}
+			if result.Allowed != test.wantOK || result.Remaining != test.wantRemain {
+				t.Errorf("TakeRateLimitToken() allowed, remaining = %v, %d, want %v, %d",
+					result.Allowed, result.Remaining, test.wantOK, test.wantRemain)
+			}
+			// the bucket keeps refilling while the test runs
+			if result.Reset > test.wantReset || result.Reset < test.wantReset-time.Second {
+				t.Errorf("TakeRateLimitToken() reset = %v, want %v", result.Reset, test.wantReset)
+			}
+			if result.RetryAfter > test.wantRetry || result.RetryAfter < test.wantRetry-time.Second {
+				t.Errorf("TakeRateLimitToken() retry after = %v, want %v", result.RetryAfter, test.wantRetry)
+			}
+		})
+	}
+}
+
+func TestTakeRateLimitTokenBySubject(t *testing.T) {
+	testRedis.FlushAll()
+	limit := RateLimit{Capacity: 2, Period: time.Minute}
+
+	steps := []struct {
+		subject string
+		wantOK  bool
+	}{
+		{subject: "a", wantOK: true},
+		{subject: "a", wantOK: true},
+		{subject: "a", wantOK: false},
+		{subject: "b", wantOK: true},
+		{subject: "a", wantOK: false},
+	}
+	for i, step := range steps {
+		result, err := TakeRateLimitToken(context.Background(), "TEST", step.subject, limit)
+		if err != nil {
+			t.Fatalf("step %d: TakeRateLimitToken() error = %v", i, err)
+		}
+		if result.Allowed != step.wantOK {
+			t.Errorf("step %d: TakeRateLimitToken(%q) allowed = %v, want %v", i, step.subject, result.Allowed, step.wantOK)
+		}
+	}
+}
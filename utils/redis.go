@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"time"
@@ -10,6 +12,9 @@ import (
 	"github.com/go-redis/redis"
 	"github.com/walk1ng/gin-photo-gallery-storage/conf"
 	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var RedisClient *redis.Client
@@ -29,51 +34,90 @@ func init() {
 }
 
 // AddAuthToRedis func add an auth to redis mean the user has logged in
-func AddAuthToRedis(username string) error {
+func AddAuthToRedis(ctx context.Context, username string) error {
 	key := fmt.Sprintf("%s%s", constant.LoginUser, username)
-	err := RedisClient.Set(key, username, constant.LoginMaxAge*time.Second).Err()
+	err := RedisWithContext(ctx).Set(key, username, constant.LoginMaxAge*time.Second).Err()
 	if err != nil {
-		AppLogger.Info(err.Error(), zap.String("service", "AddAuthToRedis()"))
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "AddAuthToRedis()"))
 		return err
 	}
 	return nil
 }
 
 // IsAuthInRedis func check if an auth exists in redis
-func IsAuthInRedis(username string) bool {
+func IsAuthInRedis(ctx context.Context, username string) bool {
 	key := fmt.Sprintf("%s%s", constant.LoginUser, username)
-	err := RedisClient.Get(key).Err()
+	err := RedisWithContext(ctx).Get(key).Err()
 	if err != nil {
-		AppLogger.Info(err.Error(), zap.String("service", "IsAuthInRedis()"))
+		GetLogger(ctx).Debug(err.Error(), zap.String("service", "IsAuthInRedis()"))
 		return false
 	}
 	return true
 }
 
 // RemoveAuthFromRedis func remove the auth from redis
-func RemoveAuthFromRedis(username string) bool {
+func RemoveAuthFromRedis(ctx context.Context, username string) bool {
 	key := fmt.Sprintf("%s%s", constant.LoginUser, username)
-	err := RedisClient.Del(key).Err()
+	err := RedisWithContext(ctx).Del(key).Err()
 	if err != nil {
-		AppLogger.Info(err.Error(), zap.String("service", "RemoveAuthFromRedis()"))
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "RemoveAuthFromRedis()"))
 		return false
 	}
 	return true
 }
 
+// AddUserToken func create a single-use token of the user, only its hash is kept
+func AddUserToken(ctx context.Context, format, username string, maxAge int) (string, error) {
+	token, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	key := fmt.Sprintf(format, hashToken(token))
+	if err := RedisWithContext(ctx).Set(key, username, time.Duration(maxAge)*time.Second).Err(); err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "AddUserToken()"))
+		return "", err
+	}
+	return token, nil
+}
+
+// GetUserToken func get the user of a token without using it up,
+// an empty user name means the token is invalid or expired
+func GetUserToken(ctx context.Context, format, token string) (string, error) {
+	username, err := RedisWithContext(ctx).Get(fmt.Sprintf(format, hashToken(token))).Result()
+	if err != nil && err != redis.Nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "GetUserToken()"))
+		return "", err
+	}
+	return username, nil
+}
+
+// PopUserToken func get the user of a token and forget the token,
+// an empty user name means the token is invalid or expired
+func PopUserToken(ctx context.Context, format, token string) (string, error) {
+	key := fmt.Sprintf(format, hashToken(token))
+	pipe := RedisWithContext(ctx).TxPipeline()
+	get := pipe.Get(key)
+	pipe.Del(key)
+	if _, err := pipe.Exec(); err != nil && err != redis.Nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "PopUserToken()"))
+		return "", err
+	}
+	return get.Val(), nil
+}
+
 // SetUploadStatus func set the upload status for a photo
-func SetUploadStatus(key string, value int) bool {
-	err := RedisClient.Set(key, value, 0).Err()
+func SetUploadStatus(ctx context.Context, key string, value int) bool {
+	err := RedisWithContext(ctx).Set(key, value, constant.UploadStatusMaxAge*time.Second).Err()
 	if err != nil {
-		AppLogger.Info(err.Error(), zap.String("service", "SetUploadStatus()"))
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "SetUploadStatus()"))
 		return false
 	}
 	return true
 }
 
 // GetPhotoUploadStatus func get the upload status for a photo
-func GetUploadStatus(key string) int {
-	val := RedisClient.Get(key).Val()
+func GetUploadStatus(ctx context.Context, key string) int {
+	val := RedisWithContext(ctx).Get(key).Val()
 	if val == "" {
 		return -2 // no such key
 	}
@@ -81,11 +125,134 @@ func GetUploadStatus(key string) int {
 	return status
 }
 
-// SendToChannel func send a message to a channel
-func SendToChannel(channel, message string) bool {
-	err := RedisClient.Publish(channel, message).Err()
+// channelMessage struct is a message sent to a channel with the trace context of its sender
+type channelMessage struct {
+	Trace   propagation.MapCarrier `json:"trace"`
+	Payload string                 `json:"payload"`
+}
+
+// SendToChannel func send a message to a channel, the receiver continues the trace of the sender
+func SendToChannel(ctx context.Context, channel, message string) bool {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	value, _ := json.Marshal(channelMessage{Trace: carrier, Payload: message})
+
+	err := RedisWithContext(ctx).Publish(channel, string(value)).Err()
 	if err != nil {
-		AppLogger.Info(err.Error(), zap.String("service", "SendToChannel()"))
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "SendToChannel()"))
+		return false
+	}
+	return true
+}
+
+// ReceiveFromChannel func get the message sent to a channel and the trace context of its sender,
+// a message sent without one is taken as it is.
+func ReceiveFromChannel(message *redis.Message) (context.Context, string) {
+	received := channelMessage{}
+	if err := json.Unmarshal([]byte(message.Payload), &received); err != nil {
+		return context.Background(), message.Payload
+	}
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), received.Trace)
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		ctx = WithLogFields(ctx, zap.String("trace_id", spanContext.TraceID().String()))
+	}
+	return ctx, received.Payload
+}
+
+// SetBatchItem func record the state of a file in a batch upload
+func SetBatchItem(ctx context.Context, batchID, name, value string) bool {
+	key := fmt.Sprintf(constant.BatchIDFormat, batchID)
+	pipe := RedisWithContext(ctx).TxPipeline()
+	pipe.HSet(key, name, value)
+	pipe.Expire(key, constant.BatchMaxAge*time.Second)
+	if _, err := pipe.Exec(); err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "SetBatchItem()"))
+		return false
+	}
+	return true
+}
+
+// GetBatchItems func get the states of all files in a batch upload by their names
+func GetBatchItems(ctx context.Context, batchID string) (map[string]string, error) {
+	key := fmt.Sprintf(constant.BatchIDFormat, batchID)
+	items, err := RedisWithContext(ctx).HGetAll(key).Result()
+	if err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "GetBatchItems()"))
+		return nil, err
+	}
+	return items, nil
+}
+
+// AddPhotoVariant func record a transformed variant generated from a photo blob
+func AddPhotoVariant(ctx context.Context, blobName, variantBlobName string) bool {
+	key := fmt.Sprintf(constant.PhotoVariantsFormat, blobName)
+	if err := RedisWithContext(ctx).SAdd(key, variantBlobName).Err(); err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "AddPhotoVariant()"))
+		return false
+	}
+	return true
+}
+
+// PopPhotoVariants func get and forget the transformed variants generated from a photo blob
+func PopPhotoVariants(ctx context.Context, blobName string) ([]string, error) {
+	key := fmt.Sprintf(constant.PhotoVariantsFormat, blobName)
+	pipe := RedisWithContext(ctx).TxPipeline()
+	members := pipe.SMembers(key)
+	pipe.Del(key)
+	if _, err := pipe.Exec(); err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "PopPhotoVariants()"))
+		return nil, err
+	}
+	return members.Val(), nil
+}
+
+// SetExportStatus func save the state of a bucket export, the expiry of the export is also
+// recorded so its blob can be deleted when the state expires.
+func SetExportStatus(ctx context.Context, exportID string, fields map[string]interface{}) bool {
+	key := fmt.Sprintf(constant.ExportIDFormat, exportID)
+	expiry := time.Now().Add(constant.ExportMaxAge * time.Second)
+	pipe := RedisWithContext(ctx).TxPipeline()
+	pipe.HMSet(key, fields)
+	pipe.Expire(key, constant.ExportMaxAge*time.Second)
+	pipe.ZAdd(constant.ExportExpiryKey, redis.Z{Score: float64(expiry.Unix()), Member: exportID})
+	if _, err := pipe.Exec(); err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "SetExportStatus()"))
+		return false
+	}
+	return true
+}
+
+// GetExportStatus func get the state of a bucket export
+func GetExportStatus(ctx context.Context, exportID string) (map[string]string, error) {
+	key := fmt.Sprintf(constant.ExportIDFormat, exportID)
+	fields, err := RedisWithContext(ctx).HGetAll(key).Result()
+	if err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "GetExportStatus()"))
+		return nil, err
+	}
+	return fields, nil
+}
+
+// GetExportIDs func get the ids of the exports expiring before the time
+func GetExportIDs(ctx context.Context, before time.Time) ([]string, error) {
+	exportIDs, err := RedisWithContext(ctx).ZRangeByScore(constant.ExportExpiryKey, redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(before.Unix(), 10),
+	}).Result()
+	if err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "GetExportIDs()"))
+		return nil, err
+	}
+	return exportIDs, nil
+}
+
+// RemoveExport func forget an export and its state
+func RemoveExport(ctx context.Context, exportID string) bool {
+	pipe := RedisWithContext(ctx).TxPipeline()
+	pipe.Del(fmt.Sprintf(constant.ExportIDFormat, exportID))
+	pipe.ZRem(constant.ExportExpiryKey, exportID)
+	if _, err := pipe.Exec(); err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "RemoveExport()"))
 		return false
 	}
 	return true
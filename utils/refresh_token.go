@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+)
+
+var ErrRefreshTokenInvalid = apperr.New(constant.RefreshTokenError, "refresh token is invalid or expired")
+var ErrRefreshTokenReused = apperr.New(constant.RefreshTokenReused, "refresh token is reused")
+
+// IssueRefreshToken func issue a refresh token of a login, a login keeps its family through the rotations
+// and an empty family starts a new login.
+func IssueRefreshToken(ctx context.Context, userName, family string) (string, error) {
+	if family == "" {
+		var err error
+		if family, err = randomHex(16); err != nil {
+			return "", err
+		}
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	token := family + "." + secret
+
+	tokenKey := fmt.Sprintf(constant.RefreshTokenFormat, hashToken(token))
+	familyKey := fmt.Sprintf(constant.RefreshFamilyFormat, family)
+	userKey := fmt.Sprintf(constant.RefreshUserFormat, userName)
+	pipe := RedisWithContext(ctx).TxPipeline()
+	pipe.HMSet(tokenKey, map[string]interface{}{
+		"user_name": userName,
+		"family":    family,
+		"used":      0,
+	})
+	pipe.Expire(tokenKey, constant.RefreshTokenMaxAge*time.Second)
+	pipe.Set(familyKey, userName, constant.RefreshTokenMaxAge*time.Second)
+	pipe.SAdd(userKey, family)
+	pipe.Expire(userKey, constant.RefreshTokenMaxAge*time.Second)
+	if _, err := pipe.Exec(); err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "IssueRefreshToken()"))
+		return "", err
+	}
+	return token, nil
+}
+
+// RotateRefreshToken func use a refresh token once and issue the next one of its family,
+// a token used twice means it was stolen so the whole family is revoked.
+func RotateRefreshToken(ctx context.Context, token string) (string, string, error) {
+	tokenKey := fmt.Sprintf(constant.RefreshTokenFormat, hashToken(token))
+	fields, err := RedisWithContext(ctx).HGetAll(tokenKey).Result()
+	if err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "RotateRefreshToken()"))
+		return "", "", err
+	}
+	if len(fields) == 0 {
+		return "", "", ErrRefreshTokenInvalid
+	}
+	userName, family := fields["user_name"], fields["family"]
+
+	// the used tokens are kept until they expire to detect the reuse
+	used, err := RedisWithContext(ctx).HIncrBy(tokenKey, "used", 1).Result()
+	if err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "RotateRefreshToken()"))
+		return "", "", err
+	}
+	if used > 1 {
+		RevokeRefreshFamily(ctx, family)
+		return userName, "", ErrRefreshTokenReused
+	}
+
+	if err := RedisWithContext(ctx).Get(fmt.Sprintf(constant.RefreshFamilyFormat, family)).Err(); err != nil {
+		return "", "", ErrRefreshTokenInvalid
+	}
+
+	// the login is ended when the user is signed out by an admin
+	if !IsAuthInRedis(ctx, userName) {
+		RevokeRefreshFamily(ctx, family)
+		return "", "", ErrRefreshTokenInvalid
+	}
+	next, err := IssueRefreshToken(ctx, userName, family)
+	if err != nil {
+		return "", "", err
+	}
+	return userName, next, nil
+}
+
+// RevokeRefreshFamily func revoke all refresh tokens of a login
+func RevokeRefreshFamily(ctx context.Context, family string) bool {
+	err := RedisWithContext(ctx).Del(fmt.Sprintf(constant.RefreshFamilyFormat, family)).Err()
+	if err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "RevokeRefreshFamily()"))
+		return false
+	}
+	return true
+}
+
+// RevokeRefreshTokens func revoke the refresh tokens of all logins of the user
+func RevokeRefreshTokens(ctx context.Context, userName string) bool {
+	userKey := fmt.Sprintf(constant.RefreshUserFormat, userName)
+	families, err := RedisWithContext(ctx).SMembers(userKey).Result()
+	if err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "RevokeRefreshTokens()"))
+		return false
+	}
+
+	keys := []string{userKey}
+	for _, family := range families {
+		keys = append(keys, fmt.Sprintf(constant.RefreshFamilyFormat, family))
+	}
+	if err := RedisWithContext(ctx).Del(keys...).Err(); err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "RevokeRefreshTokens()"))
+		return false
+	}
+	return true
+}
+
+// hashToken func hash a token so the tokens themselves are not stored in redis
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// randomHex func get n random bytes in hex
+func randomHex(n int) (string, error) {
+	buffer := make([]byte, n)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buffer), nil
+}
@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// BlobProperties struct describe a stored photo blob
+type BlobProperties struct {
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+}
+
+// Storage interface is the blob storage used to keep photo files
+type Storage interface {
+	// Upload uploads a local file as the blob with the given name and content type
+	Upload(ctx context.Context, blobName string, contentType string, file *os.File) error
+	// StageBlock stages the index-th block of a blob which is not visible until committed
+	StageBlock(ctx context.Context, blobName string, index int, data io.ReadSeeker) error
+	// CommitBlocks commits the staged blocks 0 to count-1 as the content of the blob
+	CommitBlocks(ctx context.Context, blobName string, contentType string, count int) error
+	// Download opens a reader on count bytes of the blob from offset, count 0 means to the end
+	Download(ctx context.Context, blobName string, offset, count int64) (io.ReadCloser, error)
+	// Properties gets the properties of the blob
+	Properties(ctx context.Context, blobName string) (BlobProperties, error)
+	// Delete deletes the blob
+	Delete(ctx context.Context, blobName string) error
+	// URL returns the public url of the blob
+	URL(blobName string) string
+}
+
+// PhotoStorage is the global storage for photo blobs
+var PhotoStorage Storage
+
+var errInvalidSeek = errors.New("storage: invalid seek")
+
+// BlobReader struct is a lazy io.ReadSeeker over a stored blob,
+// every seek drops the current stream and the next read opens a ranged download.
+type BlobReader struct {
+	ctx      context.Context
+	storage  Storage
+	blobName string
+	size     int64
+	offset   int64
+	body     io.ReadCloser
+}
+
+// NewBlobReader func create a BlobReader for a blob of the given size
+func NewBlobReader(ctx context.Context, storage Storage, blobName string, size int64) *BlobReader {
+	return &BlobReader{
+		ctx:      ctx,
+		storage:  storage,
+		blobName: blobName,
+		size:     size,
+	}
+}
+
+// Read func read from the current offset of the blob
+func (r *BlobReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		body, err := r.storage.Download(r.ctx, r.blobName, r.offset, 0)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+	}
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+// Seek func move the offset of the blob reader
+func (r *BlobReader) Seek(offset int64, whence int) (int64, error) {
+	var target int64
+	switch whence {
+	case io.SeekStart:
+		target = offset
+	case io.SeekCurrent:
+		target = r.offset + offset
+	case io.SeekEnd:
+		target = r.size + offset
+	default:
+		return r.offset, errInvalidSeek
+	}
+	if target < 0 {
+		return r.offset, errInvalidSeek
+	}
+	if target != r.offset {
+		r.Close()
+		r.offset = target
+	}
+	return r.offset, nil
+}
+
+// Close func close the underlying download stream
+func (r *BlobReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
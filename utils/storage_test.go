@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// memoryStorage struct is a Storage keeping the blobs in memory, it counts the downloads
+type memoryStorage struct {
+	blobs     map[string][]byte
+	downloads int
+}
+
+func (s *memoryStorage) Upload(ctx context.Context, blobName string, contentType string, file *os.File) error {
+	return nil
+}
+
+func (s *memoryStorage) StageBlock(ctx context.Context, blobName string, index int, data io.ReadSeeker) error {
+	return nil
+}
+
+func (s *memoryStorage) CommitBlocks(ctx context.Context, blobName string, contentType string, count int) error {
+	return nil
+}
+
+func (s *memoryStorage) Download(ctx context.Context, blobName string, offset, count int64) (io.ReadCloser, error) {
+	s.downloads++
+	blob := s.blobs[blobName][offset:]
+	if count > 0 && count < int64(len(blob)) {
+		blob = blob[:count]
+	}
+	return ioutil.NopCloser(bytes.NewReader(blob)), nil
+}
+
+func (s *memoryStorage) Properties(ctx context.Context, blobName string) (BlobProperties, error) {
+	return BlobProperties{Size: int64(len(s.blobs[blobName]))}, nil
+}
+
+func (s *memoryStorage) Delete(ctx context.Context, blobName string) error {
+	return nil
+}
+
+func (s *memoryStorage) URL(blobName string) string {
+	return blobName
+}
+
+func TestBlobReaderSeek(t *testing.T) {
+	blob := []byte("0123456789")
+	tests := []struct {
+		name      string
+		start     int64 // the offset read from before seeking
+		offset    int64
+		whence    int
+		want      int64
+		wantErr   bool
+		wantRead  string
+		downloads int // downloads after the seek and the read
+	}{
+		{name: "start", start: 2, offset: 5, whence: io.SeekStart, want: 5, wantRead: "567", downloads: 2},
+		{name: "current", start: 2, offset: 6, whence: io.SeekCurrent, want: 8, wantRead: "89", downloads: 2},
+		{name: "end", start: 2, offset: -4, whence: io.SeekEnd, want: 6, wantRead: "678", downloads: 2},
+		{name: "same offset keeps the stream", start: 3, offset: 0, whence: io.SeekCurrent, want: 3, wantRead: "345", downloads: 1},
+		{name: "past the end", start: 2, offset: 20, whence: io.SeekStart, want: 20, wantRead: "", downloads: 1},
+		{name: "negative", start: 2, offset: -1, whence: io.SeekStart, want: 2, wantErr: true, wantRead: "234", downloads: 1},
+		{name: "invalid whence", start: 2, offset: 1, whence: 3, want: 2, wantErr: true, wantRead: "234", downloads: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			storage := &memoryStorage{blobs: map[string][]byte{"blob": blob}}
+			reader := NewBlobReader(context.Background(), storage, "blob", int64(len(blob)))
+			defer reader.Close()
+
+			// open the stream at the start offset
+			reader.offset = test.start
+			if _, err := reader.Read(make([]byte, 0)); err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+
+			got, err := reader.Seek(test.offset, test.whence)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Seek() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("Seek() = %d, want %d", got, test.want)
+			}
+
+			read := make([]byte, 3)
+			n, _ := io.ReadFull(reader, read)
+			if string(read[:n]) != test.wantRead {
+				t.Errorf("Read() = %q, want %q", read[:n], test.wantRead)
+			}
+			if storage.downloads != test.downloads {
+				t.Errorf("downloads = %d, want %d", storage.downloads, test.downloads)
+			}
+		})
+	}
+}
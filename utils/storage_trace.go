@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedStorage struct wraps a Storage with a span for each operation
+type tracedStorage struct {
+	storage Storage
+}
+
+// start func start the span of a storage operation on a blob
+func (s *tracedStorage) start(ctx context.Context, operation, blobName string) (context.Context, trace.Span) {
+	return Tracer.Start(ctx, "storage "+operation, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("storage.operation", operation), attribute.String("storage.blob", blobName)))
+}
+
+// Upload func upload a local file in a span
+func (s *tracedStorage) Upload(ctx context.Context, blobName string, contentType string, file *os.File) (err error) {
+	ctx, span := s.start(ctx, "Upload", blobName)
+	defer func() { EndSpan(span, err) }()
+	return s.storage.Upload(ctx, blobName, contentType, file)
+}
+
+// StageBlock func stage a block in a span
+func (s *tracedStorage) StageBlock(ctx context.Context, blobName string, index int, data io.ReadSeeker) (err error) {
+	ctx, span := s.start(ctx, "StageBlock", blobName)
+	span.SetAttributes(attribute.Int("storage.block", index))
+	defer func() { EndSpan(span, err) }()
+	return s.storage.StageBlock(ctx, blobName, index, data)
+}
+
+// CommitBlocks func commit the staged blocks in a span
+func (s *tracedStorage) CommitBlocks(ctx context.Context, blobName string, contentType string, count int) (err error) {
+	ctx, span := s.start(ctx, "CommitBlocks", blobName)
+	span.SetAttributes(attribute.Int("storage.blocks", count))
+	defer func() { EndSpan(span, err) }()
+	return s.storage.CommitBlocks(ctx, blobName, contentType, count)
+}
+
+// Download func open a download stream in a span, the span does not cover the reading
+func (s *tracedStorage) Download(ctx context.Context, blobName string, offset, count int64) (body io.ReadCloser, err error) {
+	ctx, span := s.start(ctx, "Download", blobName)
+	defer func() { EndSpan(span, err) }()
+	return s.storage.Download(ctx, blobName, offset, count)
+}
+
+// Properties func get the properties of a blob in a span
+func (s *tracedStorage) Properties(ctx context.Context, blobName string) (properties BlobProperties, err error) {
+	ctx, span := s.start(ctx, "Properties", blobName)
+	defer func() { EndSpan(span, err) }()
+	return s.storage.Properties(ctx, blobName)
+}
+
+// Delete func delete a blob in a span
+func (s *tracedStorage) Delete(ctx context.Context, blobName string) (err error) {
+	ctx, span := s.start(ctx, "Delete", blobName)
+	defer func() { EndSpan(span, err) }()
+	return s.storage.Delete(ctx, blobName)
+}
+
+// URL func get the url of a blob
+func (s *tracedStorage) URL(blobName string) string {
+	return s.storage.URL(blobName)
+}
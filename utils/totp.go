@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+)
+
+// totpEncoding is the base32 encoding of the totp secrets used by the authenticator apps
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTOTPSecret func generate a random totp secret in base32
+func NewTOTPSecret() (string, error) {
+	secret := make([]byte, constant.TOTPSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+// TOTPURI func get the provisioning uri of a totp secret, it is the content of the qr code to scan
+func TOTPURI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(constant.TOTPDigits))
+	query.Set("period", fmt.Sprint(constant.TOTPPeriod))
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// CheckTOTP func check a totp code at the time, the codes of the steps next to it are accepted for clock drift.
+// The step of the matching code is returned so it can be used only once.
+func CheckTOTP(secret, code string, at time.Time) (int64, bool) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != constant.TOTPDigits {
+		return 0, false
+	}
+
+	step := at.Unix() / constant.TOTPPeriod
+	for _, s := range []int64{step - 1, step, step + 1} {
+		if hmac.Equal([]byte(totpCode(key, s)), []byte(code)) {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+// UseTOTPStep func mark a totp step of the user as used, false means it was already used
+func UseTOTPStep(ctx context.Context, username string, step int64) bool {
+	key := fmt.Sprintf(constant.TOTPUsedFormat, username, step)
+	ok, err := RedisWithContext(ctx).SetNX(key, 1, 3*constant.TOTPPeriod*time.Second).Result()
+	if err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "UseTOTPStep()"))
+		return false
+	}
+	return ok
+}
+
+// totpCode func compute the totp code of a step as in RFC 6238
+func totpCode(key []byte, step int64) string {
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	modulo := uint32(1)
+	for i := 0; i < constant.TOTPDigits; i++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", constant.TOTPDigits, value%modulo)
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Key is the sha1 key of the test vectors of RFC 6238
+var rfc6238Key = []byte("12345678901234567890")
+
+func TestTOTPCode(t *testing.T) {
+	// the vectors of RFC 6238 appendix B, cut to the last 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+
+	for _, test := range tests {
+		t.Run(time.Unix(test.unix, 0).UTC().Format(time.RFC3339), func(t *testing.T) {
+			if got := totpCode(rfc6238Key, test.unix/30); got != test.want {
+				t.Errorf("totpCode() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckTOTP(t *testing.T) {
+	secret := totpEncoding.EncodeToString(rfc6238Key)
+	at := time.Unix(1111111111, 0)
+	step := at.Unix() / 30
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "current step", secret: secret, code: "050471", wantStep: step, wantOK: true},
+		{name: "lower case secret", secret: strings.ToLower(secret), code: "050471", wantStep: step, wantOK: true},
+		{name: "previous step", secret: secret, code: totpCode(rfc6238Key, step-1), wantStep: step - 1, wantOK: true},
+		{name: "next step", secret: secret, code: totpCode(rfc6238Key, step+1), wantStep: step + 1, wantOK: true},
+		{name: "two steps ago", secret: secret, code: totpCode(rfc6238Key, step-2)},
+		{name: "two steps ahead", secret: secret, code: totpCode(rfc6238Key, step+2)},
+		{name: "wrong code", secret: secret, code: "000000"},
+		{name: "short code", secret: secret, code: "50471"},
+		{name: "long code", secret: secret, code: "0050471"},
+		{name: "empty code", secret: secret, code: ""},
+		{name: "invalid secret", secret: "not base32!", code: "050471"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gotStep, gotOK := CheckTOTP(test.secret, test.code, at)
+			if gotOK != test.wantOK || gotStep != test.wantStep {
+				t.Errorf("CheckTOTP() = %d, %v, want %d, %v", gotStep, gotOK, test.wantStep, test.wantOK)
+			}
+		})
+	}
+}
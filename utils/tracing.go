@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/go-redis/redis"
+	"github.com/walk1ng/gin-photo-gallery-storage/conf"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer is the tracer of the server spans
+var Tracer = otel.Tracer(constant.TraceInstrumentation)
+
+// tracerProvider is nil when tracing is disabled
+var tracerProvider *sdktrace.TracerProvider
+
+// Initialize the tracer provider with the configured exporter, none disables tracing
+func init() {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch conf.ServerCfg.GetDefault(constant.TraceExporter, "none") {
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "otlp":
+		options := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(conf.ServerCfg.GetDefault(constant.TraceOTLPEndpoint, "localhost:4318")),
+		}
+		if conf.ServerCfg.GetDefault(constant.TraceOTLPInsecure, "false") == "true" {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(context.Background(), options...)
+	default:
+		return
+	}
+	if err != nil {
+		AppLogger.Error(err.Error(), zap.String("service", "init()"))
+		return
+	}
+
+	ratio, err := strconv.ParseFloat(conf.ServerCfg.GetDefault(constant.TraceSampleRatio, "1"), 64)
+	if err != nil {
+		ratio = 1
+	}
+	serviceName := conf.ServerCfg.GetDefault(constant.TraceServiceName, constant.TraceInstrumentation)
+	tracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+	otel.SetTracerProvider(tracerProvider)
+	PhotoStorage = &tracedStorage{storage: PhotoStorage}
+}
+
+// ShutdownTracing func flush the spans not exported yet
+func ShutdownTracing(ctx context.Context) error {
+	if tracerProvider == nil {
+		return nil
+	}
+	return tracerProvider.Shutdown(ctx)
+}
+
+// EndSpan func record the error of a span if any and end it
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// DetachContext func get a context for a background job started by a request,
+// it keeps the span and the logger of the request but is never canceled with it.
+func DetachContext(ctx context.Context) context.Context {
+	detached := trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx))
+	return WithLogger(detached, GetLogger(ctx))
+}
+
+// RedisWithContext func get a redis client whose commands are traced as children of the span in the context
+func RedisWithContext(ctx context.Context) *redis.Client {
+	if tracerProvider == nil || !trace.SpanContextFromContext(ctx).IsValid() {
+		return RedisClient
+	}
+	client := RedisClient.WithContext(ctx)
+	client.WrapProcess(func(process func(redis.Cmder) error) func(redis.Cmder) error {
+		return func(cmd redis.Cmder) error {
+			_, span := Tracer.Start(ctx, "redis "+cmd.Name(), trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attribute.String("db.system", "redis"), attribute.String("db.operation", cmd.Name())))
+			err := process(cmd)
+			if err == redis.Nil {
+				err = nil
+			}
+			EndSpan(span, err)
+			return err
+		}
+	})
+	client.WrapProcessPipeline(func(process func([]redis.Cmder) error) func([]redis.Cmder) error {
+		return func(cmds []redis.Cmder) error {
+			names := make([]string, 0, len(cmds))
+			for _, cmd := range cmds {
+				names = append(names, cmd.Name())
+			}
+			_, span := Tracer.Start(ctx, "redis pipeline", trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attribute.String("db.system", "redis"), attribute.String("db.operation", strings.Join(names, " "))))
+			err := process(cmds)
+			if err == redis.Nil {
+				err = nil
+			}
+			EndSpan(span, err)
+			return err
+		}
+	})
+	return client
+}
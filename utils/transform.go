@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/conf"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+)
+
+// TransformOptions struct describe how to transform a photo,
+// a width or height of 0 is derived from the other one by the aspect ratio.
+type TransformOptions struct {
+	Width   int    `json:"width" form:"w"`
+	Height  int    `json:"height" form:"h"`
+	Fit     string `json:"fit" form:"fit"`
+	Quality int    `json:"quality" form:"q"`
+	Format  string `json:"format" form:"format"`
+}
+
+var ErrInvalidTransform = apperr.New(constant.PhotoTransformInvalid, "invalid transform options")
+var ErrTransformNotAllowed = apperr.New(constant.PhotoTransformDenied, "transform is not signed or allowed")
+
+// transformFormats maps the output formats to their content types
+var transformFormats = map[string]string{
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+}
+
+// Normalize func fill the defaults of the options and check they are valid
+func (options *TransformOptions) Normalize() error {
+	if options.Fit == "" {
+		options.Fit = constant.TransformFitContain
+	}
+	if options.Quality == 0 {
+		options.Quality = constant.TransformDefaultQuality
+	}
+	if options.Format == "" {
+		options.Format = "jpeg"
+	}
+
+	maxDimension := transformMaxDimension()
+	switch {
+	case options.Width < 0 || options.Height < 0 || options.Width+options.Height == 0:
+		return ErrInvalidTransform
+	case options.Width > maxDimension || options.Height > maxDimension:
+		return ErrInvalidTransform
+	case options.Quality < 1 || options.Quality > 100:
+		return ErrInvalidTransform
+	case options.Fit != constant.TransformFitContain && options.Fit != constant.TransformFitCover && options.Fit != constant.TransformFitFill:
+		return ErrInvalidTransform
+	case transformFormats[options.Format] == "":
+		return ErrInvalidTransform
+	}
+	return nil
+}
+
+// transformMaxDimension func get the max width or height of a transformed image
+func transformMaxDimension() int {
+	maxDimension, _ := strconv.Atoi(conf.ServerCfg.GetDefault(constant.TransformMaxDimension, constant.DefaultTransformMaxDimension))
+	return maxDimension
+}
+
+// Key func get the canonical form of the options, used to sign and cache the transform
+func (options *TransformOptions) Key() string {
+	return fmt.Sprintf("w=%d&h=%d&fit=%s&q=%d&format=%s",
+		options.Width, options.Height, options.Fit, options.Quality, options.Format)
+}
+
+// ContentType func get the content type of the transformed image
+func (options *TransformOptions) ContentType() string {
+	return transformFormats[options.Format]
+}
+
+// SignTransform func sign the options of a transform of a photo
+func SignTransform(photoID uint, options *TransformOptions) string {
+	secret := conf.ServerCfg.GetDefault(constant.TransformSecret, "")
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("%d?%s", photoID, options.Key())))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CheckTransform func check the options are in the allowlist or carry a valid signature,
+// signatures are only accepted when a secret is configured.
+func CheckTransform(photoID uint, options *TransformOptions, signature string) error {
+	size := fmt.Sprintf("%dx%d", options.Width, options.Height)
+	for _, allowed := range strings.Split(conf.ServerCfg.GetDefault(constant.TransformAllowedSizes, ""), ",") {
+		if strings.TrimSpace(allowed) == size {
+			return nil
+		}
+	}
+
+	if conf.ServerCfg.GetDefault(constant.TransformSecret, "") == "" || signature == "" {
+		return ErrTransformNotAllowed
+	}
+	if !hmac.Equal([]byte(signature), []byte(SignTransform(photoID, options))) {
+		return ErrTransformNotAllowed
+	}
+	return nil
+}
+
+// TransformImage func decode an image, resize it by the options and encode it in the output format
+func TransformImage(reader io.Reader, options *TransformOptions) ([]byte, error) {
+	src, _, err := image.Decode(reader)
+	if err != nil {
+		return nil, ErrImageCorrupt
+	}
+
+	bounds := src.Bounds()
+	srcRect, dstWidth, dstHeight := transformRects(bounds, options)
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, srcRect, draw.Src, nil)
+
+	buffer := bytes.Buffer{}
+	switch options.Format {
+	case "png":
+		err = png.Encode(&buffer, dst)
+	default:
+		err = jpeg.Encode(&buffer, dst, &jpeg.Options{Quality: options.Quality})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// transformRects func get the source rectangle to scale and the size of the transformed image,
+// contain fits the image in the box, cover fills the box and crops the center and fill stretches it.
+func transformRects(bounds image.Rectangle, options *TransformOptions) (image.Rectangle, int, int) {
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+	width, height := options.Width, options.Height
+
+	// a missing dimension keeps the aspect ratio whatever the fit is,
+	// it is bounded by the max dimension so a very thin source can't blow up the other one
+	if width == 0 || height == 0 {
+		if width == 0 {
+			width = transformMaxDimension()
+		}
+		if height == 0 {
+			height = transformMaxDimension()
+		}
+		width, height = containSize(srcWidth, srcHeight, width, height)
+		return bounds, width, height
+	}
+
+	switch options.Fit {
+	case constant.TransformFitCover:
+		// crop the source to the aspect ratio of the box
+		cropWidth, cropHeight := srcWidth, srcWidth*height/width
+		if cropHeight > srcHeight {
+			cropWidth, cropHeight = srcHeight*width/height, srcHeight
+		}
+		x := bounds.Min.X + (srcWidth-cropWidth)/2
+		y := bounds.Min.Y + (srcHeight-cropHeight)/2
+		return image.Rect(x, y, x+cropWidth, y+cropHeight), width, height
+	case constant.TransformFitFill:
+		return bounds, width, height
+	default:
+		width, height = containSize(srcWidth, srcHeight, width, height)
+		return bounds, width, height
+	}
+}
+
+// containSize func get the largest size with the aspect ratio of the source that fits in the box
+func containSize(srcWidth, srcHeight, width, height int) (int, int) {
+	if srcWidth*height > srcHeight*width {
+		return width, maxInt(1, srcHeight*width/srcWidth)
+	}
+	return maxInt(1, srcWidth*height/srcHeight), height
+}
+
+// maxInt func get the larger one of two ints
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
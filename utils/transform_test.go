@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"image"
+	"testing"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+)
+
+func TestTransformOptionsNormalize(t *testing.T) {
+	setConfig(t, constant.TransformMaxDimension, "4096")
+
+	tests := []struct {
+		name    string
+		options TransformOptions
+		want    TransformOptions
+		wantErr bool
+	}{
+		{
+			name:    "defaults",
+			options: TransformOptions{Width: 320},
+			want:    TransformOptions{Width: 320, Fit: constant.TransformFitContain, Quality: constant.TransformDefaultQuality, Format: "jpeg"},
+		},
+		{
+			name:    "all set",
+			options: TransformOptions{Width: 100, Height: 100, Fit: constant.TransformFitCover, Quality: 50, Format: "png"},
+			want:    TransformOptions{Width: 100, Height: 100, Fit: constant.TransformFitCover, Quality: 50, Format: "png"},
+		},
+		{
+			name:    "max dimension",
+			options: TransformOptions{Height: 4096, Fit: constant.TransformFitFill},
+			want:    TransformOptions{Height: 4096, Fit: constant.TransformFitFill, Quality: constant.TransformDefaultQuality, Format: "jpeg"},
+		},
+		{name: "no dimension", options: TransformOptions{}, wantErr: true},
+		{name: "negative width", options: TransformOptions{Width: -1, Height: 100}, wantErr: true},
+		{name: "negative height", options: TransformOptions{Width: 100, Height: -1}, wantErr: true},
+		{name: "too wide", options: TransformOptions{Width: 4097}, wantErr: true},
+		{name: "too high", options: TransformOptions{Height: 4097}, wantErr: true},
+		{name: "quality too low", options: TransformOptions{Width: 100, Quality: -1}, wantErr: true},
+		{name: "quality too high", options: TransformOptions{Width: 100, Quality: 101}, wantErr: true},
+		{name: "unknown fit", options: TransformOptions{Width: 100, Fit: "stretch"}, wantErr: true},
+		{name: "unknown format", options: TransformOptions{Width: 100, Format: "gif"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := test.options
+			err := options.Normalize()
+			if test.wantErr {
+				if err != ErrInvalidTransform {
+					t.Fatalf("Normalize() error = %v, want %v", err, ErrInvalidTransform)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Normalize() error = %v", err)
+			}
+			if options != test.want {
+				t.Errorf("Normalize() = %+v, want %+v", options, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckTransform(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		photoID   uint
+		options   TransformOptions
+		signWith  *TransformOptions // the options the signature is made of, nil for no signature
+		signPhoto uint
+		signature string // used when signWith is nil
+		wantErr   bool
+	}{
+		{name: "allowed size", photoID: 1, options: TransformOptions{Width: 320}},
+		{name: "allowed square", photoID: 1, options: TransformOptions{Width: 160, Height: 160}},
+		{name: "not allowed", photoID: 1, options: TransformOptions{Width: 100, Height: 100}, wantErr: true},
+		{name: "signed without a secret", photoID: 1, options: TransformOptions{Width: 100}, signature: SignTransform(1, &TransformOptions{Width: 100}), wantErr: true},
+		{name: "signed", secret: "secret", photoID: 1, options: TransformOptions{Width: 100}, signWith: &TransformOptions{Width: 100}, signPhoto: 1},
+		{name: "no signature", secret: "secret", photoID: 1, options: TransformOptions{Width: 100}, wantErr: true},
+		{name: "bad signature", secret: "secret", photoID: 1, options: TransformOptions{Width: 100}, signature: "00", wantErr: true},
+		{name: "signed for another photo", secret: "secret", photoID: 1, options: TransformOptions{Width: 100}, signWith: &TransformOptions{Width: 100}, signPhoto: 2, wantErr: true},
+		{name: "signed for other options", secret: "secret", photoID: 1, options: TransformOptions{Width: 100}, signWith: &TransformOptions{Width: 200}, signPhoto: 1, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setConfig(t, constant.TransformAllowedSizes, "160x160, 320x0,640x0")
+			setConfig(t, constant.TransformSecret, test.secret)
+
+			signature := test.signature
+			if test.signWith != nil {
+				signature = SignTransform(test.signPhoto, test.signWith)
+			}
+			err := CheckTransform(test.photoID, &test.options, signature)
+			if test.wantErr && err != ErrTransformNotAllowed {
+				t.Errorf("CheckTransform() error = %v, want %v", err, ErrTransformNotAllowed)
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("CheckTransform() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestTransformRects(t *testing.T) {
+	setConfig(t, constant.TransformMaxDimension, "4096")
+
+	tests := []struct {
+		name       string
+		bounds     image.Rectangle
+		options    TransformOptions
+		wantRect   image.Rectangle
+		wantWidth  int
+		wantHeight int
+	}{
+		{
+			name:     "contain landscape",
+			bounds:   image.Rect(0, 0, 4000, 3000),
+			options:  TransformOptions{Width: 200, Height: 200, Fit: constant.TransformFitContain},
+			wantRect: image.Rect(0, 0, 4000, 3000), wantWidth: 200, wantHeight: 150,
+		},
+		{
+			name:     "contain portrait",
+			bounds:   image.Rect(0, 0, 3000, 4000),
+			options:  TransformOptions{Width: 200, Height: 200, Fit: constant.TransformFitContain},
+			wantRect: image.Rect(0, 0, 3000, 4000), wantWidth: 150, wantHeight: 200,
+		},
+		{
+			name:     "width only",
+			bounds:   image.Rect(0, 0, 4000, 3000),
+			options:  TransformOptions{Width: 320, Fit: constant.TransformFitFill},
+			wantRect: image.Rect(0, 0, 4000, 3000), wantWidth: 320, wantHeight: 240,
+		},
+		{
+			name:     "height only",
+			bounds:   image.Rect(0, 0, 4000, 3000),
+			options:  TransformOptions{Height: 300, Fit: constant.TransformFitCover},
+			wantRect: image.Rect(0, 0, 4000, 3000), wantWidth: 400, wantHeight: 300,
+		},
+		{
+			name:     "height only of a thin source is bounded",
+			bounds:   image.Rect(0, 0, 10000, 10),
+			options:  TransformOptions{Height: 1000, Fit: constant.TransformFitContain},
+			wantRect: image.Rect(0, 0, 10000, 10), wantWidth: 4096, wantHeight: 4,
+		},
+		{
+			name:     "cover crops the center",
+			bounds:   image.Rect(0, 0, 4000, 3000),
+			options:  TransformOptions{Width: 100, Height: 100, Fit: constant.TransformFitCover},
+			wantRect: image.Rect(500, 0, 3500, 3000), wantWidth: 100, wantHeight: 100,
+		},
+		{
+			name:     "cover of an offset source",
+			bounds:   image.Rect(10, 20, 410, 320),
+			options:  TransformOptions{Width: 300, Height: 100, Fit: constant.TransformFitCover},
+			wantRect: image.Rect(10, 103, 410, 236), wantWidth: 300, wantHeight: 100,
+		},
+		{
+			name:     "fill stretches",
+			bounds:   image.Rect(0, 0, 4000, 3000),
+			options:  TransformOptions{Width: 100, Height: 200, Fit: constant.TransformFitFill},
+			wantRect: image.Rect(0, 0, 4000, 3000), wantWidth: 100, wantHeight: 200,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rect, width, height := transformRects(test.bounds, &test.options)
+			if rect != test.wantRect || width != test.wantWidth || height != test.wantHeight {
+				t.Errorf("transformRects() = %v, %d, %d, want %v, %d, %d",
+					rect, width, height, test.wantRect, test.wantWidth, test.wantHeight)
+			}
+		})
+	}
+}
+
+func TestContainSize(t *testing.T) {
+	tests := []struct {
+		name                  string
+		srcWidth, srcHeight   int
+		width, height         int
+		wantWidth, wantHeight int
+	}{
+		{name: "landscape", srcWidth: 4000, srcHeight: 3000, width: 320, height: 320, wantWidth: 320, wantHeight: 240},
+		{name: "portrait", srcWidth: 3000, srcHeight: 4000, width: 320, height: 320, wantWidth: 240, wantHeight: 320},
+		{name: "square in a wide box", srcWidth: 100, srcHeight: 100, width: 80, height: 50, wantWidth: 50, wantHeight: 50},
+		{name: "same ratio", srcWidth: 400, srcHeight: 300, width: 800, height: 600, wantWidth: 800, wantHeight: 600},
+		{name: "thin source keeps a pixel", srcWidth: 10000, srcHeight: 1, width: 4096, height: 4096, wantWidth: 4096, wantHeight: 1},
+		{name: "tall source keeps a pixel", srcWidth: 1, srcHeight: 10000, width: 4096, height: 4096, wantWidth: 1, wantHeight: 4096},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			width, height := containSize(test.srcWidth, test.srcHeight, test.width, test.height)
+			if width != test.wantWidth || height != test.wantHeight {
+				t.Errorf("containSize() = %d, %d, want %d, %d", width, height, test.wantWidth, test.wantHeight)
+			}
+		})
+	}
+}
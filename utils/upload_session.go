@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/walk1ng/gin-photo-gallery-storage/apperr"
+	"github.com/walk1ng/gin-photo-gallery-storage/constant"
+)
+
+// UploadSession struct keep the state of a resumable photo upload
+type UploadSession struct {
+	UserName    string
+	PhotoID     uint
+	BlobName    string
+	TotalSize   int64
+	ContentType string
+}
+
+var ErrNoUploadSession = apperr.New(constant.PhotoUploadNotExist, "no such upload session")
+
+// SaveUploadSession func save a resumable upload session to redis
+func SaveUploadSession(ctx context.Context, uploadID string, session *UploadSession) error {
+	key := fmt.Sprintf(constant.UploadSessionFormat, uploadID)
+	fields := map[string]interface{}{
+		"user_name":    session.UserName,
+		"photo_id":     session.PhotoID,
+		"blob_name":    session.BlobName,
+		"total_size":   session.TotalSize,
+		"content_type": session.ContentType,
+	}
+
+	err := RedisWithContext(ctx).HMSet(key, fields).Err()
+	if err == nil {
+		err = RedisWithContext(ctx).Expire(key, constant.UploadSessionMaxAge*time.Second).Err()
+	}
+	if err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "SaveUploadSession()"))
+		return err
+	}
+	return nil
+}
+
+// GetUploadSession func get a resumable upload session from redis
+func GetUploadSession(ctx context.Context, uploadID string) (*UploadSession, error) {
+	key := fmt.Sprintf(constant.UploadSessionFormat, uploadID)
+	fields, err := RedisWithContext(ctx).HGetAll(key).Result()
+	if err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "GetUploadSession()"))
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, ErrNoUploadSession
+	}
+
+	photoID, _ := strconv.ParseUint(fields["photo_id"], 10, 64)
+	totalSize, _ := strconv.ParseInt(fields["total_size"], 10, 64)
+	return &UploadSession{
+		UserName:    fields["user_name"],
+		PhotoID:     uint(photoID),
+		BlobName:    fields["blob_name"],
+		TotalSize:   totalSize,
+		ContentType: fields["content_type"],
+	}, nil
+}
+
+// SetUploadContentType func set the content type detected from the first chunk of the upload
+func SetUploadContentType(ctx context.Context, uploadID, contentType string) error {
+	key := fmt.Sprintf(constant.UploadSessionFormat, uploadID)
+	if err := RedisWithContext(ctx).HSet(key, "content_type", contentType).Err(); err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "SetUploadContentType()"))
+		return err
+	}
+	return nil
+}
+
+// AddUploadChunk func record a staged chunk and refresh the expiration of the session and its upload status
+func AddUploadChunk(ctx context.Context, uploadID string, index int, size int64) error {
+	sessionKey := fmt.Sprintf(constant.UploadSessionFormat, uploadID)
+	chunksKey := fmt.Sprintf(constant.UploadChunksFormat, uploadID)
+
+	pipe := RedisWithContext(ctx).TxPipeline()
+	pipe.HSet(chunksKey, strconv.Itoa(index), size)
+	pipe.Expire(chunksKey, constant.UploadSessionMaxAge*time.Second)
+	pipe.Expire(sessionKey, constant.UploadSessionMaxAge*time.Second)
+	pipe.Expire(uploadID, constant.UploadStatusMaxAge*time.Second)
+	if _, err := pipe.Exec(); err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "AddUploadChunk()"))
+		return err
+	}
+	return nil
+}
+
+// GetUploadChunks func get the sizes of the staged chunks by their index
+func GetUploadChunks(ctx context.Context, uploadID string) (map[int]int64, error) {
+	key := fmt.Sprintf(constant.UploadChunksFormat, uploadID)
+	fields, err := RedisWithContext(ctx).HGetAll(key).Result()
+	if err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "GetUploadChunks()"))
+		return nil, err
+	}
+
+	chunks := make(map[int]int64, len(fields))
+	for field, value := range fields {
+		index, _ := strconv.Atoi(field)
+		size, _ := strconv.ParseInt(value, 10, 64)
+		chunks[index] = size
+	}
+	return chunks, nil
+}
+
+// RemoveUploadSession func remove a resumable upload session and its chunks from redis
+func RemoveUploadSession(ctx context.Context, uploadID string) bool {
+	err := RedisWithContext(ctx).Del(
+		fmt.Sprintf(constant.UploadSessionFormat, uploadID),
+		fmt.Sprintf(constant.UploadChunksFormat, uploadID)).Err()
+	if err != nil {
+		GetLogger(ctx).Error(err.Error(), zap.String("service", "RemoveUploadSession()"))
+		return false
+	}
+	return true
+}